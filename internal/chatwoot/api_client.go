@@ -3,7 +3,8 @@ package chatwoot
 import (
 	"bytes"
 	"chatwoot-sync-go/internal/config"
-	"encoding/base64"
+	"chatwoot-sync-go/internal/models"
+	"context"
 	"encoding/json"
 	"fmt"
 	"io"
@@ -11,7 +12,7 @@ import (
 	"mime"
 	"mime/multipart"
 	"net/http"
-	"path/filepath"
+	"net/textproto"
 	"strings"
 	"time"
 )
@@ -34,73 +35,24 @@ func NewAPIClient(cfg *config.Config) *APIClient {
 	}
 }
 
+// IsConfigured indica se a URL base e o token da API do Chatwoot foram informados
+func (c *APIClient) IsConfigured() bool {
+	return c.baseURL != "" && c.token != ""
+}
+
 // CreateMessageWithAttachment cria uma mensagem com attachment via API do Chatwoot
 func (c *APIClient) CreateMessageWithAttachment(
+	ctx context.Context,
 	conversationID int,
 	content string,
 	messageType string, // "incoming" ou "outgoing"
-	attachmentURL string,
-	attachmentType string, // "image", "video", "audio", "file"
+	attachment *models.ChatwootAttachment,
 	sourceID string,
 ) (map[string]interface{}, error) {
-	if c.baseURL == "" || c.token == "" {
+	if !c.IsConfigured() {
 		return nil, fmt.Errorf("Chatwoot API not configured (CHATWOOT_BASE_URL and CHATWOOT_API_TOKEN required)")
 	}
 
-	// Determinar se é data URL (base64) ou file URL
-	isDataURL := strings.HasPrefix(attachmentURL, "data:")
-	
-	var fileData []byte
-	var fileName string
-	var mimeType string
-
-	if isDataURL {
-		// Extrair base64 do data URL
-		parts := strings.Split(attachmentURL, ",")
-		if len(parts) != 2 {
-			return nil, fmt.Errorf("invalid data URL format")
-		}
-		
-		// Extrair mime type do data URL
-		dataPart := parts[0]
-		if strings.Contains(dataPart, ";base64") {
-			mimeType = strings.TrimPrefix(strings.Split(dataPart, ";")[0], "data:")
-		}
-		
-		var err error
-		fileData, err = base64.StdEncoding.DecodeString(parts[1])
-		if err != nil {
-			return nil, fmt.Errorf("failed to decode base64: %w", err)
-		}
-		
-		// Gerar nome de arquivo baseado no tipo
-		ext := c.getExtensionFromMimeType(mimeType)
-		fileName = fmt.Sprintf("media_%d%s", time.Now().Unix(), ext)
-	} else {
-		// Baixar arquivo da URL
-		resp, err := http.Get(attachmentURL)
-		if err != nil {
-			return nil, fmt.Errorf("failed to download file: %w", err)
-		}
-		defer resp.Body.Close()
-
-		if resp.StatusCode != http.StatusOK {
-			return nil, fmt.Errorf("failed to download file: status %d", resp.StatusCode)
-		}
-
-		fileData, err = io.ReadAll(resp.Body)
-		if err != nil {
-			return nil, fmt.Errorf("failed to read file: %w", err)
-		}
-
-		// Extrair nome de arquivo e mime type da URL
-		fileName = filepath.Base(attachmentURL)
-		mimeType = resp.Header.Get("Content-Type")
-		if mimeType == "" {
-			mimeType = mime.TypeByExtension(filepath.Ext(fileName))
-		}
-	}
-
 	// Criar FormData
 	body := &bytes.Buffer{}
 	writer := multipart.NewWriter(body)
@@ -114,14 +66,17 @@ func (c *APIClient) CreateMessageWithAttachment(
 		writer.WriteField("source_id", sourceID)
 	}
 
-	// Adicionar arquivo
-	part, err := writer.CreateFormFile("attachments[]", fileName)
+	// Adicionar arquivo, com o Content-Type da mídia para o Chatwoot identificar o tipo do anexo
+	header := make(textproto.MIMEHeader)
+	header.Set("Content-Disposition", fmt.Sprintf(`form-data; name="attachments[]"; filename="%s"`, attachment.FileName))
+	header.Set("Content-Type", attachment.ContentType)
+	part, err := writer.CreatePart(header)
 	if err != nil {
 		writer.Close()
 		return nil, fmt.Errorf("failed to create form file: %w", err)
 	}
 
-	if _, err := part.Write(fileData); err != nil {
+	if _, err := part.Write(attachment.Data); err != nil {
 		writer.Close()
 		return nil, fmt.Errorf("failed to write file data: %w", err)
 	}
@@ -132,7 +87,7 @@ func (c *APIClient) CreateMessageWithAttachment(
 	url := fmt.Sprintf("%s/api/v1/accounts/%d/conversations/%d/messages", 
 		c.baseURL, c.accountID, conversationID)
 	
-	req, err := http.NewRequest("POST", url, body)
+	req, err := http.NewRequestWithContext(ctx, "POST", url, body)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create request: %w", err)
 	}
@@ -166,15 +121,102 @@ func (c *APIClient) CreateMessageWithAttachment(
 	return result, nil
 }
 
-func (c *APIClient) getExtensionFromMimeType(mimeType string) string {
+// UpdateContactAvatar envia a foto do contato via API do Chatwoot
+func (c *APIClient) UpdateContactAvatar(ctx context.Context, contactID int, avatar *models.ChatwootAttachment) error {
+	if !c.IsConfigured() {
+		return fmt.Errorf("Chatwoot API not configured (CHATWOOT_BASE_URL and CHATWOOT_API_TOKEN required)")
+	}
+
+	body := &bytes.Buffer{}
+	writer := multipart.NewWriter(body)
+
+	// O Chatwoot valida o tipo do avatar, então o Content-Type da parte precisa ser o da imagem
+	header := make(textproto.MIMEHeader)
+	header.Set("Content-Disposition", fmt.Sprintf(`form-data; name="avatar"; filename="%s"`, avatar.FileName))
+	header.Set("Content-Type", avatar.ContentType)
+	part, err := writer.CreatePart(header)
+	if err != nil {
+		writer.Close()
+		return fmt.Errorf("failed to create form file: %w", err)
+	}
+	if _, err := part.Write(avatar.Data); err != nil {
+		writer.Close()
+		return fmt.Errorf("failed to write avatar data: %w", err)
+	}
+	writer.Close()
+
+	url := fmt.Sprintf("%s/api/v1/accounts/%d/contacts/%d", c.baseURL, c.accountID, contactID)
+	req, err := http.NewRequestWithContext(ctx, "PUT", url, body)
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("api_access_token", c.token)
+	req.Header.Set("Content-Type", writer.FormDataContentType())
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to execute request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		respBody, _ := io.ReadAll(resp.Body)
+		return fmt.Errorf("API returned status %d: %s", resp.StatusCode, string(respBody))
+	}
+
+	log.Printf("Successfully updated avatar of contact %d via API", contactID)
+	return nil
+}
+
+// mimeExtensions fixa a extensão dos tipos de mídia comuns no WhatsApp; a tabela do pacote mime
+// depende do sistema (ex.: .jfif para image/jpeg)
+
+
+var mimeExtensions = map[string]string{
+	// Imagens, vídeos e áudios
+	"image/jpeg":      ".jpg",
+	"image/png":       ".png",
+	"image/webp":      ".webp",
+	"image/gif":       ".gif",
+	"video/mp4":       ".mp4",
+	"video/3gpp":      ".3gp",
+	"video/quicktime": ".mov",
+	"audio/ogg":       ".ogg",
+	"audio/mpeg":      ".mp3",
+	"audio/mp4":       ".m4a",
+	"audio/aac":       ".aac",
+	"audio/amr":       ".amr",
+
+	// Documentos
+	"application/pdf":               ".pdf",
+	"application/zip":               ".zip",
+	"application/msword":            ".doc",
+	"application/vnd.ms-excel":      ".xls",
+	"application/vnd.ms-powerpoint": ".ppt",
+	"text/plain":                    ".txt",
+	"text/csv":                      ".csv",
+	"text/vcard":                    ".vcf",
+
+	// Documentos do Office (OOXML)
+	"application/vnd.openxmlformats-officedocument.wordprocessingml.document":   ".docx",
+	"application/vnd.openxmlformats-officedocument.spreadsheetml.sheet":         ".xlsx",
+	"application/vnd.openxmlformats-officedocument.presentationml.presentation": ".pptx",
+}
+
+// ExtensionFromMimeType retorna a extensão de arquivo (com ponto) para o mime type
+func ExtensionFromMimeType(mimeType string) string {
+	if parsed, _, err := mime.ParseMediaType(mimeType); err == nil {
+		mimeType = parsed
+	}
+	if ext, ok := mimeExtensions[strings.ToLower(mimeType)]; ok {
+		return ext
+	}
+
 	exts, err := mime.ExtensionsByType(mimeType)
 	if err != nil || len(exts) == 0 {
 		// Fallback para tipos comuns
 		switch {
 		case strings.HasPrefix(mimeType, "image/"):
-			if strings.Contains(mimeType, "webp") {
-				return ".webp"
-			}
 			return ".jpg"
 		case strings.HasPrefix(mimeType, "video/"):
 			return ".mp4"
@@ -0,0 +1,166 @@
+package chatwoot
+
+import (
+	"chatwoot-sync-go/internal/models"
+	"context"
+	"crypto/md5"
+	"crypto/rand"
+	"encoding/base64"
+	"fmt"
+	"log"
+	"math/big"
+
+	"github.com/lib/pq"
+)
+
+// blobKeyAlphabet é o alfabeto base36 usado pelo ActiveStorage para gerar chaves de blobs
+const blobKeyAlphabet = "0123456789abcdefghijklmnopqrstuvwxyz"
+
+// HasStorage indica se há um storage configurado para gravar anexos direto no banco
+func (d *Database) HasStorage() bool {
+	return d.storage != nil
+}
+
+// insertAttachment grava o arquivo no storage e cria as linhas em attachments,
+// active_storage_blobs e active_storage_attachments
+func (d *Database) insertAttachment(ctx context.Context, messageID int64, attachment *models.ChatwootAttachment) error {
+	if d.storage == nil {
+		return fmt.Errorf("no storage configured for attachments")
+	}
+	if messageID == 0 {
+		return fmt.Errorf("message was not inserted")
+	}
+
+	var attachmentID int64
+	attachmentInsert := `
+		INSERT INTO attachments (file_type, account_id, message_id, created_at, updated_at)
+		VALUES ($1, $2, $3, NOW(), NOW())
+		RETURNING id
+	`
+	err := d.q.QueryRowContext(ctx, attachmentInsert, attachment.FileType, d.cfg.Chatwoot.AccountID, messageID).Scan(&attachmentID)
+	if err != nil {
+		return fmt.Errorf("failed to insert attachment: %w", err)
+	}
+
+	return d.attachBlob(ctx, "file", "Attachment", attachmentID, attachment)
+}
+
+// attachBlob grava o arquivo no storage, cria o blob e o associa ao registro (record_type,
+// record_id) com o nome informado em active_storage_attachments. Se as linhas não forem gravadas,
+// ou se a transação em curso for desfeita, o arquivo é removido do storage.
+func (d *Database) attachBlob(ctx context.Context, name, recordType string, recordID int64, attachment *models.ChatwootAttachment) error {
+	key, err := generateBlobKey()
+	if err != nil {
+		return fmt.Errorf("failed to generate blob key: %w", err)
+	}
+
+	if err := d.storage.Put(key, attachment.Data, attachment.ContentType); err != nil {
+		return fmt.Errorf("failed to store file: %w", err)
+	}
+	if err := d.insertBlob(ctx, key, name, recordType, recordID, attachment); err != nil {
+		d.deleteStoredFiles([]string{key})
+		return err
+	}
+
+	if d.storedKeys != nil {
+		*d.storedKeys = append(*d.storedKeys, key)
+	}
+	return nil
+}
+
+// insertBlob cria as linhas do blob já gravado sob key em active_storage_blobs e active_storage_attachments
+func (d *Database) insertBlob(ctx context.Context, key, name, recordType string, recordID int64, attachment *models.ChatwootAttachment) error {
+
+	checksum := md5.Sum(attachment.Data)
+	var blobID int64
+	blobInsert := `
+		INSERT INTO active_storage_blobs (key, filename, content_type, metadata, service_name, byte_size, checksum, created_at)
+		VALUES ($1, $2, $3, '{"identified":true}', $4, $5, $6, NOW())
+		RETURNING id
+	`
+	err := d.q.QueryRowContext(ctx, blobInsert, key, attachment.FileName, attachment.ContentType, d.storage.ServiceName(),
+		len(attachment.Data), base64.StdEncoding.EncodeToString(checksum[:])).Scan(&blobID)
+	if err != nil {
+		return fmt.Errorf("failed to insert blob: %w", err)
+	}
+
+	linkInsert := `
+		INSERT INTO active_storage_attachments (name, record_type, record_id, blob_id, created_at)
+		VALUES ($1, $2, $3, $4, NOW())
+	`
+	if _, err := d.q.ExecContext(ctx, linkInsert, name, recordType, recordID, blobID); err != nil {
+		return fmt.Errorf("failed to insert blob attachment: %w", err)
+	}
+
+	return nil
+}
+
+// deleteUnusedBlobs apaga os blobs informados que pertencem ao storage configurado e que nenhum
+// anexo ou variante usa mais. Os arquivos são removidos do storage depois do commit da transação
+// em curso. Sem storage não faz nada: o arquivo fica onde o Chatwoot o gravou.
+func (d *Database) deleteUnusedBlobs(ctx context.Context, blobIDs []int64) error {
+	if d.storage == nil || len(blobIDs) == 0 {
+		return nil
+	}
+
+	var hasVariants bool
+	if err := d.q.QueryRowContext(ctx, `SELECT to_regclass('active_storage_variant_records') IS NOT NULL`).Scan(&hasVariants); err != nil {
+		return fmt.Errorf("failed to check variant records table: %w", err)
+	}
+
+	deleteBlobs := `
+		DELETE FROM active_storage_blobs b
+		WHERE b.id = ANY($1) AND b.service_name = $2
+			AND NOT EXISTS (SELECT 1 FROM active_storage_attachments a WHERE a.blob_id = b.id)
+	`
+	if hasVariants {
+		deleteBlobs += ` AND NOT EXISTS (SELECT 1 FROM active_storage_variant_records v WHERE v.blob_id = b.id)`
+	}
+	rows, err := d.q.QueryContext(ctx, deleteBlobs+` RETURNING b.key`, pq.Array(blobIDs), d.storage.ServiceName())
+	if err != nil {
+		return fmt.Errorf("failed to delete blobs: %w", err)
+	}
+	defer rows.Close()
+
+	var keys []string
+	for rows.Next() {
+		var key string
+		if err := rows.Scan(&key); err != nil {
+			return fmt.Errorf("failed to scan blob key: %w", err)
+		}
+		keys = append(keys, key)
+	}
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("failed to delete blobs: %w", err)
+	}
+
+	if d.releasedKeys == nil {
+		d.deleteStoredFiles(keys)
+		return nil
+	}
+	*d.releasedKeys = append(*d.releasedKeys, keys...)
+	return nil
+}
+
+// deleteStoredFiles remove do storage arquivos cujas linhas não existem (mais) no banco
+func (d *Database) deleteStoredFiles(keys []string) {
+	for _, key := range keys {
+		if err := d.storage.Delete(key); err != nil {
+			log.Printf("Warning: failed to delete orphan file %s from storage: %v", key, err)
+		}
+	}
+}
+
+// generateBlobKey gera uma chave aleatória de 28 caracteres base36, como SecureRandom.base36(28)
+func generateBlobKey() (string, error) {
+	key := make([]byte, 28)
+	max := big.NewInt(int64(len(blobKeyAlphabet)))
+	for i := range key {
+		n, err := rand.Int(rand.Reader, max)
+		if err != nil {
+			return "", err
+		}
+		key[i] = blobKeyAlphabet[n.Int64()]
+	}
+	return string(key), nil
+}
@@ -0,0 +1,114 @@
+package chatwoot
+
+import (
+	"chatwoot-sync-go/internal/models"
+	"context"
+	"database/sql"
+	"fmt"
+	"log"
+)
+
+// attrAvatarSource guarda, em additional_attributes, a origem da foto importada para o contato
+const attrAvatarSource = "whatsapp_avatar_source"
+
+// ContactAvatarSource retorna a origem da foto já importada para o contato ("" se nenhuma)
+func (d *Database) ContactAvatarSource(ctx context.Context, contactID int) (string, error) {
+	var source sql.NullString
+	query := `SELECT additional_attributes->>'` + attrAvatarSource + `' FROM contacts WHERE id = $1 AND account_id = $2`
+	err := d.q.QueryRowContext(ctx, query, contactID, d.cfg.Chatwoot.AccountID).Scan(&source)
+	if err == sql.ErrNoRows {
+		return "", nil
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to read avatar source of contact %d: %w", contactID, err)
+	}
+	return source.String, nil
+}
+
+// SetContactAvatarSource registra a origem da foto importada para o contato
+func (d *Database) SetContactAvatarSource(ctx context.Context, contactID int, source string) error {
+	query := `
+		UPDATE contacts
+		SET additional_attributes = COALESCE(additional_attributes, '{}'::jsonb) || jsonb_build_object('` + attrAvatarSource + `', $3::TEXT)
+		WHERE id = $1 AND account_id = $2
+	`
+	if _, err := d.q.ExecContext(ctx, query, contactID, d.cfg.Chatwoot.AccountID, source); err != nil {
+		return fmt.Errorf("failed to save avatar source of contact %d: %w", contactID, err)
+	}
+	return nil
+}
+
+// contactAvatarChange é o registro no diário de uma troca de foto: os blobs da foto nova e os da
+// anterior, com a origem que ela tinha
+type contactAvatarChange struct {
+	BlobIDs         []int64 `json:"blob_ids"`
+	PreviousBlobIDs []int64 `json:"previous_blob_ids"`
+	PreviousSource  string  `json:"previous_source"`
+}
+
+// ContactAvatarBlobIDs retorna os blobs anexados como foto do contato
+func (d *Database) ContactAvatarBlobIDs(ctx context.Context, contactID int) ([]int64, error) {
+	query := `
+		SELECT blob_id FROM active_storage_attachments
+		WHERE record_type = 'Contact' AND name = 'avatar' AND record_id = $1
+	`
+	ids, err := d.queryIDs(ctx, query, contactID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read avatar of contact %d: %w", contactID, err)
+	}
+	return ids, nil
+}
+
+// RecordContactAvatar registra no diário da execução a troca de foto do contato, com a foto e a
+// origem anteriores, para que o rollback possa desfazê-la
+func (d *Database) RecordContactAvatar(ctx context.Context, contactID int, previousBlobIDs []int64, previousSource string) error {
+	if d.journal.get() == "" {
+		return nil
+	}
+
+	blobIDs, err := d.ContactAvatarBlobIDs(ctx, contactID)
+	if err != nil {
+		return err
+	}
+	change := contactAvatarChange{BlobIDs: blobIDs, PreviousBlobIDs: previousBlobIDs, PreviousSource: previousSource}
+	return d.recordDetails(ctx, JournalContactAvatars, int64(contactID), change)
+}
+
+// SetContactAvatar substitui a foto do contato via ActiveStorage e registra sua origem. O blob da
+// foto anterior apenas deixa de ser anexado ao contato, pois pode ter variantes (miniaturas) geradas;
+// a troca é registrada no diário da execução.
+func (d *Database) SetContactAvatar(ctx context.Context, contactID int, avatar *models.ChatwootAttachment, source string) error {
+	if d.storage == nil {
+		return fmt.Errorf("no storage configured for avatars")
+	}
+
+	return d.WithTx(ctx, func(tx *Database) error {
+		previousSource, err := tx.ContactAvatarSource(ctx, contactID)
+		if err != nil {
+			return err
+		}
+
+		deleteQuery := `
+			DELETE FROM active_storage_attachments
+			WHERE record_type = 'Contact' AND name = 'avatar' AND record_id = $1
+			RETURNING blob_id
+		`
+		previousBlobIDs, err := tx.queryIDs(ctx, deleteQuery, contactID)
+		if err != nil {
+			return fmt.Errorf("failed to remove previous avatar of contact %d: %w", contactID, err)
+		}
+
+		if err := tx.attachBlob(ctx, "avatar", "Contact", int64(contactID), avatar); err != nil {
+			return err
+		}
+		if err := tx.SetContactAvatarSource(ctx, contactID, source); err != nil {
+			return err
+		}
+		if err := tx.RecordContactAvatar(ctx, contactID, previousBlobIDs, previousSource); err != nil {
+			return err
+		}
+
+		log.Printf("SetContactAvatar: Avatar of contact %d updated (%d bytes)", contactID, len(avatar.Data))
+		return nil
+	})
+}
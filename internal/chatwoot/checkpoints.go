@@ -0,0 +1,93 @@
+package chatwoot
+
+import (
+	"chatwoot-sync-go/internal/models"
+	"context"
+	"fmt"
+	"log"
+)
+
+// EnsureCheckpointTable cria a tabela de checkpoints da sincronização, se ela não existir
+func (d *Database) EnsureCheckpointTable(ctx context.Context) error {
+	query := `
+		CREATE TABLE IF NOT EXISTS chatwoot_sync_checkpoints (
+			account_id INTEGER NOT NULL,
+			inbox_id INTEGER NOT NULL,
+			chat_id TEXT NOT NULL,
+			last_message_timestamp BIGINT NOT NULL DEFAULT 0,
+			last_message_id TEXT NOT NULL DEFAULT '',
+			last_chat_timestamp BIGINT NOT NULL DEFAULT 0,
+			updated_at TIMESTAMP NOT NULL DEFAULT NOW(),
+			PRIMARY KEY (account_id, inbox_id, chat_id)
+		)
+	`
+
+	if _, err := d.q.ExecContext(ctx, query); err != nil {
+		return fmt.Errorf("failed to create checkpoint table: %w", err)
+	}
+
+	return nil
+}
+
+// HasCheckpointTable indica se a tabela de checkpoints já existe (usado quando não se pode criá-la)
+func (d *Database) HasCheckpointTable(ctx context.Context) (bool, error) {
+	var exists bool
+	err := d.q.QueryRowContext(ctx, `SELECT to_regclass('chatwoot_sync_checkpoints') IS NOT NULL`).Scan(&exists)
+	if err != nil {
+		return false, fmt.Errorf("failed to check checkpoint table: %w", err)
+	}
+	return exists, nil
+}
+
+// GetCheckpoints carrega os checkpoints de todos os chats do inbox
+func (d *Database) GetCheckpoints(ctx context.Context, inboxID int) (map[string]models.SyncCheckpoint, error) {
+	query := `
+		SELECT chat_id, last_message_timestamp, last_message_id, last_chat_timestamp
+		FROM chatwoot_sync_checkpoints
+		WHERE account_id = $1 AND inbox_id = $2
+	`
+	rows, err := d.q.QueryContext(ctx, query, d.cfg.Chatwoot.AccountID, inboxID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load checkpoints: %w", err)
+	}
+	defer rows.Close()
+
+	checkpoints := make(map[string]models.SyncCheckpoint)
+	for rows.Next() {
+		var cp models.SyncCheckpoint
+		if err := rows.Scan(&cp.ChatID, &cp.LastMessageTimestamp, &cp.LastMessageID, &cp.LastChatTimestamp); err != nil {
+			return nil, fmt.Errorf("failed to scan checkpoint: %w", err)
+		}
+		checkpoints[cp.ChatID] = cp
+	}
+
+	log.Printf("GetCheckpoints: Loaded %d checkpoints for inbox %d", len(checkpoints), inboxID)
+	return checkpoints, nil
+}
+
+// SaveCheckpoint grava o checkpoint do chat, sem nunca retroceder os timestamps já registrados
+func (d *Database) SaveCheckpoint(ctx context.Context, inboxID int, cp models.SyncCheckpoint) error {
+	query := `
+		INSERT INTO chatwoot_sync_checkpoints (
+			account_id, inbox_id, chat_id, last_message_timestamp, last_message_id, last_chat_timestamp, updated_at
+		) VALUES ($1, $2, $3, $4, $5, $6, NOW())
+		ON CONFLICT (account_id, inbox_id, chat_id) DO UPDATE SET
+			last_message_id = CASE
+				WHEN EXCLUDED.last_message_timestamp >= chatwoot_sync_checkpoints.last_message_timestamp
+					AND EXCLUDED.last_message_id <> ''
+				THEN EXCLUDED.last_message_id
+				ELSE chatwoot_sync_checkpoints.last_message_id
+			END,
+			last_message_timestamp = GREATEST(chatwoot_sync_checkpoints.last_message_timestamp, EXCLUDED.last_message_timestamp),
+			last_chat_timestamp = GREATEST(chatwoot_sync_checkpoints.last_chat_timestamp, EXCLUDED.last_chat_timestamp),
+			updated_at = NOW()
+	`
+
+	_, err := d.q.ExecContext(ctx, query, d.cfg.Chatwoot.AccountID, inboxID, cp.ChatID,
+		cp.LastMessageTimestamp, cp.LastMessageID, cp.LastChatTimestamp)
+	if err != nil {
+		return fmt.Errorf("failed to save checkpoint: %w", err)
+	}
+
+	return nil
+}
@@ -3,18 +3,36 @@ package chatwoot
 import (
 	"chatwoot-sync-go/internal/config"
 	"chatwoot-sync-go/internal/models"
+	"chatwoot-sync-go/internal/storage"
+	"context"
 	"database/sql"
 	"fmt"
 	"log"
+	"sort"
 	"strings"
 
 	"github.com/lib/pq"
 	_ "github.com/lib/pq"
 )
 
+// querier é a interface comum entre *sql.DB e *sql.Tx usada pelas consultas
+type querier interface {
+	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+	QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row
+}
+
 type Database struct {
 	db *sql.DB
+	q  querier
+	tx *sql.Tx
 	cfg *config.Config
+	storage storage.Storage
+	journal *runJournal
+	// storedKeys acumula as chaves gravadas no storage durante a transação, removidas se ela falhar
+	storedKeys *[]string
+	// releasedKeys acumula as chaves dos blobs apagados durante a transação, removidas do storage após o commit
+	releasedKeys *[]string
 }
 
 func NewDatabase(cfg *config.Config) (*Database, error) {
@@ -32,6 +50,9 @@ func NewDatabase(cfg *config.Config) (*Database, error) {
 	if err != nil {
 		return nil, fmt.Errorf("failed to open database: %w", err)
 	}
+	// Limita as conexões simultâneas dos workers; cada chat em andamento ocupa uma transação
+	db.SetMaxOpenConns(cfg.Chatwoot.DB.MaxConnections)
+	db.SetMaxIdleConns(cfg.Chatwoot.DB.MaxConnections)
 
 	if err := db.Ping(); err != nil {
 		return nil, fmt.Errorf("failed to ping database: %w", err)
@@ -39,9 +60,21 @@ func NewDatabase(cfg *config.Config) (*Database, error) {
 
 	log.Println("Connected to Chatwoot database successfully")
 
+	st, err := storage.New(cfg)
+	if err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to configure storage: %w", err)
+	}
+	if st != nil {
+		log.Printf("Using ActiveStorage service '%s' at %s for media attachments", st.ServiceName(), cfg.Chatwoot.Storage.Path)
+	}
+
 	return &Database{
-		db:  db,
-		cfg: cfg,
+		db:      db,
+		q:       db,
+		cfg:     cfg,
+		storage: st,
+		journal: &runJournal{},
 	}, nil
 }
 
@@ -49,34 +82,96 @@ func (d *Database) Close() error {
 	return d.db.Close()
 }
 
-// ListInboxes lista todos os inboxes disponíveis para debug
-func (d *Database) ListInboxes() ([]map[string]interface{}, error) {
+// WithTx executa fn dentro de uma transação, fazendo commit se fn retornar nil
+// e rollback caso contrário (inclusive se ctx for cancelado). Se d já estiver em
+// uma transação, ela é reutilizada.
+func (d *Database) WithTx(ctx context.Context, fn func(tx *Database) error) error {
+	if d.tx != nil {
+		return fn(d)
+	}
+
+	tx, err := d.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+
+	txDB := *d
+	txDB.q = tx
+	txDB.tx = tx
+	txDB.storedKeys = &[]string{}
+	txDB.releasedKeys = &[]string{}
+
+	if err := fn(&txDB); err != nil {
+		if rbErr := tx.Rollback(); rbErr != nil && rbErr != sql.ErrTxDone {
+			log.Printf("Warning: failed to rollback transaction: %v", rbErr)
+		}
+		d.deleteStoredFiles(*txDB.storedKeys)
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		d.deleteStoredFiles(*txDB.storedKeys)
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	// Os arquivos dos blobs apagados só saem do storage depois que as linhas deixaram de existir
+	d.deleteStoredFiles(*txDB.releasedKeys)
+	return nil
+}
+
+// lockContacts serializa, até o fim da transação, a criação dos contatos informados entre
+// workers concorrentes (pg_advisory_xact_lock). Fora de uma transação não faz nada.
+func (d *Database) lockContacts(ctx context.Context, contacts []models.ChatwootContact) error {
+	if d.tx == nil {
+		return nil
+	}
+
+	keys := make([]string, 0, len(contacts))
+	seen := make(map[string]bool, len(contacts))
+	for _, contact := range contacts {
+		key := contact.PhoneNumber
+		if key == "" {
+			key = contact.Identifier
+		}
+		if key == "" || seen[key] {
+			continue
+		}
+		seen[key] = true
+		keys = append(keys, key)
+	}
+	// Ordem fixa evita deadlock entre transações que bloqueiam os mesmos contatos
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		if _, err := d.q.ExecContext(ctx, `SELECT pg_advisory_xact_lock($1, hashtext($2))`, d.cfg.Chatwoot.AccountID, key); err != nil {
+			return fmt.Errorf("failed to lock contact %s: %w", key, err)
+		}
+	}
+	return nil
+}
+
+// ListInboxes lista os inboxes da conta configurada
+func (d *Database) ListInboxes(ctx context.Context) ([]models.ChatwootInbox, error) {
 	query := `SELECT id, name, inbox_type FROM inboxes WHERE account_id = $1 ORDER BY id`
-	rows, err := d.db.Query(query, d.cfg.Chatwoot.AccountID)
+	rows, err := d.q.QueryContext(ctx, query, d.cfg.Chatwoot.AccountID)
 	if err != nil {
 		return nil, fmt.Errorf("failed to list inboxes: %w", err)
 	}
 	defer rows.Close()
 
-	var inboxes []map[string]interface{}
+	var inboxes []models.ChatwootInbox
 	for rows.Next() {
-		var id int
-		var name, inboxType string
-		if err := rows.Scan(&id, &name, &inboxType); err != nil {
+		var inbox models.ChatwootInbox
+		if err := rows.Scan(&inbox.ID, &inbox.Name, &inbox.InboxType); err != nil {
 			return nil, fmt.Errorf("failed to scan inbox: %w", err)
 		}
-		inboxes = append(inboxes, map[string]interface{}{
-			"id":         id,
-			"name":       name,
-			"inbox_type": inboxType,
-		})
+		inboxes = append(inboxes, inbox)
 	}
 
-	return inboxes, nil
+	return inboxes, rows.Err()
 }
 
 // GetInbox busca o inbox pelo nome ou ID, ou usa o primeiro disponível
-func (d *Database) GetInbox() (int, error) {
+func (d *Database) GetInbox(ctx context.Context) (int, error) {
 	var inboxID int
 	var accountID int
 	
@@ -84,11 +179,11 @@ func (d *Database) GetInbox() (int, error) {
 		d.cfg.Chatwoot.AccountID, d.cfg.Chatwoot.InboxID, d.cfg.Chatwoot.InboxName)
 	
 	// Primeiro, lista todos os inboxes para debug
-	inboxes, listErr := d.ListInboxes()
+	inboxes, listErr := d.ListInboxes(ctx)
 	if listErr == nil && len(inboxes) > 0 {
 		log.Printf("Available inboxes for account %d:", d.cfg.Chatwoot.AccountID)
 		for _, inbox := range inboxes {
-			log.Printf("  - ID: %v, Name: %v, Type: %v", inbox["id"], inbox["name"], inbox["inbox_type"])
+			log.Printf("  - ID: %d, Name: %s, Type: %s", inbox.ID, inbox.Name, inbox.InboxType)
 		}
 	}
 	
@@ -97,7 +192,7 @@ func (d *Database) GetInbox() (int, error) {
 		// Primeiro verifica se o inbox existe (qualquer conta)
 		var tempID, tempAccountID int
 		checkQuery := `SELECT id, account_id FROM inboxes WHERE id = $1 LIMIT 1`
-		err := d.db.QueryRow(checkQuery, d.cfg.Chatwoot.InboxID).Scan(&tempID, &tempAccountID)
+		err := d.q.QueryRowContext(ctx, checkQuery, d.cfg.Chatwoot.InboxID).Scan(&tempID, &tempAccountID)
 		if err == nil {
 			if tempAccountID == d.cfg.Chatwoot.AccountID {
 				log.Printf("Found inbox ID %d in account %d", tempID, tempAccountID)
@@ -110,7 +205,7 @@ func (d *Database) GetInbox() (int, error) {
 		
 		// Agora tenta com account_id
 		query := `SELECT id FROM inboxes WHERE account_id = $1 AND id = $2 LIMIT 1`
-		err = d.db.QueryRow(query, d.cfg.Chatwoot.AccountID, d.cfg.Chatwoot.InboxID).Scan(&inboxID)
+		err = d.q.QueryRowContext(ctx, query, d.cfg.Chatwoot.AccountID, d.cfg.Chatwoot.InboxID).Scan(&inboxID)
 		if err == nil {
 			log.Printf("Found inbox by ID: %d", inboxID)
 			return inboxID, nil
@@ -125,7 +220,7 @@ func (d *Database) GetInbox() (int, error) {
 	// Se não encontrou por ID, tenta buscar por nome
 	if d.cfg.Chatwoot.InboxName != "" {
 		query := `SELECT id FROM inboxes WHERE account_id = $1 AND name = $2 LIMIT 1`
-		err := d.db.QueryRow(query, d.cfg.Chatwoot.AccountID, d.cfg.Chatwoot.InboxName).Scan(&inboxID)
+		err := d.q.QueryRowContext(ctx, query, d.cfg.Chatwoot.AccountID, d.cfg.Chatwoot.InboxName).Scan(&inboxID)
 		if err == nil {
 			log.Printf("Found inbox by name '%s': %d", d.cfg.Chatwoot.InboxName, inboxID)
 			return inboxID, nil
@@ -139,7 +234,7 @@ func (d *Database) GetInbox() (int, error) {
 	
 	// Se não encontrou, tenta buscar qualquer inbox da conta
 	query := `SELECT id FROM inboxes WHERE account_id = $1 ORDER BY id LIMIT 1`
-	err := d.db.QueryRow(query, d.cfg.Chatwoot.AccountID).Scan(&inboxID)
+	err := d.q.QueryRowContext(ctx, query, d.cfg.Chatwoot.AccountID).Scan(&inboxID)
 	if err == nil {
 		log.Printf("Warning: Using first available inbox (ID: %d) from account %d", inboxID, d.cfg.Chatwoot.AccountID)
 		return inboxID, nil
@@ -151,7 +246,7 @@ func (d *Database) GetInbox() (int, error) {
 	// Se ainda não encontrou, verifica se há inboxes em outras contas
 	if d.cfg.Chatwoot.InboxID > 0 {
 		checkAllQuery := `SELECT id, account_id FROM inboxes WHERE id = $1 LIMIT 1`
-		err := d.db.QueryRow(checkAllQuery, d.cfg.Chatwoot.InboxID).Scan(&inboxID, &accountID)
+		err := d.q.QueryRowContext(ctx, checkAllQuery, d.cfg.Chatwoot.InboxID).Scan(&inboxID, &accountID)
 		if err == nil {
 			return 0, fmt.Errorf("inbox ID %d exists but belongs to account %d (configured account: %d). Please update CHATWOOT_ACCOUNT_ID or use the correct inbox", 
 				inboxID, accountID, d.cfg.Chatwoot.AccountID)
@@ -163,11 +258,11 @@ func (d *Database) GetInbox() (int, error) {
 }
 
 // GetChatwootUser busca o usuário do token
-func (d *Database) GetChatwootUser(token string) (*models.ChatwootUser, error) {
+func (d *Database) GetChatwootUser(ctx context.Context, token string) (*models.ChatwootUser, error) {
 	var user models.ChatwootUser
 	query := `SELECT owner_type AS user_type, owner_id AS user_id FROM access_tokens WHERE token = $1 LIMIT 1`
 	
-	err := d.db.QueryRow(query, token).Scan(&user.UserType, &user.UserID)
+	err := d.q.QueryRowContext(ctx, query, token).Scan(&user.UserType, &user.UserID)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get chatwoot user: %w", err)
 	}
@@ -177,6 +272,7 @@ func (d *Database) GetChatwootUser(token string) (*models.ChatwootUser, error) {
 
 // CreateContactsAndConversations cria contatos e conversas usando CTE
 func (d *Database) CreateContactsAndConversations(
+	ctx context.Context,
 	contacts []models.ChatwootContact,
 	inboxID int,
 ) (map[string]*models.ChatwootFKs, error) {
@@ -184,24 +280,41 @@ func (d *Database) CreateContactsAndConversations(
 		return make(map[string]*models.ChatwootFKs), nil
 	}
 
+	if err := d.lockContacts(ctx, contacts); err != nil {
+		return nil, err
+	}
+
 	// Construir VALUES para a CTE
 	var values []string
 	var args []interface{}
 	argIndex := 3 // $1 = account_id, $2 = inbox_id, $3+ = valores
 
 	for _, contact := range contacts {
-		values = append(values, fmt.Sprintf("($%d, $%d, $%d, $%d)", argIndex, argIndex+1, argIndex+2, argIndex+3))
-		args = append(args, contact.PhoneNumber, contact.Name, contact.FirstTimestamp, contact.LastTimestamp)
-		argIndex += 4
+		values = append(values, fmt.Sprintf("($%d, $%d, $%d, $%d, $%d)", argIndex, argIndex+1, argIndex+2, argIndex+3, argIndex+4))
+		args = append(args, contact.PhoneNumber, contact.Name, contact.FirstTimestamp, contact.LastTimestamp, contact.LID)
+		argIndex += 5
+	}
+
+	// Registrar no diário da execução as linhas criadas pela CTE
+	journalCTE := ""
+	if runID := d.journal.get(); runID != "" {
+		args = append(args, runID)
+		journalCTE = fmt.Sprintf(`,
+			journal AS (
+				INSERT INTO chatwoot_sync_journal (run_id, table_name, record_id)
+				SELECT $%[1]d::TEXT, '%[2]s', id FROM new_contact
+				UNION ALL SELECT $%[1]d::TEXT, '%[3]s', id FROM new_contact_inbox
+				UNION ALL SELECT $%[1]d::TEXT, '%[4]s', id FROM new_conversation
+			)`, argIndex, JournalContacts, JournalContactInboxes, JournalConversations)
 	}
 
 	// Query completa com CTE
 	query := fmt.Sprintf(`
 		WITH
 			phone_number AS (
-				SELECT phone_number, contact_name, created_at::BIGINT, last_activity_at::BIGINT FROM (
+				SELECT phone_number, contact_name, created_at::BIGINT, last_activity_at::BIGINT, lid::TEXT FROM (
 					VALUES %s
-				) as t (phone_number, contact_name, created_at, last_activity_at)
+				) as t (phone_number, contact_name, created_at, last_activity_at, lid)
 			),
 			only_new_phone_number AS (
 				SELECT * FROM phone_number
@@ -217,12 +330,13 @@ func (d *Database) CreateContactsAndConversations(
 				)
 			),
 			new_contact AS (
-				INSERT INTO contacts (name, phone_number, account_id, identifier, created_at, updated_at)
+				INSERT INTO contacts (name, phone_number, account_id, identifier, custom_attributes, created_at, updated_at)
 				SELECT 
 					COALESCE(NULLIF(TRIM(p.contact_name), ''), REPLACE(p.phone_number, '+', '')) as name,
 					p.phone_number,
 					$1,
 					CONCAT(REPLACE(p.phone_number, '+', ''), '@s.whatsapp.net') as identifier,
+					%s,
 					to_timestamp(CASE WHEN p.created_at > 10000000000 THEN p.created_at / 1000 ELSE p.created_at END),
 					to_timestamp(CASE WHEN p.last_activity_at > 10000000000 THEN p.last_activity_at / 1000 ELSE p.last_activity_at END)
 				FROM only_new_phone_number AS p
@@ -257,7 +371,7 @@ func (d *Database) CreateContactsAndConversations(
 						AND inbox_id = $2
 				)
 				RETURNING id, contact_id
-			)
+			)%s
 			SELECT new_contact.phone_number, new_conversation.contact_id, new_conversation.id AS conversation_id
 			FROM new_conversation 
 			JOIN new_contact ON new_conversation.contact_id = new_contact.id
@@ -273,7 +387,8 @@ func (d *Database) CreateContactsAndConversations(
 			WHERE NOT EXISTS (
 				SELECT 1 FROM new_conversation WHERE new_conversation.contact_id = c.id
 			)
-	`, strings.Join(values, ","))
+	`, strings.Join(values, ","),
+		identityAttributesSQL("CONCAT(REPLACE(p.phone_number, '+', ''), '@s.whatsapp.net')", "p.lid"), journalCTE)
 
 	// Preparar argumentos: account_id, inbox_id, depois os valores
 	args = append([]interface{}{d.cfg.Chatwoot.AccountID, inboxID}, args...)
@@ -281,7 +396,7 @@ func (d *Database) CreateContactsAndConversations(
 	log.Printf("CreateContactsAndConversations: Executing query for %d contacts (account_id=%d, inbox_id=%d)", 
 		len(contacts), d.cfg.Chatwoot.AccountID, inboxID)
 
-	rows, err := d.db.Query(query, args...)
+	rows, err := d.q.QueryContext(ctx, query, args...)
 	if err != nil {
 		log.Printf("CreateContactsAndConversations: Query failed: %v", err)
 		log.Printf("CreateContactsAndConversations: Query was: %s", query)
@@ -356,7 +471,7 @@ func (d *Database) CreateContactsAndConversations(
 			// Tentar buscar manualmente os contatos faltantes
 			for _, missingPhone := range missingPhones {
 				log.Printf("CreateContactsAndConversations: Attempting to find contact manually for phone: %s", missingPhone)
-				manualFK, err := d.findContactManually(missingPhone, inboxID)
+				manualFK, err := d.findContactManually(ctx, missingPhone, inboxID)
 				if err != nil {
 					log.Printf("CreateContactsAndConversations: Failed to find contact manually for %s: %v", missingPhone, err)
 				} else if manualFK != nil {
@@ -372,7 +487,7 @@ func (d *Database) CreateContactsAndConversations(
 						continue
 					}
 					
-					createdFK, err := d.createContactAndConversation(contactInfo, inboxID)
+					createdFK, err := d.createContactAndConversation(ctx, contactInfo, inboxID)
 					if err != nil {
 						log.Printf("CreateContactsAndConversations: Failed to create contact for %s: %v", missingPhone, err)
 					} else {
@@ -391,43 +506,53 @@ func (d *Database) CreateContactsAndConversations(
 	}
 
 	// Atualizar nomes dos contatos existentes que não têm nome ou têm apenas o número
+	if err := d.UpdateContactNames(ctx, contacts); err != nil {
+		log.Printf("Warning: failed to update existing contact names: %v", err)
+		// Não retornar erro, apenas logar
+	}
+
+	return result, nil
+}
+
+// UpdateContactNames atualiza o nome de contatos existentes que não têm nome ou têm apenas o número
+func (d *Database) UpdateContactNames(ctx context.Context, contacts []models.ChatwootContact) error {
+	if len(contacts) == 0 {
+		return nil
+	}
+
 	// Construir VALUES apenas com phone_number e contact_name
 	var updateValues []string
 	var updateArgs []interface{}
 	updateArgIndex := 2 // $1 = account_id, $2+ = valores
-	
+
 	for i := 0; i < len(contacts); i++ {
 		updateValues = append(updateValues, fmt.Sprintf("($%d, $%d)", updateArgIndex, updateArgIndex+1))
 		updateArgs = append(updateArgs, contacts[i].PhoneNumber, contacts[i].Name)
 		updateArgIndex += 2
 	}
-	
-	if len(updateValues) > 0 {
-		updateQuery := fmt.Sprintf(`
-			UPDATE contacts c
-			SET name = COALESCE(NULLIF(TRIM(p.contact_name), ''), c.name)
-			FROM (
-				VALUES %s
-			) as p (phone_number, contact_name)
-			WHERE c.phone_number = p.phone_number 
-				AND c.account_id = $1
-				AND NULLIF(TRIM(p.contact_name), '') IS NOT NULL
-				AND (c.name IS NULL OR c.name = '' OR c.name = REPLACE(c.phone_number, '+', ''))
-		`, strings.Join(updateValues, ","))
-		
-		updateArgs = append([]interface{}{d.cfg.Chatwoot.AccountID}, updateArgs...)
-		_, err = d.db.Exec(updateQuery, updateArgs...)
-		if err != nil {
-			log.Printf("Warning: failed to update existing contact names: %v", err)
-			// Não retornar erro, apenas logar
-		}
+
+	updateQuery := fmt.Sprintf(`
+		UPDATE contacts c
+		SET name = COALESCE(NULLIF(TRIM(p.contact_name), ''), c.name)
+		FROM (
+			VALUES %s
+		) as p (phone_number, contact_name)
+		WHERE c.phone_number = p.phone_number 
+			AND c.account_id = $1
+			AND NULLIF(TRIM(p.contact_name), '') IS NOT NULL
+			AND (c.name IS NULL OR c.name = '' OR c.name = REPLACE(c.phone_number, '+', ''))
+	`, strings.Join(updateValues, ","))
+
+	updateArgs = append([]interface{}{d.cfg.Chatwoot.AccountID}, updateArgs...)
+	if _, err := d.q.ExecContext(ctx, updateQuery, updateArgs...); err != nil {
+		return fmt.Errorf("failed to update contact names: %w", err)
 	}
 
-	return result, nil
+	return nil
 }
 
 // findContactManually busca um contato manualmente quando a query CTE não o retorna
-func (d *Database) findContactManually(phoneNumber string, inboxID int) (*models.ChatwootFKs, error) {
+func (d *Database) findContactManually(ctx context.Context, phoneNumber string, inboxID int) (*models.ChatwootFKs, error) {
 	log.Printf("findContactManually: Searching for contact with phone_number='%s', account_id=%d, inbox_id=%d", 
 		phoneNumber, d.cfg.Chatwoot.AccountID, inboxID)
 	
@@ -446,7 +571,7 @@ func (d *Database) findContactManually(phoneNumber string, inboxID int) (*models
 	`
 	
 	var contactID, conversationID sql.NullInt64
-	err := d.db.QueryRow(query, d.cfg.Chatwoot.AccountID, inboxID, phoneNumber).Scan(&contactID, &conversationID)
+	err := d.q.QueryRowContext(ctx, query, d.cfg.Chatwoot.AccountID, inboxID, phoneNumber).Scan(&contactID, &conversationID)
 	if err != nil {
 		if err == sql.ErrNoRows {
 			// Se não encontrou por phone_number, tentar buscar por identifier
@@ -466,7 +591,7 @@ func (d *Database) findContactManually(phoneNumber string, inboxID int) (*models
 				LIMIT 1
 			`
 			
-			err = d.db.QueryRow(queryByIdentifier, d.cfg.Chatwoot.AccountID, inboxID, identifier).Scan(&contactID, &conversationID)
+			err = d.q.QueryRowContext(ctx, queryByIdentifier, d.cfg.Chatwoot.AccountID, inboxID, identifier).Scan(&contactID, &conversationID)
 			if err != nil {
 				if err == sql.ErrNoRows {
 					log.Printf("findContactManually: Contact with phone_number='%s' or identifier='%s' not found in database", phoneNumber, identifier)
@@ -504,7 +629,7 @@ func (d *Database) findContactManually(phoneNumber string, inboxID int) (*models
 			// Buscar ou criar contact_inbox
 			var contactInboxID sql.NullInt64
 			ciQuery := `SELECT id FROM contact_inboxes WHERE contact_id = $1 AND inbox_id = $2 LIMIT 1`
-			err = d.db.QueryRow(ciQuery, fk.ContactID, inboxID).Scan(&contactInboxID)
+			err = d.q.QueryRowContext(ctx, ciQuery, fk.ContactID, inboxID).Scan(&contactInboxID)
 			if err != nil && err != sql.ErrNoRows {
 				return nil, fmt.Errorf("failed to query contact_inbox: %w", err)
 			}
@@ -513,17 +638,20 @@ func (d *Database) findContactManually(phoneNumber string, inboxID int) (*models
 				// Criar contact_inbox
 				ciInsert := `INSERT INTO contact_inboxes (contact_id, inbox_id, source_id, created_at, updated_at) 
 					VALUES ($1, $2, gen_random_uuid(), NOW(), NOW()) RETURNING id`
-				err = d.db.QueryRow(ciInsert, fk.ContactID, inboxID).Scan(&contactInboxID)
+				err = d.q.QueryRowContext(ctx, ciInsert, fk.ContactID, inboxID).Scan(&contactInboxID)
 				if err != nil {
 					return nil, fmt.Errorf("failed to create contact_inbox: %w", err)
 				}
+				if err := d.record(ctx, JournalContactInboxes, contactInboxID.Int64); err != nil {
+					return nil, err
+				}
 				log.Printf("findContactManually: Created contact_inbox %d for contact %d", contactInboxID.Int64, fk.ContactID)
 			}
 			
 			// Verificar se já existe conversa
 			var existingConvID sql.NullInt64
 			convCheck := `SELECT id FROM conversations WHERE contact_inbox_id = $1 AND account_id = $2 AND inbox_id = $3 LIMIT 1`
-			err = d.db.QueryRow(convCheck, contactInboxID.Int64, d.cfg.Chatwoot.AccountID, inboxID).Scan(&existingConvID)
+			err = d.q.QueryRowContext(ctx, convCheck, contactInboxID.Int64, d.cfg.Chatwoot.AccountID, inboxID).Scan(&existingConvID)
 			if err != nil && err != sql.ErrNoRows {
 				return nil, fmt.Errorf("failed to check conversation: %w", err)
 			}
@@ -535,11 +663,15 @@ func (d *Database) findContactManually(phoneNumber string, inboxID int) (*models
 				// Criar conversa
 				convInsert := `INSERT INTO conversations (account_id, inbox_id, status, contact_id, contact_inbox_id, uuid, last_activity_at, created_at, updated_at)
 					VALUES ($1, $2, 0, $3, $4, gen_random_uuid(), NOW(), NOW(), NOW()) RETURNING id`
-				err = d.db.QueryRow(convInsert, d.cfg.Chatwoot.AccountID, inboxID, fk.ContactID, contactInboxID.Int64).Scan(&conversationID)
+				err = d.q.QueryRowContext(ctx, convInsert, d.cfg.Chatwoot.AccountID, inboxID, fk.ContactID, contactInboxID.Int64).Scan(&conversationID)
 				if err != nil {
 					return nil, fmt.Errorf("failed to create conversation: %w", err)
 				}
 				
+				if err := d.record(ctx, JournalConversations, conversationID.Int64); err != nil {
+					return nil, err
+				}
+				
 				fk.ConversationID = int(conversationID.Int64)
 				log.Printf("findContactManually: Created conversation %d for contact %d", fk.ConversationID, fk.ContactID)
 		}
@@ -551,18 +683,12 @@ func (d *Database) findContactManually(phoneNumber string, inboxID int) (*models
 }
 
 // createContactAndConversation cria um contato e sua conversa quando ele não existe
-func (d *Database) createContactAndConversation(contact models.ChatwootContact, inboxID int) (*models.ChatwootFKs, error) {
+func (d *Database) createContactAndConversation(ctx context.Context, contact models.ChatwootContact, inboxID int) (*models.ChatwootFKs, error) {
 	log.Printf("createContactAndConversation: Creating contact for phone_number='%s', name='%s'", contact.PhoneNumber, contact.Name)
 	
 	// Converter timestamps
-	createdAt := contact.FirstTimestamp
-	if createdAt > 10000000000 {
-		createdAt = createdAt / 1000
-	}
-	updatedAt := contact.LastTimestamp
-	if updatedAt > 10000000000 {
-		updatedAt = updatedAt / 1000
-	}
+	createdAt := models.UnixSeconds(contact.FirstTimestamp)
+	updatedAt := models.UnixSeconds(contact.LastTimestamp)
 	
 	// Criar contato
 	contactName := contact.Name
@@ -578,7 +704,7 @@ func (d *Database) createContactAndConversation(contact models.ChatwootContact,
 	// Verificar se o contato já existe pelo identifier (devido à constraint única)
 	var existingContactID sql.NullInt64
 	checkQuery := `SELECT id FROM contacts WHERE identifier = $1 AND account_id = $2 LIMIT 1`
-	err := d.db.QueryRow(checkQuery, identifier, d.cfg.Chatwoot.AccountID).Scan(&existingContactID)
+	err := d.q.QueryRowContext(ctx, checkQuery, identifier, d.cfg.Chatwoot.AccountID).Scan(&existingContactID)
 	if err != nil && err != sql.ErrNoRows {
 		return nil, fmt.Errorf("failed to check existing contact: %w", err)
 	}
@@ -591,21 +717,25 @@ func (d *Database) createContactAndConversation(contact models.ChatwootContact,
 		
 		// Atualizar phone_number se necessário
 		updateQuery := `UPDATE contacts SET phone_number = $1, name = COALESCE(NULLIF(TRIM($2), ''), name) WHERE id = $3`
-		_, err = d.db.Exec(updateQuery, contact.PhoneNumber, contactName, contactID)
+		_, err = d.q.ExecContext(ctx, updateQuery, contact.PhoneNumber, contactName, contactID)
 		if err != nil {
 			log.Printf("createContactAndConversation: Warning - failed to update contact phone_number: %v", err)
 		}
 	} else {
 		// Criar novo contato
 		contactInsert := `
-			INSERT INTO contacts (name, phone_number, account_id, identifier, created_at, updated_at)
-			VALUES ($1, $2, $3, $4, to_timestamp($5), to_timestamp($6))
+			INSERT INTO contacts (name, phone_number, account_id, identifier, custom_attributes, created_at, updated_at)
+			VALUES ($1, $2, $3, $4, ` + identityAttributesSQL("$7", "$8") + `, to_timestamp($5), to_timestamp($6))
 			RETURNING id
 		`
-		err = d.db.QueryRow(contactInsert, contactName, contact.PhoneNumber, d.cfg.Chatwoot.AccountID, identifier, createdAt, updatedAt).Scan(&contactID)
+		err = d.q.QueryRowContext(ctx, contactInsert, contactName, contact.PhoneNumber, d.cfg.Chatwoot.AccountID, identifier, createdAt, updatedAt,
+			phoneJID(contact.PhoneNumber), contact.LID).Scan(&contactID)
 		if err != nil {
 			return nil, fmt.Errorf("failed to create contact: %w", err)
 		}
+		if err := d.record(ctx, JournalContacts, contactID); err != nil {
+			return nil, err
+		}
 		log.Printf("createContactAndConversation: Created contact_id=%d", contactID)
 	}
 	
@@ -613,20 +743,26 @@ func (d *Database) createContactAndConversation(contact models.ChatwootContact,
 	var contactInboxID int64
 	ciInsert := `INSERT INTO contact_inboxes (contact_id, inbox_id, source_id, created_at, updated_at) 
 		VALUES ($1, $2, gen_random_uuid(), to_timestamp($3), to_timestamp($4)) RETURNING id`
-	err = d.db.QueryRow(ciInsert, contactID, inboxID, createdAt, updatedAt).Scan(&contactInboxID)
+	err = d.q.QueryRowContext(ctx, ciInsert, contactID, inboxID, createdAt, updatedAt).Scan(&contactInboxID)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create contact_inbox: %w", err)
 	}
+	if err := d.record(ctx, JournalContactInboxes, contactInboxID); err != nil {
+		return nil, err
+	}
 	log.Printf("createContactAndConversation: Created contact_inbox_id=%d", contactInboxID)
 	
 	// Criar conversa
 	var conversationID int64
 	convInsert := `INSERT INTO conversations (account_id, inbox_id, status, contact_id, contact_inbox_id, uuid, last_activity_at, created_at, updated_at)
 		VALUES ($1, $2, 0, $3, $4, gen_random_uuid(), to_timestamp($5), to_timestamp($6), to_timestamp($7)) RETURNING id`
-	err = d.db.QueryRow(convInsert, d.cfg.Chatwoot.AccountID, inboxID, contactID, contactInboxID, updatedAt, createdAt, updatedAt).Scan(&conversationID)
+	err = d.q.QueryRowContext(ctx, convInsert, d.cfg.Chatwoot.AccountID, inboxID, contactID, contactInboxID, updatedAt, createdAt, updatedAt).Scan(&conversationID)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create conversation: %w", err)
 	}
+	if err := d.record(ctx, JournalConversations, conversationID); err != nil {
+		return nil, err
+	}
 	log.Printf("createContactAndConversation: Created conversation_id=%d", conversationID)
 	
 	return &models.ChatwootFKs{
@@ -637,14 +773,24 @@ func (d *Database) createContactAndConversation(contact models.ChatwootContact,
 }
 
 // CheckExistingMessages verifica quais mensagens já existem
-func (d *Database) CheckExistingMessages(sourceIDs []string, conversationID int) (map[string]bool, error) {
+func (d *Database) CheckExistingMessages(ctx context.Context, sourceIDs []string, conversationID int) (map[string]bool, error) {
 	if len(sourceIDs) == 0 {
 		return make(map[string]bool), nil
 	}
 
-	// Usar pq.Array para converter []string em array PostgreSQL
-	query := `SELECT source_id FROM messages WHERE source_id = ANY($1) AND conversation_id = $2`
-	rows, err := d.db.Query(query, pq.Array(sourceIDs), conversationID)
+	// Usar pq.Array para converter []string em array PostgreSQL.
+	// Mensagens enviadas pela ponte com vários anexos guardam os source_ids extras em content_attributes.
+	query := `
+		SELECT source_id FROM messages WHERE source_id = ANY($1) AND conversation_id = $2
+		UNION
+		SELECT bridged.source_id
+		FROM messages m,
+			json_array_elements_text(m.content_attributes::json -> 'bridged_source_ids') AS bridged(source_id)
+		WHERE m.conversation_id = $2
+			AND json_typeof(m.content_attributes::json -> 'bridged_source_ids') = 'array'
+			AND bridged.source_id = ANY($1)
+	`
+	rows, err := d.q.QueryContext(ctx, query, pq.Array(sourceIDs), conversationID)
 	if err != nil {
 		return nil, fmt.Errorf("failed to check existing messages: %w", err)
 	}
@@ -668,7 +814,7 @@ func (d *Database) CheckExistingMessages(sourceIDs []string, conversationID int)
 }
 
 // InsertMessages insere mensagens em lote
-func (d *Database) InsertMessages(messages []models.ChatwootMessage, inboxID int) (int, error) {
+func (d *Database) InsertMessages(ctx context.Context, messages []models.ChatwootMessage, inboxID int) (int, error) {
 	if len(messages) == 0 {
 		return 0, nil
 	}
@@ -735,8 +881,9 @@ func (d *Database) InsertMessages(messages []models.ChatwootMessage, inboxID int
 		}
 		
 		values = append(values, fmt.Sprintf(
-			"($%d, $%d, $%d, $%d, $%d, $%d, FALSE, 0, $%d, $%d, $%d, to_timestamp($%d), to_timestamp($%d))",
-			argIndex, argIndex+1, argIndex+2, argIndex+3, argIndex+4, argIndex+5, argIndex+6, argIndex+7, argIndex+8, argIndex+9, argIndex+10,
+			"($%d, $%d, $%d, $%d, $%d, $%d, FALSE, 0, $%d, $%d, $%d, to_timestamp($%d), to_timestamp($%d), $%d::json, $%d)",
+			argIndex, argIndex+1, argIndex+2, argIndex+3, argIndex+4, argIndex+5, argIndex+6, argIndex+7, argIndex+8, argIndex+9, argIndex+10, argIndex+11,
+			argIndex+12,
 		))
 		args = append(args,
 			msg.Content,
@@ -750,8 +897,10 @@ func (d *Database) InsertMessages(messages []models.ChatwootMessage, inboxID int
 			msg.SourceID,
 			timestampSeconds,
 			timestampSeconds,
+			replyAttributes(msg.InReplyTo),
+			msg.Status,
 		)
-		argIndex += 11
+		argIndex += 13
 
 		// Log primeira e última mensagem para debug
 		if i == 0 || i == len(messages)-1 {
@@ -764,18 +913,54 @@ func (d *Database) InsertMessages(messages []models.ChatwootMessage, inboxID int
 		INSERT INTO messages (
 			content, processed_message_content, account_id, inbox_id, conversation_id,
 			message_type, private, content_type, sender_type, sender_id, source_id,
-			created_at, updated_at
+			created_at, updated_at, content_attributes, status
 		) VALUES %s
+		RETURNING id, source_id
 	`, strings.Join(values, ","))
 
-	result, err := d.db.Exec(query, args...)
-	if err != nil {
-		return 0, fmt.Errorf("failed to insert messages: %w", err)
-	}
+	// Mensagens e anexos são gravados na mesma transação
+	count := 0
+	err := d.WithTx(ctx, func(tx *Database) error {
+		rows, err := tx.q.QueryContext(ctx, query, args...)
+		if err != nil {
+			return fmt.Errorf("failed to insert messages: %w", err)
+		}
+
+		messageIDs := make(map[string]int64, len(messages))
+		insertedIDs := make([]int64, 0, len(messages))
+		for rows.Next() {
+			var id int64
+			var sourceID string
+			if err := rows.Scan(&id, &sourceID); err != nil {
+				rows.Close()
+				return fmt.Errorf("failed to scan inserted message: %w", err)
+			}
+			messageIDs[sourceID] = id
+			insertedIDs = append(insertedIDs, id)
+			count++
+		}
+		if err := rows.Err(); err != nil {
+			rows.Close()
+			return fmt.Errorf("failed to insert messages: %w", err)
+		}
+		rows.Close()
+
+		if err := tx.record(ctx, JournalMessages, insertedIDs...); err != nil {
+			return err
+		}
 
-	count, err := result.RowsAffected()
+		for _, msg := range messages {
+			if msg.Attachment == nil {
+				continue
+			}
+			if err := tx.insertAttachment(ctx, messageIDs[msg.SourceID], msg.Attachment); err != nil {
+				return fmt.Errorf("failed to insert attachment for %s: %w", msg.SourceID, err)
+			}
+		}
+		return nil
+	})
 	if err != nil {
-		return 0, fmt.Errorf("failed to get rows affected: %w", err)
+		return 0, err
 	}
 
 	log.Printf("InsertMessages: Successfully inserted %d messages for conversation %d", 
@@ -785,17 +970,9 @@ func (d *Database) InsertMessages(messages []models.ChatwootMessage, inboxID int
 }
 
 // UpdateConversationLastActivity atualiza a última atividade da conversa
-func (d *Database) UpdateConversationLastActivity(conversationID int, timestamp int64) error {
-	// Verificar se o timestamp está em milissegundos ou segundos
-	var timestampSeconds int64
-	if timestamp > 10000000000 {
-		// Está em milissegundos, converter para segundos
-		timestampSeconds = timestamp / 1000
-	} else {
-		// Já está em segundos
-		timestampSeconds = timestamp
-	}
-	
+func (d *Database) UpdateConversationLastActivity(ctx context.Context, conversationID int, timestamp int64) error {
+	timestampSeconds := models.UnixSeconds(timestamp)
+
 	query := `
 		UPDATE conversations
 		SET 
@@ -804,7 +981,7 @@ func (d *Database) UpdateConversationLastActivity(conversationID int, timestamp
 		WHERE id = $2
 	`
 
-	_, err := d.db.Exec(query, timestampSeconds, conversationID)
+	_, err := d.q.ExecContext(ctx, query, timestampSeconds, conversationID)
 	if err != nil {
 		return fmt.Errorf("failed to update conversation activity: %w", err)
 	}
@@ -812,3 +989,92 @@ func (d *Database) UpdateConversationLastActivity(conversationID int, timestamp
 	return nil
 }
 
+
+// UpdateMessageTimestamp ajusta created_at/updated_at de uma mensagem para o horário original do WhatsApp
+func (d *Database) UpdateMessageTimestamp(ctx context.Context, sourceID string, conversationID int, timestamp int64) error {
+	timestampSeconds := models.UnixSeconds(timestamp)
+
+	query := `
+		UPDATE messages
+		SET created_at = to_timestamp($1), updated_at = to_timestamp($1)
+		WHERE source_id = $2 AND conversation_id = $3
+	`
+
+	_, err := d.q.ExecContext(ctx, query, timestampSeconds, sourceID, conversationID)
+	if err != nil {
+		return fmt.Errorf("failed to update message timestamp: %w", err)
+	}
+
+	return nil
+}
+
+// UpdateMessageSender define o remetente de uma mensagem criada via API (ex.: participante de grupo)
+func (d *Database) UpdateMessageSender(ctx context.Context, sourceID string, conversationID int, senderType string, senderID int) error {
+	query := `
+		UPDATE messages
+		SET sender_type = $1, sender_id = $2
+		WHERE source_id = $3 AND conversation_id = $4
+	`
+
+	_, err := d.q.ExecContext(ctx, query, senderType, senderID, sourceID, conversationID)
+	if err != nil {
+		return fmt.Errorf("failed to update message sender: %w", err)
+	}
+
+	return nil
+}
+
+// FindMessageRecipient busca o contato da conversa de uma mensagem enviada por um agente, conferindo
+// que a mensagem é pública, de saída, ainda sem source_id e pertence à conversa e ao inbox
+// informados. Retorna nil se a mensagem não atender a essas condições.
+func (d *Database) FindMessageRecipient(ctx context.Context, messageID, conversationID, inboxID int) (*models.ChatwootContact, error) {
+	query := `
+		SELECT COALESCE(c.identifier, ''), COALESCE(c.phone_number, ''),
+			COALESCE(c.custom_attributes->>'` + attrWhatsAppLID + `', '')
+		FROM messages m
+		JOIN conversations con ON con.id = m.conversation_id AND con.account_id = m.account_id
+		JOIN contacts c ON c.id = con.contact_id AND c.account_id = con.account_id
+		WHERE m.id = $1 AND m.conversation_id = $2 AND m.inbox_id = $3 AND m.account_id = $4
+			AND con.inbox_id = $3
+			AND m.message_type = 1
+			AND NOT COALESCE(m.private, false)
+			AND COALESCE(m.source_id, '') = ''
+	`
+	var contact models.ChatwootContact
+	err := d.q.QueryRowContext(ctx, query, messageID, conversationID, inboxID, d.cfg.Chatwoot.AccountID).
+		Scan(&contact.Identifier, &contact.PhoneNumber, &contact.LID)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to find recipient of message %d: %w", messageID, err)
+	}
+	return &contact, nil
+}
+
+// MarkMessageBridged grava na mensagem do Chatwoot os source_ids das mensagens enviadas ao WhatsApp,
+// para que a importação não traga essas mensagens de volta como duplicadas
+func (d *Database) MarkMessageBridged(ctx context.Context, messageID int, sourceIDs []string) error {
+	if len(sourceIDs) == 0 {
+		return nil
+	}
+
+	query := `
+		UPDATE messages
+		SET source_id = $1,
+			content_attributes = CASE
+				WHEN cardinality($2::text[]) = 0 THEN content_attributes
+				ELSE (COALESCE(content_attributes::jsonb, '{}'::jsonb)
+					|| jsonb_build_object('bridged_source_ids', to_jsonb($2::text[])))::json
+			END,
+			updated_at = NOW()
+		WHERE id = $3 AND account_id = $4
+	`
+
+	_, err := d.q.ExecContext(ctx, query, sourceIDs[0], pq.Array(sourceIDs[1:]), messageID, d.cfg.Chatwoot.AccountID)
+	if err != nil {
+		return fmt.Errorf("failed to update message source_id: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,159 @@
+package chatwoot
+
+import (
+	"chatwoot-sync-go/internal/models"
+	"context"
+	"fmt"
+	"log"
+	"strconv"
+
+	"github.com/lib/pq"
+)
+
+// FindDuplicateMessages lista os source_ids do WhatsApp (WAID:) que aparecem em mais de uma
+// mensagem do inbox, em qualquer conversa
+func (d *Database) FindDuplicateMessages(ctx context.Context, inboxID int) ([]models.DuplicateMessage, error) {
+	query := `
+		SELECT m.source_id, m.id, m.conversation_id, m.created_at
+		FROM messages m
+		WHERE m.account_id = $1 AND m.inbox_id = $2
+			AND m.source_id IN (
+				SELECT source_id
+				FROM messages
+				WHERE account_id = $1 AND inbox_id = $2 AND source_id LIKE 'WAID:%'
+				GROUP BY source_id
+				HAVING COUNT(*) > 1
+			)
+		ORDER BY m.source_id, m.created_at, m.id
+	`
+	rows, err := d.q.QueryContext(ctx, query, d.cfg.Chatwoot.AccountID, inboxID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to find duplicate messages: %w", err)
+	}
+	defer rows.Close()
+
+	var duplicates []models.DuplicateMessage
+	for rows.Next() {
+		var sourceID string
+		var messageCopy models.MessageCopy
+		if err := rows.Scan(&sourceID, &messageCopy.MessageID, &messageCopy.ConversationID, &messageCopy.CreatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan duplicate message: %w", err)
+		}
+
+		if n := len(duplicates); n == 0 || duplicates[n-1].SourceID != sourceID {
+			duplicates = append(duplicates, models.DuplicateMessage{SourceID: sourceID})
+		}
+		last := &duplicates[len(duplicates)-1]
+		last.Copies = append(last.Copies, messageCopy)
+	}
+
+	return duplicates, rows.Err()
+}
+
+// DeleteDuplicateMessages apaga as cópias extras (chaves de keptIDs) em uma transação. As respostas
+// que citam uma cópia extra (in_reply_to), sempre na conversa dela, passam antes a citar a cópia
+// mantida do mesmo source_id.
+func (d *Database) DeleteDuplicateMessages(ctx context.Context, keptIDs map[int64]int64) (int, error) {
+	if len(keptIDs) == 0 {
+		return 0, nil
+	}
+
+	extraIDs := make([]int64, 0, len(keptIDs))
+	keptByExtra := make([]int64, 0, len(keptIDs))
+	for extraID, keptID := range keptIDs {
+		extraIDs = append(extraIDs, extraID)
+		keptByExtra = append(keptByExtra, keptID)
+	}
+
+	deleted := 0
+	err := d.WithTx(ctx, func(tx *Database) error {
+		repoint := `
+			UPDATE messages m
+			SET content_attributes = (m.content_attributes::jsonb || jsonb_build_object('` + attrInReplyTo + `', r.kept_id))::json
+			FROM unnest($1::BIGINT[], $2::BIGINT[]) AS r(extra_id, kept_id)
+			WHERE m.account_id = $3
+				AND m.conversation_id IN (SELECT conversation_id FROM messages WHERE id = ANY($1))
+				AND m.content_attributes::jsonb ->> '` + attrInReplyTo + `' = r.extra_id::TEXT
+		`
+		result, err := tx.q.ExecContext(ctx, repoint, pq.Array(extraIDs), pq.Array(keptByExtra), d.cfg.Chatwoot.AccountID)
+		if err != nil {
+			return fmt.Errorf("failed to repoint replies to kept messages: %w", err)
+		}
+		if repointed, _ := result.RowsAffected(); repointed > 0 {
+			log.Printf("DeleteDuplicateMessages: Repointed %d replies to the kept copies", repointed)
+		}
+
+		deleted, err = tx.DeleteMessages(ctx, extraIDs)
+		return err
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	return deleted, nil
+}
+
+// DeleteMessages apaga as mensagens e seus anexos em uma transação, junto com os blobs do
+// ActiveStorage que ficarem sem uso e seus arquivos (veja deleteUnusedBlobs). Respostas
+// que citam uma das mensagens perdem o in_reply_to, mas mantêm o source_id citado, e voltam a ser
+// resolvidas por LinkReplies se a mensagem for importada de novo.
+func (d *Database) DeleteMessages(ctx context.Context, messageIDs []int64) (int, error) {
+	if len(messageIDs) == 0 {
+		return 0, nil
+	}
+
+	deleted := 0
+	err := d.WithTx(ctx, func(tx *Database) error {
+		unlink := `
+			UPDATE messages
+			SET content_attributes = (content_attributes::jsonb - '` + attrInReplyTo + `')::json
+			WHERE account_id = $2
+				AND conversation_id IN (SELECT conversation_id FROM messages WHERE id = ANY($1))
+				AND NOT id = ANY($1)
+				AND (content_attributes::jsonb ->> '` + attrInReplyTo + `') = ANY($3)
+		`
+		textIDs := make([]string, 0, len(messageIDs))
+		for _, id := range messageIDs {
+			textIDs = append(textIDs, strconv.FormatInt(id, 10))
+		}
+		if _, err := tx.q.ExecContext(ctx, unlink, pq.Array(messageIDs), d.cfg.Chatwoot.AccountID, pq.Array(textIDs)); err != nil {
+			return fmt.Errorf("failed to unlink replies to deleted messages: %w", err)
+		}
+
+		blobLinks := `
+			DELETE FROM active_storage_attachments asa
+			USING attachments a
+			WHERE asa.record_type = 'Attachment' AND asa.record_id = a.id AND a.message_id = ANY($1)
+			RETURNING asa.blob_id
+		`
+		blobIDs, err := tx.queryIDs(ctx, blobLinks, pq.Array(messageIDs))
+		if err != nil {
+			return fmt.Errorf("failed to delete blob attachments: %w", err)
+		}
+
+		if _, err := tx.q.ExecContext(ctx, `DELETE FROM attachments WHERE message_id = ANY($1)`, pq.Array(messageIDs)); err != nil {
+			return fmt.Errorf("failed to delete attachments: %w", err)
+		}
+
+		if err := tx.deleteUnusedBlobs(ctx, blobIDs); err != nil {
+			return err
+		}
+
+		result, err := tx.q.ExecContext(ctx, `DELETE FROM messages WHERE id = ANY($1) AND account_id = $2`,
+			pq.Array(messageIDs), d.cfg.Chatwoot.AccountID)
+		if err != nil {
+			return fmt.Errorf("failed to delete messages: %w", err)
+		}
+		affected, err := result.RowsAffected()
+		if err != nil {
+			return fmt.Errorf("failed to count deleted messages: %w", err)
+		}
+		deleted = int(affected)
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	return deleted, nil
+}
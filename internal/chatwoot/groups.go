@@ -0,0 +1,172 @@
+package chatwoot
+
+import (
+	"chatwoot-sync-go/internal/models"
+	"context"
+	"database/sql"
+	"fmt"
+	"log"
+	"strings"
+)
+
+// CreateGroupConversation busca ou cria o contato que representa o grupo e sua conversa no inbox.
+// O contato do grupo é identificado pelo JID do grupo ({id}@g.us) e não tem telefone.
+func (d *Database) CreateGroupConversation(ctx context.Context, group models.ChatwootContact, inboxID int) (*models.ChatwootFKs, error) {
+	createdAt := models.UnixSeconds(group.FirstTimestamp)
+	updatedAt := models.UnixSeconds(group.LastTimestamp)
+
+	groupName := strings.TrimSpace(group.Name)
+	if groupName == "" {
+		groupName = group.Identifier
+	}
+
+	if err := d.lockContacts(ctx, []models.ChatwootContact{{Identifier: group.Identifier}}); err != nil {
+		return nil, err
+	}
+
+	var contactID int64
+	checkQuery := `SELECT id FROM contacts WHERE identifier = $1 AND account_id = $2 LIMIT 1`
+	err := d.q.QueryRowContext(ctx, checkQuery, group.Identifier, d.cfg.Chatwoot.AccountID).Scan(&contactID)
+	switch {
+	case err == sql.ErrNoRows:
+		contactInsert := `
+			INSERT INTO contacts (name, account_id, identifier, created_at, updated_at)
+			VALUES ($1, $2, $3, to_timestamp($4), to_timestamp($5))
+			RETURNING id
+		`
+		err = d.q.QueryRowContext(ctx, contactInsert, groupName, d.cfg.Chatwoot.AccountID, group.Identifier, createdAt, updatedAt).Scan(&contactID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create group contact: %w", err)
+		}
+		if err := d.record(ctx, JournalContacts, contactID); err != nil {
+			return nil, err
+		}
+		log.Printf("CreateGroupConversation: Created contact_id=%d for group %s", contactID, group.Identifier)
+	case err != nil:
+		return nil, fmt.Errorf("failed to check existing group contact: %w", err)
+	default:
+		// Grupos podem ser renomeados, manter o nome atual
+		if err := d.UpdateGroupName(ctx, int(contactID), groupName); err != nil {
+			log.Printf("CreateGroupConversation: Warning - %v", err)
+		}
+	}
+
+	conversationID, err := d.ensureConversation(ctx, contactID, inboxID, createdAt, updatedAt)
+	if err != nil {
+		return nil, err
+	}
+
+	return &models.ChatwootFKs{
+		ContactID:      int(contactID),
+		ConversationID: int(conversationID),
+	}, nil
+}
+
+// UpdateGroupName atualiza o nome do contato do grupo, se ele foi renomeado
+func (d *Database) UpdateGroupName(ctx context.Context, contactID int, name string) error {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return nil
+	}
+
+	updateQuery := `UPDATE contacts SET name = $1, updated_at = NOW() WHERE id = $2 AND account_id = $3 AND name IS DISTINCT FROM $1`
+	if _, err := d.q.ExecContext(ctx, updateQuery, name, contactID, d.cfg.Chatwoot.AccountID); err != nil {
+		return fmt.Errorf("failed to update group name: %w", err)
+	}
+	return nil
+}
+
+// EnsureContacts busca ou cria contatos sem conversa (ex.: participantes de grupos).
+// Retorna um mapa identifier -> contact_id.
+func (d *Database) EnsureContacts(ctx context.Context, contacts []models.ChatwootContact) (map[string]int, error) {
+	result := make(map[string]int, len(contacts))
+
+	if err := d.lockContacts(ctx, contacts); err != nil {
+		return nil, err
+	}
+
+	for _, contact := range contacts {
+		if _, done := result[contact.Identifier]; done {
+			continue
+		}
+
+		// Participante com telefone e LID: contatos criados antes só com o LID passam a ser o do telefone
+		if err := d.LinkContactLID(ctx, contact); err != nil {
+			return nil, err
+		}
+
+		contactID, err := d.findContactID(ctx, contact)
+		if err != nil {
+			return nil, err
+		}
+
+		if contactID == 0 {
+			contactName := strings.TrimSpace(contact.Name)
+			if contactName == "" {
+				contactName = strings.TrimPrefix(contact.PhoneNumber, "+")
+			}
+			if contactName == "" {
+				contactName = contact.Identifier
+			}
+
+			contactInsert := `
+				INSERT INTO contacts (name, phone_number, account_id, identifier, custom_attributes, created_at, updated_at)
+				VALUES ($1, NULLIF($2, ''), $3, $4, ` + identityAttributesSQL("$5", "$6") + `, NOW(), NOW())
+				RETURNING id
+			`
+			err = d.q.QueryRowContext(ctx, contactInsert, contactName, contact.PhoneNumber, d.cfg.Chatwoot.AccountID, contact.Identifier,
+				phoneJID(contact.PhoneNumber), contact.LID).Scan(&contactID)
+			if err != nil {
+				return nil, fmt.Errorf("failed to create contact %s: %w", contact.Identifier, err)
+			}
+			if err := d.record(ctx, JournalContacts, contactID); err != nil {
+				return nil, err
+			}
+			log.Printf("EnsureContacts: Created contact_id=%d for %s", contactID, contact.Identifier)
+		}
+
+		result[contact.Identifier] = int(contactID)
+	}
+
+	return result, nil
+}
+
+// ensureConversation busca ou cria o contact_inbox e a conversa mais recente do contato no inbox
+func (d *Database) ensureConversation(ctx context.Context, contactID int64, inboxID int, createdAt, updatedAt int64) (int64, error) {
+	var contactInboxID int64
+	ciQuery := `SELECT id FROM contact_inboxes WHERE contact_id = $1 AND inbox_id = $2 LIMIT 1`
+	err := d.q.QueryRowContext(ctx, ciQuery, contactID, inboxID).Scan(&contactInboxID)
+	if err == sql.ErrNoRows {
+		ciInsert := `INSERT INTO contact_inboxes (contact_id, inbox_id, source_id, created_at, updated_at)
+			VALUES ($1, $2, gen_random_uuid(), to_timestamp($3), to_timestamp($4)) RETURNING id`
+		err = d.q.QueryRowContext(ctx, ciInsert, contactID, inboxID, createdAt, updatedAt).Scan(&contactInboxID)
+		if err != nil {
+			return 0, fmt.Errorf("failed to create contact_inbox: %w", err)
+		}
+		if err := d.record(ctx, JournalContactInboxes, contactInboxID); err != nil {
+			return 0, err
+		}
+	} else if err != nil {
+		return 0, fmt.Errorf("failed to query contact_inbox: %w", err)
+	}
+
+	var conversationID int64
+	convQuery := `SELECT id FROM conversations WHERE contact_inbox_id = $1 AND account_id = $2 AND inbox_id = $3 ORDER BY id DESC LIMIT 1`
+	err = d.q.QueryRowContext(ctx, convQuery, contactInboxID, d.cfg.Chatwoot.AccountID, inboxID).Scan(&conversationID)
+	if err == sql.ErrNoRows {
+		convInsert := `INSERT INTO conversations (account_id, inbox_id, status, contact_id, contact_inbox_id, uuid, last_activity_at, created_at, updated_at)
+			VALUES ($1, $2, 0, $3, $4, gen_random_uuid(), to_timestamp($5), to_timestamp($6), to_timestamp($5)) RETURNING id`
+		err = d.q.QueryRowContext(ctx, convInsert, d.cfg.Chatwoot.AccountID, inboxID, contactID, contactInboxID, updatedAt, createdAt).Scan(&conversationID)
+		if err != nil {
+			return 0, fmt.Errorf("failed to create conversation: %w", err)
+		}
+		if err := d.record(ctx, JournalConversations, conversationID); err != nil {
+			return 0, err
+		}
+		log.Printf("ensureConversation: Created conversation_id=%d for contact %d", conversationID, contactID)
+	} else if err != nil {
+		return 0, fmt.Errorf("failed to query conversation: %w", err)
+	}
+
+	return conversationID, nil
+}
@@ -0,0 +1,351 @@
+package chatwoot
+
+import (
+	"chatwoot-sync-go/internal/models"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log"
+	"strings"
+)
+
+// Atributos personalizados do contato com as identidades do WhatsApp. O identifier guarda o JID
+// de telefone quando ele é conhecido e o LID (@lid) apenas para contatos sem telefone.
+const (
+	attrWhatsAppPN  = "whatsapp_pn"
+	attrWhatsAppLID = "whatsapp_lid"
+)
+
+// identityAttributesSQL monta o JSON de atributos a partir do JID de telefone e do LID
+// (parâmetros de texto; valores vazios são omitidos)
+func identityAttributesSQL(pnParam, lidParam string) string {
+	return fmt.Sprintf(`jsonb_strip_nulls(jsonb_build_object('%s', NULLIF(%s, ''), '%s', NULLIF(%s, '')))`,
+		attrWhatsAppPN, pnParam, attrWhatsAppLID, lidParam)
+}
+
+// phoneJID retorna o JID de telefone ({número}@s.whatsapp.net) de um número em E.164
+func phoneJID(phoneNumber string) string {
+	if phoneNumber == "" {
+		return ""
+	}
+	return strings.TrimPrefix(phoneNumber, "+") + "@s.whatsapp.net"
+}
+
+// FindLIDConversation busca, sem gravar nada, o contato com o LID informado e sua conversa mais
+// recente no inbox. Retorna nil se o contato não existe.
+func (d *Database) FindLIDConversation(ctx context.Context, lid string, inboxID int) (*models.ChatwootExistingContact, error) {
+	query := `
+		SELECT c.id, COALESCE(c.name, ''), COALESCE(MAX(con.id), 0)
+		FROM contacts c
+		LEFT JOIN contact_inboxes ci ON ci.contact_id = c.id AND ci.inbox_id = $3
+		LEFT JOIN conversations con ON con.contact_inbox_id = ci.id
+			AND con.account_id = $1
+			AND con.inbox_id = $3
+		WHERE c.account_id = $1 AND (c.identifier = $2 OR c.custom_attributes->>'` + attrWhatsAppLID + `' = $2)
+		GROUP BY c.id, c.name
+		ORDER BY (c.identifier = $2) DESC, c.id
+		LIMIT 1
+	`
+
+	var existing models.ChatwootExistingContact
+	err := d.q.QueryRowContext(ctx, query, d.cfg.Chatwoot.AccountID, lid, inboxID).
+		Scan(&existing.ContactID, &existing.Name, &existing.ConversationID)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to find contact by LID: %w", err)
+	}
+
+	return &existing, nil
+}
+
+// CreateLIDConversation busca ou cria o contato conhecido apenas pelo LID (sem telefone) e sua
+// conversa no inbox
+func (d *Database) CreateLIDConversation(ctx context.Context, contact models.ChatwootContact, inboxID int) (*models.ChatwootFKs, error) {
+	createdAt := models.UnixSeconds(contact.FirstTimestamp)
+	updatedAt := models.UnixSeconds(contact.LastTimestamp)
+
+	if err := d.lockContacts(ctx, []models.ChatwootContact{{Identifier: contact.LID}}); err != nil {
+		return nil, err
+	}
+
+	contactID, err := d.findContactIDByLID(ctx, contact.LID)
+	if err != nil {
+		return nil, err
+	}
+	if contactID == 0 {
+		name := strings.TrimSpace(contact.Name)
+		if name == "" {
+			name = strings.TrimSuffix(contact.LID, "@lid")
+		}
+		contactInsert := `
+			INSERT INTO contacts (name, account_id, identifier, custom_attributes, created_at, updated_at)
+			VALUES ($1, $2, $3, ` + identityAttributesSQL("''", "$3") + `, to_timestamp($4), to_timestamp($5))
+			RETURNING id
+		`
+		err = d.q.QueryRowContext(ctx, contactInsert, name, d.cfg.Chatwoot.AccountID, contact.LID, createdAt, updatedAt).Scan(&contactID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create LID contact: %w", err)
+		}
+		if err := d.record(ctx, JournalContacts, contactID); err != nil {
+			return nil, err
+		}
+		log.Printf("CreateLIDConversation: Created contact_id=%d for %s", contactID, contact.LID)
+	}
+
+	conversationID, err := d.ensureConversation(ctx, contactID, inboxID, createdAt, updatedAt)
+	if err != nil {
+		return nil, err
+	}
+
+	return &models.ChatwootFKs{
+		ContactID:      int(contactID),
+		ConversationID: int(conversationID),
+	}, nil
+}
+
+// findContactIDByLID busca o contato pelo LID no identifier ou nos atributos, retornando 0 se não existir
+func (d *Database) findContactIDByLID(ctx context.Context, lid string) (int64, error) {
+	var contactID int64
+	query := `
+		SELECT id FROM contacts
+		WHERE account_id = $1 AND (identifier = $2 OR custom_attributes->>'` + attrWhatsAppLID + `' = $2)
+		ORDER BY (identifier = $2) DESC, id
+		LIMIT 1
+	`
+	err := d.q.QueryRowContext(ctx, query, d.cfg.Chatwoot.AccountID, lid).Scan(&contactID)
+	if err == sql.ErrNoRows {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, fmt.Errorf("failed to query contact %s: %w", lid, err)
+	}
+	return contactID, nil
+}
+
+// LinkContactLID associa o LID ao contato do telefone. Se o LID já pertence a contatos criados
+// antes de o telefone ser conhecido, eles são mesclados no contato do telefone; se só existe o
+// contato do LID, ele passa a ser o contato do telefone.
+func (d *Database) LinkContactLID(ctx context.Context, contact models.ChatwootContact) error {
+	if contact.LID == "" || contact.PhoneNumber == "" {
+		return nil
+	}
+
+	return d.WithTx(ctx, func(tx *Database) error {
+		locks := []models.ChatwootContact{contact, {Identifier: contact.LID}}
+		if err := tx.lockContacts(ctx, locks); err != nil {
+			return err
+		}
+
+		pnJID := phoneJID(contact.PhoneNumber)
+		phoneContactID, err := tx.findContactID(ctx, models.ChatwootContact{Identifier: pnJID, PhoneNumber: contact.PhoneNumber})
+		if err != nil {
+			return err
+		}
+
+		lidContactIDs, err := tx.lidContactIDs(ctx, contact.LID, phoneContactID)
+		if err != nil {
+			return err
+		}
+
+		keepID := phoneContactID
+		if keepID == 0 {
+			if len(lidContactIDs) == 0 {
+				return nil
+			}
+			keepID, lidContactIDs = lidContactIDs[0], lidContactIDs[1:]
+		}
+
+		for _, mergeID := range lidContactIDs {
+			if err := tx.MergeContacts(ctx, keepID, mergeID); err != nil {
+				return err
+			}
+		}
+
+		update := `
+			UPDATE contacts
+			SET phone_number = $2,
+				identifier = $3,
+				custom_attributes = COALESCE(custom_attributes, '{}'::jsonb) || ` + identityAttributesSQL("$3", "$4") + `,
+				updated_at = NOW()
+			WHERE id = $1
+				AND (phone_number IS DISTINCT FROM $2
+					OR identifier IS DISTINCT FROM $3
+					OR custom_attributes->>'` + attrWhatsAppLID + `' IS DISTINCT FROM $4
+					OR custom_attributes->>'` + attrWhatsAppPN + `' IS DISTINCT FROM $3)
+		`
+		result, err := tx.q.ExecContext(ctx, update, keepID, contact.PhoneNumber, pnJID, contact.LID)
+		if err != nil {
+			return fmt.Errorf("failed to link LID %s to contact %d: %w", contact.LID, keepID, err)
+		}
+		if affected, _ := result.RowsAffected(); affected > 0 {
+			log.Printf("LinkContactLID: Contact %d linked to %s and %s", keepID, pnJID, contact.LID)
+		}
+		return nil
+	})
+}
+
+// lidContactIDs lista os contatos com o LID informado, exceto exceptID, do mais antigo para o mais novo
+func (d *Database) lidContactIDs(ctx context.Context, lid string, exceptID int64) ([]int64, error) {
+	query := `
+		SELECT id FROM contacts
+		WHERE account_id = $1 AND id <> $3
+			AND (identifier = $2 OR custom_attributes->>'` + attrWhatsAppLID + `' = $2)
+		ORDER BY id
+	`
+	rows, err := d.q.QueryContext(ctx, query, d.cfg.Chatwoot.AccountID, lid, exceptID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to find contacts by LID: %w", err)
+	}
+	defer rows.Close()
+
+	var ids []int64
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			return nil, fmt.Errorf("failed to scan contact: %w", err)
+		}
+		ids = append(ids, id)
+	}
+	return ids, rows.Err()
+}
+
+// contactMerge é o registro no diário de uma mescla: os dois contatos antes dela (linhas de
+// contacts em JSON), os IDs das linhas movidas do contato apagado para o mantido e os blobs da
+// foto do contato apagado
+type contactMerge struct {
+	KeptID         int64           `json:"kept_id"`
+	Kept           json.RawMessage `json:"kept"`
+	Merged         json.RawMessage `json:"merged"`
+	Conversations  []int64         `json:"conversations"`
+	ContactInboxes []int64         `json:"contact_inboxes"`
+	Messages       []int64         `json:"messages"`
+	Notes          []int64         `json:"notes"`
+	AvatarBlobIDs  []int64         `json:"avatar_blob_ids,omitempty"`
+}
+
+// contactMove é uma das alterações que passam as linhas de um contato para outro; ids recebe as
+// linhas movidas
+type contactMove struct {
+	query string
+	ids   *[]int64
+}
+
+// MergeContacts move conversas, contact_inboxes, mensagens enviadas e notas do contato mergeID
+// para keepID e apaga mergeID com sua foto. Atributos e nome do contato mantido têm prioridade. A mescla é
+// registrada no diário da execução, com o que é preciso para o rollback desfazê-la.
+func (d *Database) MergeContacts(ctx context.Context, keepID, mergeID int64) error {
+	if keepID == mergeID {
+		return nil
+	}
+
+	return d.WithTx(ctx, func(tx *Database) error {
+		var hasNotes bool
+		if err := tx.q.QueryRowContext(ctx, `SELECT to_regclass('notes') IS NOT NULL`).Scan(&hasNotes); err != nil {
+			return fmt.Errorf("failed to check notes table: %w", err)
+		}
+
+		merge := contactMerge{KeptID: keepID}
+		var kept string
+		keptQuery := `SELECT to_jsonb(c)::text FROM contacts c WHERE id = $1 AND account_id = $2`
+		if err := tx.q.QueryRowContext(ctx, keptQuery, keepID, d.cfg.Chatwoot.AccountID).Scan(&kept); err != nil {
+			return fmt.Errorf("failed to read contact %d: %w", keepID, err)
+		}
+		merge.Kept = json.RawMessage(kept)
+
+		moves := []contactMove{
+			{`UPDATE conversations SET contact_id = $1 WHERE contact_id = $2 AND account_id = $3 RETURNING id`, &merge.Conversations},
+			{`
+				UPDATE contact_inboxes ci SET contact_id = $1
+				FROM inboxes i
+				WHERE ci.contact_id = $2 AND i.id = ci.inbox_id AND i.account_id = $3
+				RETURNING ci.id
+			`, &merge.ContactInboxes},
+			{`UPDATE messages SET sender_id = $1 WHERE sender_type = 'Contact' AND sender_id = $2 AND account_id = $3 RETURNING id`, &merge.Messages},
+		}
+		if hasNotes {
+			moves = append(moves, contactMove{`UPDATE notes SET contact_id = $1 WHERE contact_id = $2 AND account_id = $3 RETURNING id`, &merge.Notes})
+		}
+		for _, move := range moves {
+			ids, err := tx.queryIDs(ctx, move.query, keepID, mergeID, d.cfg.Chatwoot.AccountID)
+			if err != nil {
+				return fmt.Errorf("failed to merge contact %d into %d: %w", mergeID, keepID, err)
+			}
+			*move.ids = ids
+		}
+
+		// O contato mesclado é apagado antes de copiar o email, que é único na conta
+		var name, email sql.NullString
+		var customAttributes, additionalAttributes sql.NullString
+		var merged string
+		deleteQuery := `
+			DELETE FROM contacts WHERE id = $1 AND account_id = $2
+			RETURNING name, email, custom_attributes::text, additional_attributes::text, to_jsonb(contacts)::text
+		`
+		err := tx.q.QueryRowContext(ctx, deleteQuery, mergeID, d.cfg.Chatwoot.AccountID).
+			Scan(&name, &email, &customAttributes, &additionalAttributes, &merged)
+		if err == sql.ErrNoRows {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("failed to delete merged contact %d: %w", mergeID, err)
+		}
+		merge.Merged = json.RawMessage(merged)
+
+		// A foto do contato apagado não fica órfã: o anexo sai junto e o blob, se nada mais o usa
+		avatarQuery := `
+			DELETE FROM active_storage_attachments
+			WHERE record_type = 'Contact' AND name = 'avatar' AND record_id = $1
+			RETURNING blob_id
+		`
+		merge.AvatarBlobIDs, err = tx.queryIDs(ctx, avatarQuery, mergeID)
+		if err != nil {
+			return fmt.Errorf("failed to remove avatar of merged contact %d: %w", mergeID, err)
+		}
+		if err := tx.deleteUnusedBlobs(ctx, merge.AvatarBlobIDs); err != nil {
+			return fmt.Errorf("failed to delete avatar blob of merged contact %d: %w", mergeID, err)
+		}
+
+		update := `
+			UPDATE contacts SET
+				name = CASE WHEN COALESCE(TRIM(name), '') = '' OR name = REPLACE(COALESCE(phone_number, ''), '+', '')
+					THEN COALESCE(NULLIF(TRIM($2), ''), name) ELSE name END,
+				email = COALESCE(email, $3),
+				custom_attributes = COALESCE($4::jsonb, '{}'::jsonb) || COALESCE(custom_attributes, '{}'::jsonb),
+				additional_attributes = COALESCE($5::jsonb, '{}'::jsonb) || COALESCE(additional_attributes, '{}'::jsonb),
+				updated_at = NOW()
+			WHERE id = $1
+		`
+		if _, err := tx.q.ExecContext(ctx, update, keepID, name, email, customAttributes, additionalAttributes); err != nil {
+			return fmt.Errorf("failed to update merged contact %d: %w", keepID, err)
+		}
+
+		if err := tx.recordDetails(ctx, JournalContactMerges, mergeID, merge); err != nil {
+			return err
+		}
+
+		log.Printf("MergeContacts: Contact %d merged into %d", mergeID, keepID)
+		return nil
+	})
+}
+
+// queryIDs executa a consulta (ou alteração com RETURNING) e retorna os IDs da primeira coluna
+func (d *Database) queryIDs(ctx context.Context, query string, args ...interface{}) ([]int64, error) {
+	rows, err := d.q.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	ids := []int64{}
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, rows.Err()
+}
@@ -0,0 +1,567 @@
+package chatwoot
+
+import (
+	"chatwoot-sync-go/internal/models"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+
+	"github.com/lib/pq"
+)
+
+// Tabelas cujas linhas criadas pela sincronização são registradas no diário
+const (
+	JournalContacts       = "contacts"
+	JournalContactInboxes = "contact_inboxes"
+	JournalConversations  = "conversations"
+	JournalMessages       = "messages"
+)
+
+// Alterações em linhas que já existiam, registradas no diário com os dados para desfazê-las
+// (details): mesclas de contatos, com record_id igual ao contato apagado, e trocas de foto, com
+// record_id igual ao contato
+const (
+	JournalContactMerges  = "contact_merges"
+	JournalContactAvatars = "contact_avatars"
+)
+
+// JournalChanges lista as alterações do diário; o rollback as desfaz da mais recente para a mais antiga
+var JournalChanges = []string{JournalContactMerges, JournalContactAvatars}
+
+// JournalTables lista as tabelas do diário na ordem em que o rollback apaga as linhas
+var JournalTables = []string{JournalMessages, JournalConversations, JournalContactInboxes, JournalContacts}
+
+// errRollbackDryRun desfaz a transação do rollback quando ele apenas simula a remoção
+var errRollbackDryRun = errors.New("rollback dry-run")
+
+// runJournal guarda a execução atual; é compartilhado pelas cópias de Database criadas em WithTx
+type runJournal struct {
+	mutex sync.RWMutex
+	runID string
+}
+
+func (j *runJournal) get() string {
+	j.mutex.RLock()
+	defer j.mutex.RUnlock()
+	return j.runID
+}
+
+func (j *runJournal) set(runID string) {
+	j.mutex.Lock()
+	defer j.mutex.Unlock()
+	j.runID = runID
+}
+
+// EnsureJournalTables cria as tabelas de execuções e do diário, se elas não existirem
+func (d *Database) EnsureJournalTables(ctx context.Context) error {
+	queries := []string{`
+		CREATE TABLE IF NOT EXISTS chatwoot_sync_runs (
+			run_id TEXT PRIMARY KEY,
+			account_id INTEGER NOT NULL,
+			inbox_id INTEGER NOT NULL,
+			started_at TIMESTAMP NOT NULL DEFAULT NOW(),
+			rolled_back_at TIMESTAMP
+		)
+	`, `
+		CREATE TABLE IF NOT EXISTS chatwoot_sync_journal (
+			run_id TEXT NOT NULL,
+			table_name TEXT NOT NULL,
+			record_id BIGINT NOT NULL,
+			created_at TIMESTAMP NOT NULL DEFAULT NOW()
+		)
+	`,
+		`ALTER TABLE chatwoot_sync_journal ADD COLUMN IF NOT EXISTS details JSONB`,
+		`ALTER TABLE chatwoot_sync_journal ADD COLUMN IF NOT EXISTS seq BIGSERIAL`,
+		`CREATE INDEX IF NOT EXISTS chatwoot_sync_journal_run_idx ON chatwoot_sync_journal (run_id, table_name)`}
+
+	for _, query := range queries {
+		if _, err := d.q.ExecContext(ctx, query); err != nil {
+			return fmt.Errorf("failed to create journal tables: %w", err)
+		}
+	}
+	return nil
+}
+
+// hasJournalTables indica se as tabelas do diário já existem
+func (d *Database) hasJournalTables(ctx context.Context) (bool, error) {
+	var exists bool
+	query := `SELECT to_regclass('chatwoot_sync_runs') IS NOT NULL AND to_regclass('chatwoot_sync_journal') IS NOT NULL`
+	if err := d.q.QueryRowContext(ctx, query).Scan(&exists); err != nil {
+		return false, fmt.Errorf("failed to check journal tables: %w", err)
+	}
+	return exists, nil
+}
+
+// StartRun registra uma nova execução; a partir daí, as linhas criadas por d (e pelas
+// transações abertas a partir dele) são registradas no diário com o runID
+func (d *Database) StartRun(ctx context.Context, runID string, inboxID int) error {
+	if err := d.EnsureJournalTables(ctx); err != nil {
+		return err
+	}
+
+	query := `INSERT INTO chatwoot_sync_runs (run_id, account_id, inbox_id, started_at) VALUES ($1, $2, $3, NOW())`
+	if _, err := d.q.ExecContext(ctx, query, runID, d.cfg.Chatwoot.AccountID, inboxID); err != nil {
+		return fmt.Errorf("failed to register sync run: %w", err)
+	}
+
+	d.journal.set(runID)
+	return nil
+}
+
+// RunID retorna a execução atual, ou "" quando nada está sendo registrado (ex.: dry-run)
+func (d *Database) RunID() string {
+	return d.journal.get()
+}
+
+// record registra no diário as linhas criadas em table. Dentro de uma transação, o registro é
+// desfeito junto com as linhas se ela falhar.
+func (d *Database) record(ctx context.Context, table string, ids ...int64) error {
+	runID := d.journal.get()
+	if runID == "" || len(ids) == 0 {
+		return nil
+	}
+
+	query := `INSERT INTO chatwoot_sync_journal (run_id, table_name, record_id) SELECT $1, $2, unnest($3::BIGINT[])`
+	if _, err := d.q.ExecContext(ctx, query, runID, table, pq.Array(ids)); err != nil {
+		return fmt.Errorf("failed to record created %s in journal: %w", table, err)
+	}
+	return nil
+}
+
+// recordDetails registra no diário uma alteração em recordID que o rollback desfaz a partir de details
+func (d *Database) recordDetails(ctx context.Context, table string, recordID int64, details interface{}) error {
+	runID := d.journal.get()
+	if runID == "" {
+		return nil
+	}
+
+	data, err := json.Marshal(details)
+	if err != nil {
+		return fmt.Errorf("failed to encode %s journal entry: %w", table, err)
+	}
+	query := `INSERT INTO chatwoot_sync_journal (run_id, table_name, record_id, details) VALUES ($1, $2, $3, $4::jsonb)`
+	if _, err := d.q.ExecContext(ctx, query, runID, table, recordID, string(data)); err != nil {
+		return fmt.Errorf("failed to record %s in journal: %w", table, err)
+	}
+	return nil
+}
+
+// RecordMessage registra no diário uma mensagem criada pela API do Chatwoot
+func (d *Database) RecordMessage(ctx context.Context, messageID int64) error {
+	return d.record(ctx, JournalMessages, messageID)
+}
+
+// ListRuns lista as execuções mais recentes da conta
+func (d *Database) ListRuns(ctx context.Context, limit int) ([]models.SyncRun, error) {
+	exists, err := d.hasJournalTables(ctx)
+	if err != nil || !exists {
+		return nil, err
+	}
+
+	query := `
+		SELECT r.run_id, r.inbox_id, r.started_at, r.rolled_back_at, COUNT(j.record_id)
+		FROM chatwoot_sync_runs r
+			LEFT JOIN chatwoot_sync_journal j ON j.run_id = r.run_id
+		WHERE r.account_id = $1
+		GROUP BY r.run_id, r.inbox_id, r.started_at, r.rolled_back_at
+		ORDER BY r.started_at DESC
+		LIMIT $2
+	`
+	rows, err := d.q.QueryContext(ctx, query, d.cfg.Chatwoot.AccountID, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list sync runs: %w", err)
+	}
+	defer rows.Close()
+
+	var runs []models.SyncRun
+	for rows.Next() {
+		run, err := scanRun(rows)
+		if err != nil {
+			return nil, err
+		}
+		runs = append(runs, *run)
+	}
+	return runs, rows.Err()
+}
+
+// GetRun busca uma execução da conta; retorna nil se ela não existir
+func (d *Database) GetRun(ctx context.Context, runID string) (*models.SyncRun, error) {
+	exists, err := d.hasJournalTables(ctx)
+	if err != nil || !exists {
+		return nil, err
+	}
+
+	query := `
+		SELECT r.run_id, r.inbox_id, r.started_at, r.rolled_back_at, COUNT(j.record_id)
+		FROM chatwoot_sync_runs r
+			LEFT JOIN chatwoot_sync_journal j ON j.run_id = r.run_id
+		WHERE r.account_id = $1 AND r.run_id = $2
+		GROUP BY r.run_id, r.inbox_id, r.started_at, r.rolled_back_at
+	`
+	run, err := scanRun(d.q.QueryRowContext(ctx, query, d.cfg.Chatwoot.AccountID, runID))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	return run, err
+}
+
+// rowScanner é implementado por *sql.Row e *sql.Rows
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+func scanRun(row rowScanner) (*models.SyncRun, error) {
+	var run models.SyncRun
+	var rolledBackAt sql.NullTime
+	if err := row.Scan(&run.RunID, &run.InboxID, &run.StartedAt, &rolledBackAt, &run.Rows); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, err
+		}
+		return nil, fmt.Errorf("failed to scan sync run: %w", err)
+	}
+	if rolledBackAt.Valid {
+		run.RolledBackAt = &rolledBackAt.Time
+		run.Partial = run.Rows > 0
+	}
+	return &run, nil
+}
+
+// RollbackRun desfaz, em uma única transação, o que a execução registrou: apaga as mensagens (com
+// anexos), desfaz as mesclas de contatos e as trocas de foto, da mais recente para a mais antiga, e
+// apaga as conversas, contact_inboxes e contatos criados. Linhas que ainda são usadas por dados
+// fora da execução (ex.: uma conversa que recebeu mensagens depois) e alterações sobrepostas por
+// outras depois dela são mantidas, e o rollback é marcado como parcial.
+// Sem apply, o rollback é feito e desfeito apenas para contar o que seria removido.
+func (d *Database) RollbackRun(ctx context.Context, runID string, apply bool) (*models.RollbackResult, error) {
+	result := &models.RollbackResult{
+		Recorded: make(map[string]int),
+		Deleted:  make(map[string]int),
+	}
+
+	// Diários criados por versões anteriores ainda não têm as colunas das alterações
+	if err := d.EnsureJournalTables(ctx); err != nil {
+		return nil, err
+	}
+
+	err := d.WithTx(ctx, func(tx *Database) error {
+		ids := make(map[string][]int64, len(JournalTables))
+		for _, table := range JournalTables {
+			tableIDs, err := tx.journalIDs(ctx, runID, table)
+			if err != nil {
+				return err
+			}
+			ids[table] = tableIDs
+			result.Recorded[table] = len(tableIDs)
+		}
+
+		deleted, err := tx.DeleteMessages(ctx, ids[JournalMessages])
+		if err != nil {
+			return err
+		}
+		result.Deleted[JournalMessages] = deleted
+
+		changes, err := tx.journalChanges(ctx, runID)
+		if err != nil {
+			return err
+		}
+		var undone []int64
+		for _, change := range changes {
+			result.Recorded[change.table]++
+
+			var ok bool
+			switch change.table {
+			case JournalContactMerges:
+				ok, err = tx.undoContactMerge(ctx, change)
+			case JournalContactAvatars:
+				ok, err = tx.undoContactAvatar(ctx, change)
+			}
+			if err != nil {
+				return err
+			}
+			if ok {
+				result.Deleted[change.table]++
+				undone = append(undone, change.seq)
+			}
+		}
+
+		deletes := []struct {
+			table string
+			query string
+		}{
+			{JournalConversations, `
+				DELETE FROM conversations c
+				WHERE c.id = ANY($1) AND c.account_id = $2
+					AND NOT EXISTS (SELECT 1 FROM messages m WHERE m.conversation_id = c.id)
+			`},
+			{JournalContactInboxes, `
+				DELETE FROM contact_inboxes ci
+				USING contacts ct
+				WHERE ci.id = ANY($1) AND ct.id = ci.contact_id AND ct.account_id = $2
+					AND NOT EXISTS (SELECT 1 FROM conversations c WHERE c.contact_inbox_id = ci.id)
+			`},
+			{JournalContacts, `
+				DELETE FROM contacts ct
+				WHERE ct.id = ANY($1) AND ct.account_id = $2
+					AND NOT EXISTS (SELECT 1 FROM contact_inboxes ci WHERE ci.contact_id = ct.id)
+					AND NOT EXISTS (SELECT 1 FROM conversations c WHERE c.contact_id = ct.id)
+					AND NOT EXISTS (SELECT 1 FROM messages m WHERE m.sender_type = 'Contact' AND m.sender_id = ct.id)
+			`},
+		}
+		for _, del := range deletes {
+			if len(ids[del.table]) == 0 {
+				continue
+			}
+			res, err := tx.q.ExecContext(ctx, del.query, pq.Array(ids[del.table]), d.cfg.Chatwoot.AccountID)
+			if err != nil {
+				return fmt.Errorf("failed to delete %s: %w", del.table, err)
+			}
+			affected, err := res.RowsAffected()
+			if err != nil {
+				return fmt.Errorf("failed to count deleted %s: %w", del.table, err)
+			}
+			result.Deleted[del.table] = int(affected)
+		}
+
+		// O diário mantém apenas as linhas que continuam no banco e as alterações não desfeitas,
+		// permitindo repetir o rollback
+		for _, table := range JournalTables {
+			prune := fmt.Sprintf(`
+				DELETE FROM chatwoot_sync_journal j
+				WHERE j.run_id = $1 AND j.table_name = $2
+					AND NOT EXISTS (SELECT 1 FROM %s t WHERE t.id = j.record_id)
+			`, table)
+			if _, err := tx.q.ExecContext(ctx, prune, runID, table); err != nil {
+				return fmt.Errorf("failed to prune journal: %w", err)
+			}
+		}
+		if len(undone) > 0 {
+			prune := `DELETE FROM chatwoot_sync_journal WHERE run_id = $1 AND seq = ANY($2)`
+			if _, err := tx.q.ExecContext(ctx, prune, runID, pq.Array(undone)); err != nil {
+				return fmt.Errorf("failed to prune journal: %w", err)
+			}
+		}
+
+		if _, err := tx.q.ExecContext(ctx, `UPDATE chatwoot_sync_runs SET rolled_back_at = NOW() WHERE run_id = $1`, runID); err != nil {
+			return fmt.Errorf("failed to mark run as rolled back: %w", err)
+		}
+
+		if !apply {
+			return errRollbackDryRun
+		}
+		return nil
+	})
+	if err != nil && !errors.Is(err, errRollbackDryRun) {
+		return nil, err
+	}
+	for table, recorded := range result.Recorded {
+		if result.Deleted[table] < recorded {
+			result.Partial = true
+		}
+	}
+
+	log.Printf("RollbackRun: run %s, deleted %d messages, %d conversations, %d contact_inboxes, %d contacts, undid %d contact merges, %d avatars (applied: %v, partial: %v)",
+		runID, result.Deleted[JournalMessages], result.Deleted[JournalConversations],
+		result.Deleted[JournalContactInboxes], result.Deleted[JournalContacts],
+		result.Deleted[JournalContactMerges], result.Deleted[JournalContactAvatars], apply, result.Partial)
+	return result, nil
+}
+
+// journalEntry é uma alteração registrada no diário, com os dados para desfazê-la
+type journalEntry struct {
+	seq      int64
+	table    string
+	recordID int64
+	details  []byte
+}
+
+// journalChanges retorna as alterações registradas pela execução, da mais recente para a mais antiga
+func (d *Database) journalChanges(ctx context.Context, runID string) ([]journalEntry, error) {
+	query := `
+		SELECT seq, table_name, record_id, details::text FROM chatwoot_sync_journal
+		WHERE run_id = $1 AND table_name = ANY($2)
+		ORDER BY seq DESC
+	`
+	rows, err := d.q.QueryContext(ctx, query, runID, pq.Array(JournalChanges))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read journal: %w", err)
+	}
+	defer rows.Close()
+
+	var entries []journalEntry
+	for rows.Next() {
+		var entry journalEntry
+		var details sql.NullString
+		if err := rows.Scan(&entry.seq, &entry.table, &entry.recordID, &details); err != nil {
+			return nil, fmt.Errorf("failed to scan journal entry: %w", err)
+		}
+		entry.details = []byte(details.String)
+		entries = append(entries, entry)
+	}
+	return entries, rows.Err()
+}
+
+// undoContactMerge desfaz uma mescla: restaura o contato mantido como ele estava, recria o contato
+// apagado e devolve a ele as linhas movidas e a foto, se o blob dela ainda existe. Retorna false, sem alterar nada, se o contato mantido
+// não existe mais ou se o apagado não pode ser recriado (ex.: email ou identifier já em uso).
+func (d *Database) undoContactMerge(ctx context.Context, change journalEntry) (bool, error) {
+	var merge contactMerge
+	if err := json.Unmarshal(change.details, &merge); err != nil {
+		return false, fmt.Errorf("failed to decode merge of contact %d: %w", change.recordID, err)
+	}
+
+	if _, err := d.q.ExecContext(ctx, `SAVEPOINT undo_contact_merge`); err != nil {
+		return false, fmt.Errorf("failed to undo merge of contact %d: %w", change.recordID, err)
+	}
+	keep := func() (bool, error) {
+		if _, err := d.q.ExecContext(ctx, `ROLLBACK TO SAVEPOINT undo_contact_merge`); err != nil {
+			return false, fmt.Errorf("failed to undo merge of contact %d: %w", change.recordID, err)
+		}
+		return false, nil
+	}
+
+	restore := `
+		UPDATE contacts c SET
+			name = r.name, email = r.email, phone_number = r.phone_number, identifier = r.identifier,
+			custom_attributes = r.custom_attributes, additional_attributes = r.additional_attributes,
+			updated_at = NOW()
+		FROM jsonb_populate_record(NULL::contacts, $3::jsonb) r
+		WHERE c.id = $1 AND c.account_id = $2
+	`
+	res, err := d.q.ExecContext(ctx, restore, merge.KeptID, d.cfg.Chatwoot.AccountID, string(merge.Kept))
+	if err != nil {
+		return false, fmt.Errorf("failed to restore contact %d: %w", merge.KeptID, err)
+	}
+	if affected, err := res.RowsAffected(); err != nil || affected == 0 {
+		return keep()
+	}
+
+	insert := `INSERT INTO contacts SELECT * FROM jsonb_populate_record(NULL::contacts, $1::jsonb) ON CONFLICT DO NOTHING`
+	res, err = d.q.ExecContext(ctx, insert, string(merge.Merged))
+	if err != nil {
+		return false, fmt.Errorf("failed to recreate merged contact %d: %w", change.recordID, err)
+	}
+	if affected, err := res.RowsAffected(); err != nil || affected == 0 {
+		return keep()
+	}
+
+	moves := []contactMove{
+		{`UPDATE conversations SET contact_id = $1 WHERE id = ANY($2) AND contact_id = $3 AND account_id = $4`, &merge.Conversations},
+		{`
+			UPDATE contact_inboxes ci SET contact_id = $1
+			FROM inboxes i
+			WHERE ci.id = ANY($2) AND ci.contact_id = $3 AND i.id = ci.inbox_id AND i.account_id = $4
+		`, &merge.ContactInboxes},
+		{`UPDATE messages SET sender_id = $1 WHERE id = ANY($2) AND sender_type = 'Contact' AND sender_id = $3 AND account_id = $4`, &merge.Messages},
+		{`UPDATE notes SET contact_id = $1 WHERE id = ANY($2) AND contact_id = $3 AND account_id = $4`, &merge.Notes},
+	}
+	for _, move := range moves {
+		if len(*move.ids) == 0 {
+			continue
+		}
+		if _, err := d.q.ExecContext(ctx, move.query, change.recordID, pq.Array(*move.ids), merge.KeptID, d.cfg.Chatwoot.AccountID); err != nil {
+			return false, fmt.Errorf("failed to undo merge of contact %d: %w", change.recordID, err)
+		}
+	}
+
+	// O blob da foto só continua existindo se a mescla não pôde apagá-lo (sem storage ou ainda em uso)
+	if len(merge.AvatarBlobIDs) > 0 {
+		reattach := `
+			INSERT INTO active_storage_attachments (name, record_type, record_id, blob_id, created_at)
+			SELECT 'avatar', 'Contact', $1, b.id, NOW()
+			FROM active_storage_blobs b
+			WHERE b.id = ANY($2)
+			ORDER BY b.id DESC
+			LIMIT 1
+		`
+		if _, err := d.q.ExecContext(ctx, reattach, change.recordID, pq.Array(merge.AvatarBlobIDs)); err != nil {
+			return false, fmt.Errorf("failed to restore avatar of contact %d: %w", change.recordID, err)
+		}
+	}
+
+	if _, err := d.q.ExecContext(ctx, `RELEASE SAVEPOINT undo_contact_merge`); err != nil {
+		return false, fmt.Errorf("failed to undo merge of contact %d: %w", change.recordID, err)
+	}
+	return true, nil
+}
+
+// undoContactAvatar desfaz uma troca de foto: remove a foto importada, apaga seu blob se nada mais
+// o usa (o arquivo sai do storage após o commit) e volta a anexar a foto anterior, com sua origem.
+// Retorna false, sem alterar nada, se a foto do contato foi trocada de novo depois.
+func (d *Database) undoContactAvatar(ctx context.Context, change journalEntry) (bool, error) {
+	var avatar contactAvatarChange
+	if err := json.Unmarshal(change.details, &avatar); err != nil {
+		return false, fmt.Errorf("failed to decode avatar of contact %d: %w", change.recordID, err)
+	}
+
+	var replaced bool
+	replacedQuery := `
+		SELECT EXISTS (
+			SELECT 1 FROM active_storage_attachments
+			WHERE record_type = 'Contact' AND name = 'avatar' AND record_id = $1 AND NOT blob_id = ANY($2)
+		)
+	`
+	if err := d.q.QueryRowContext(ctx, replacedQuery, change.recordID, pq.Array(avatar.BlobIDs)).Scan(&replaced); err != nil {
+		return false, fmt.Errorf("failed to read avatar of contact %d: %w", change.recordID, err)
+	}
+	if replaced {
+		return false, nil
+	}
+
+	unlink := `DELETE FROM active_storage_attachments WHERE record_type = 'Contact' AND name = 'avatar' AND record_id = $1`
+	if _, err := d.q.ExecContext(ctx, unlink, change.recordID); err != nil {
+		return false, fmt.Errorf("failed to remove avatar of contact %d: %w", change.recordID, err)
+	}
+
+	if err := d.deleteUnusedBlobs(ctx, avatar.BlobIDs); err != nil {
+		return false, fmt.Errorf("failed to delete avatar blob of contact %d: %w", change.recordID, err)
+	}
+
+	// A foto anterior só volta se o blob ainda existe: a API do Chatwoot apaga o blob substituído
+	reattach := `
+		INSERT INTO active_storage_attachments (name, record_type, record_id, blob_id, created_at)
+		SELECT 'avatar', 'Contact', ct.id, b.id, NOW()
+		FROM contacts ct, active_storage_blobs b
+		WHERE ct.id = $1 AND ct.account_id = $2 AND b.id = ANY($3)
+		ORDER BY b.id DESC
+		LIMIT 1
+	`
+	if _, err := d.q.ExecContext(ctx, reattach, change.recordID, d.cfg.Chatwoot.AccountID, pq.Array(avatar.PreviousBlobIDs)); err != nil {
+		return false, fmt.Errorf("failed to restore avatar of contact %d: %w", change.recordID, err)
+	}
+
+	restoreSource := `
+		UPDATE contacts SET additional_attributes = CASE
+			WHEN $3::TEXT = '' THEN COALESCE(additional_attributes, '{}'::jsonb) - '` + attrAvatarSource + `'
+			ELSE COALESCE(additional_attributes, '{}'::jsonb) || jsonb_build_object('` + attrAvatarSource + `', $3::TEXT)
+		END
+		WHERE id = $1 AND account_id = $2
+	`
+	if _, err := d.q.ExecContext(ctx, restoreSource, change.recordID, d.cfg.Chatwoot.AccountID, avatar.PreviousSource); err != nil {
+		return false, fmt.Errorf("failed to restore avatar source of contact %d: %w", change.recordID, err)
+	}
+	return true, nil
+}
+
+// journalIDs retorna os IDs registrados pela execução em table
+func (d *Database) journalIDs(ctx context.Context, runID, table string) ([]int64, error) {
+	rows, err := d.q.QueryContext(ctx,
+		`SELECT DISTINCT record_id FROM chatwoot_sync_journal WHERE run_id = $1 AND table_name = $2`, runID, table)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read journal: %w", err)
+	}
+	defer rows.Close()
+
+	var ids []int64
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			return nil, fmt.Errorf("failed to scan journal entry: %w", err)
+		}
+		ids = append(ids, id)
+	}
+	return ids, rows.Err()
+}
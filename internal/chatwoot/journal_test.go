@@ -0,0 +1,124 @@
+package chatwoot
+
+import (
+	"chatwoot-sync-go/internal/config"
+	"chatwoot-sync-go/internal/storage"
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// fakeConn responde às consultas do rollback com um banco mínimo: uma mensagem registrada no
+// diário, com um anexo cujo blob não é usado por mais nada
+type fakeConn struct {
+	blobKey     string
+	failOn      string
+	deletedBlob bool
+}
+
+func (c *fakeConn) Connect(context.Context) (driver.Conn, error) { return c, nil }
+func (c *fakeConn) Driver() driver.Driver                        { return nil }
+
+func (c *fakeConn) Prepare(string) (driver.Stmt, error) {
+	return nil, errors.New("prepare not supported")
+}
+func (c *fakeConn) Close() error              { return nil }
+func (c *fakeConn) Begin() (driver.Tx, error) { return c, nil }
+func (c *fakeConn) Commit() error             { return nil }
+func (c *fakeConn) Rollback() error           { return nil }
+
+func (c *fakeConn) ExecContext(_ context.Context, query string, _ []driver.NamedValue) (driver.Result, error) {
+	if c.failOn != "" && strings.Contains(query, c.failOn) {
+		return nil, errors.New("falha simulada")
+	}
+	return driver.RowsAffected(1), nil
+}
+
+func (c *fakeConn) QueryContext(_ context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	switch {
+	case strings.Contains(query, "SELECT DISTINCT record_id") && args[1].Value == JournalMessages:
+		return &fakeRows{values: [][]driver.Value{{int64(1)}}}, nil
+	case strings.Contains(query, "RETURNING asa.blob_id"):
+		return &fakeRows{values: [][]driver.Value{{int64(7)}}}, nil
+	case strings.Contains(query, "to_regclass"):
+		return &fakeRows{values: [][]driver.Value{{true}}}, nil
+	case strings.Contains(query, "DELETE FROM active_storage_blobs"):
+		c.deletedBlob = true
+		return &fakeRows{values: [][]driver.Value{{c.blobKey}}}, nil
+	}
+	return &fakeRows{}, nil
+}
+
+type fakeRows struct {
+	values [][]driver.Value
+}
+
+func (r *fakeRows) Columns() []string {
+	if len(r.values) == 0 {
+		return []string{"id"}
+	}
+	return make([]string, len(r.values[0]))
+}
+func (r *fakeRows) Close() error { return nil }
+
+func (r *fakeRows) Next(dest []driver.Value) error {
+	if len(r.values) == 0 {
+		return io.EOF
+	}
+	copy(dest, r.values[0])
+	r.values = r.values[1:]
+	return nil
+}
+
+func TestRollbackRunDeletesBlobFiles(t *testing.T) {
+	tests := []struct {
+		name     string
+		apply    bool
+		failOn   string
+		wantFile bool
+	}{
+		{"rollback aplicado", true, "", false},
+		{"simulação mantém o arquivo", false, "", true},
+		{"transação desfeita mantém o arquivo", true, "UPDATE chatwoot_sync_runs", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			root := t.TempDir()
+			st, err := storage.NewLocalStorage(root, "local")
+			if err != nil {
+				t.Fatal(err)
+			}
+			const key = "k1b2c3d4e5f6g7h8i9j0l1m2n3o4"
+			if err := st.Put(key, []byte("foto"), "image/jpeg"); err != nil {
+				t.Fatal(err)
+			}
+
+			conn := &fakeConn{blobKey: key, failOn: tt.failOn}
+			db := sql.OpenDB(conn)
+			defer db.Close()
+			cfg := &config.Config{}
+			cfg.Chatwoot.AccountID = 1
+			d := &Database{db: db, q: db, cfg: cfg, storage: st, journal: &runJournal{}}
+
+			_, err = d.RollbackRun(context.Background(), "run", tt.apply)
+			if (err != nil) != (tt.failOn != "") {
+				t.Fatalf("RollbackRun() erro = %v", err)
+			}
+			if !conn.deletedBlob {
+				t.Errorf("blob sem uso não foi apagado")
+			}
+
+			_, statErr := os.Stat(filepath.Join(root, key[0:2], key[2:4], key))
+			if exists := statErr == nil; exists != tt.wantFile {
+				t.Errorf("arquivo existe = %v, esperado %v", exists, tt.wantFile)
+			}
+		})
+	}
+}
@@ -0,0 +1,195 @@
+package chatwoot
+
+import (
+	"chatwoot-sync-go/internal/models"
+	"context"
+	"database/sql"
+	"fmt"
+
+	"github.com/lib/pq"
+)
+
+// FindContactsAndConversations busca, sem gravar nada, os contatos existentes pelos telefones
+// e a conversa mais recente de cada um no inbox. Telefones sem contato não aparecem no resultado.
+func (d *Database) FindContactsAndConversations(
+	ctx context.Context,
+	contacts []models.ChatwootContact,
+	inboxID int,
+) (map[string]*models.ChatwootExistingContact, error) {
+	result := make(map[string]*models.ChatwootExistingContact)
+	if len(contacts) == 0 {
+		return result, nil
+	}
+
+	phones := make([]string, 0, len(contacts))
+	for _, contact := range contacts {
+		phones = append(phones, contact.PhoneNumber)
+	}
+
+	query := `
+		SELECT p.phone_number, c.id, COALESCE(c.name, ''), COALESCE(MAX(con.id), 0) AS conversation_id
+		FROM unnest($2::text[]) AS p (phone_number)
+		JOIN contacts c ON c.account_id = $1
+			AND (c.phone_number = p.phone_number
+				OR c.identifier = CONCAT(REPLACE(p.phone_number, '+', ''), '@s.whatsapp.net'))
+		LEFT JOIN contact_inboxes ci ON ci.contact_id = c.id AND ci.inbox_id = $3
+		LEFT JOIN conversations con ON con.contact_inbox_id = ci.id
+			AND con.account_id = $1
+			AND con.inbox_id = $3
+			AND con.contact_id = c.id
+		GROUP BY p.phone_number, c.id, c.name
+		ORDER BY p.phone_number, conversation_id DESC, c.id
+	`
+	rows, err := d.q.QueryContext(ctx, query, d.cfg.Chatwoot.AccountID, pq.Array(phones), inboxID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to find contacts: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var existing models.ChatwootExistingContact
+		if err := rows.Scan(&existing.PhoneNumber, &existing.ContactID, &existing.Name, &existing.ConversationID); err != nil {
+			return nil, fmt.Errorf("failed to scan contact: %w", err)
+		}
+		// A primeira linha de cada telefone é a que tem a conversa mais recente
+		if _, found := result[existing.PhoneNumber]; !found {
+			result[existing.PhoneNumber] = &existing
+		}
+	}
+
+	return result, rows.Err()
+}
+
+// FindChatConversations busca, sem gravar nada, as conversas do inbox de todos os contatos que a
+// sincronização consolida em um único chat: o contato do telefone (phone_number), o do identifier
+// e os contatos com o LID, que são mesclados no do telefone. Retorna os IDs da mais recente para
+// a mais antiga.
+func (d *Database) FindChatConversations(ctx context.Context, contact models.ChatwootContact, inboxID int) ([]int, error) {
+	query := `
+		SELECT DISTINCT con.id
+		FROM contacts c
+		JOIN contact_inboxes ci ON ci.contact_id = c.id AND ci.inbox_id = $2
+		JOIN conversations con ON con.contact_inbox_id = ci.id
+			AND con.account_id = $1
+			AND con.inbox_id = $2
+		WHERE c.account_id = $1
+			AND (($3 <> '' AND c.phone_number = $3)
+				OR ($4 <> '' AND c.identifier = $4)
+				OR ($5 <> '' AND (c.identifier = $5 OR c.custom_attributes->>'` + attrWhatsAppLID + `' = $5)))
+		ORDER BY con.id DESC
+	`
+	rows, err := d.q.QueryContext(ctx, query, d.cfg.Chatwoot.AccountID, inboxID,
+		contact.PhoneNumber, contact.Identifier, contact.LID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to find conversations of %s: %w", contact.Identifier, err)
+	}
+	defer rows.Close()
+
+	var conversationIDs []int
+	for rows.Next() {
+		var conversationID int
+		if err := rows.Scan(&conversationID); err != nil {
+			return nil, fmt.Errorf("failed to scan conversation: %w", err)
+		}
+		conversationIDs = append(conversationIDs, conversationID)
+	}
+	return conversationIDs, rows.Err()
+}
+
+// FindGroupConversation busca, sem gravar nada, o contato do grupo e sua conversa mais recente no inbox.
+// Retorna nil se o grupo ainda não existe.
+func (d *Database) FindGroupConversation(ctx context.Context, identifier string, inboxID int) (*models.ChatwootExistingContact, error) {
+	query := `
+		SELECT c.id, COALESCE(c.name, ''), COALESCE(MAX(con.id), 0)
+		FROM contacts c
+		LEFT JOIN contact_inboxes ci ON ci.contact_id = c.id AND ci.inbox_id = $3
+		LEFT JOIN conversations con ON con.contact_inbox_id = ci.id
+			AND con.account_id = $1
+			AND con.inbox_id = $3
+		WHERE c.identifier = $2 AND c.account_id = $1
+		GROUP BY c.id, c.name
+		LIMIT 1
+	`
+
+	var existing models.ChatwootExistingContact
+	err := d.q.QueryRowContext(ctx, query, d.cfg.Chatwoot.AccountID, identifier, inboxID).
+		Scan(&existing.ContactID, &existing.Name, &existing.ConversationID)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to find group: %w", err)
+	}
+
+	return &existing, nil
+}
+
+// FindContacts busca, sem gravar nada, os contatos existentes (ex.: participantes de grupos).
+// Retorna um mapa identifier -> contact_id apenas com os contatos encontrados.
+func (d *Database) FindContacts(ctx context.Context, contacts []models.ChatwootContact) (map[string]int, error) {
+	result := make(map[string]int, len(contacts))
+
+	for _, contact := range contacts {
+		if _, done := result[contact.Identifier]; done {
+			continue
+		}
+
+		contactID, err := d.findContactID(ctx, contact)
+		if err != nil {
+			return nil, err
+		}
+		if contactID != 0 {
+			result[contact.Identifier] = int(contactID)
+		}
+	}
+
+	return result, nil
+}
+
+// findContactID busca o contato pelo identifier, telefone ou LID, retornando 0 se não existir
+func (d *Database) findContactID(ctx context.Context, contact models.ChatwootContact) (int64, error) {
+	var contactID int64
+	findQuery := `
+		SELECT id FROM contacts
+		WHERE account_id = $1
+			AND (identifier = $2
+				OR (NULLIF($3, '') IS NOT NULL AND phone_number = $3)
+				OR (NULLIF($4, '') IS NOT NULL AND (identifier = $4 OR custom_attributes->>'` + attrWhatsAppLID + `' = $4)))
+		ORDER BY (identifier = $2) DESC, (phone_number IS NOT DISTINCT FROM NULLIF($3, '')) DESC, id
+		LIMIT 1
+	`
+	err := d.q.QueryRowContext(ctx, findQuery, d.cfg.Chatwoot.AccountID, contact.Identifier, contact.PhoneNumber, contact.LID).Scan(&contactID)
+	if err == sql.ErrNoRows {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, fmt.Errorf("failed to query contact %s: %w", contact.Identifier, err)
+	}
+	return contactID, nil
+}
+
+// FindContactPhone retorna o primeiro dos telefones informados (em ordem de preferência) que já
+// pertence a um contato da conta, pelo phone_number ou pelo identifier. Retorna "" se nenhum existir.
+func (d *Database) FindContactPhone(ctx context.Context, phones []string) (string, error) {
+	query := `
+		SELECT p.phone_number
+		FROM unnest($2::text[]) WITH ORDINALITY AS p (phone_number, position)
+		WHERE EXISTS (
+			SELECT 1 FROM contacts c
+			WHERE c.account_id = $1
+				AND (c.phone_number = p.phone_number
+					OR c.identifier = CONCAT(REPLACE(p.phone_number, '+', ''), '@s.whatsapp.net'))
+		)
+		ORDER BY p.position
+		LIMIT 1
+	`
+	var found string
+	err := d.q.QueryRowContext(ctx, query, d.cfg.Chatwoot.AccountID, pq.Array(phones)).Scan(&found)
+	if err == sql.ErrNoRows {
+		return "", nil
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to find contact phone: %w", err)
+	}
+	return found, nil
+}
@@ -0,0 +1,70 @@
+package chatwoot
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+)
+
+// Atributos de content_attributes usados pelo Chatwoot para exibir a mensagem respondida.
+// in_reply_to_external_id guarda o source_id (WAID:) da citação até que ela seja resolvida.
+const (
+	attrInReplyTo           = "in_reply_to"
+	attrInReplyToExternalID = "in_reply_to_external_id"
+)
+
+// replyAttributes monta o content_attributes de uma mensagem nova que cita quotedSourceID
+func replyAttributes(quotedSourceID string) string {
+	if quotedSourceID == "" {
+		return "{}"
+	}
+	attributes, _ := json.Marshal(map[string]string{attrInReplyToExternalID: quotedSourceID})
+	return string(attributes)
+}
+
+// SetMessageReplyTo registra a citação de uma mensagem criada pela API do Chatwoot; o ID da
+// mensagem citada é resolvido depois, por LinkReplies
+func (d *Database) SetMessageReplyTo(ctx context.Context, sourceID string, conversationID int, quotedSourceID string) error {
+	query := `
+		UPDATE messages
+		SET content_attributes = (COALESCE(content_attributes::jsonb, '{}'::jsonb)
+			|| jsonb_build_object('` + attrInReplyToExternalID + `', $1::TEXT))::json
+		WHERE source_id = $2 AND conversation_id = $3
+	`
+	if _, err := d.q.ExecContext(ctx, query, quotedSourceID, sourceID, conversationID); err != nil {
+		return fmt.Errorf("failed to update message reply: %w", err)
+	}
+	return nil
+}
+
+// LinkReplies resolve as citações pendentes da conversa: mensagens com in_reply_to_external_id e
+// sem in_reply_to recebem o ID da mensagem citada, se ela já foi importada (inclusive como um dos
+// source_ids extras de uma mensagem enviada pela ponte). Retorna quantas foram resolvidas.
+func (d *Database) LinkReplies(ctx context.Context, conversationID int) (int, error) {
+	query := `
+		UPDATE messages m
+		SET content_attributes = (m.content_attributes::jsonb || jsonb_build_object('` + attrInReplyTo + `', q.id))::json
+		FROM messages q
+		WHERE m.conversation_id = $1 AND m.account_id = $2
+			AND m.content_attributes::jsonb ? '` + attrInReplyToExternalID + `'
+			AND NOT m.content_attributes::jsonb ? '` + attrInReplyTo + `'
+			AND q.conversation_id = m.conversation_id
+			AND q.id <> m.id
+			AND (q.source_id = m.content_attributes::jsonb ->> '` + attrInReplyToExternalID + `'
+				OR (json_typeof(q.content_attributes::json -> 'bridged_source_ids') = 'array'
+					AND q.content_attributes::jsonb -> 'bridged_source_ids' ? (m.content_attributes::jsonb ->> '` + attrInReplyToExternalID + `')))
+	`
+	result, err := d.q.ExecContext(ctx, query, conversationID, d.cfg.Chatwoot.AccountID)
+	if err != nil {
+		return 0, fmt.Errorf("failed to link replies: %w", err)
+	}
+	linked, err := result.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("failed to count linked replies: %w", err)
+	}
+	if linked > 0 {
+		log.Printf("LinkReplies: Linked %d replies in conversation %d", linked, conversationID)
+	}
+	return int(linked), nil
+}
@@ -0,0 +1,35 @@
+package chatwoot
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+func TestReplyAttributes(t *testing.T) {
+	tests := []struct {
+		name           string
+		quotedSourceID string
+		want           map[string]interface{}
+	}{
+		{"sem citação", "", map[string]interface{}{}},
+		{"com citação", "WAID:3EB0C767D26A1D2E5F4B", map[string]interface{}{attrInReplyToExternalID: "WAID:3EB0C767D26A1D2E5F4B"}},
+		{"caracteres escapados", `WAID:"a\b"`, map[string]interface{}{attrInReplyToExternalID: `WAID:"a\b"`}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			raw := replyAttributes(tt.quotedSourceID)
+			var got map[string]interface{}
+			if err := json.Unmarshal([]byte(raw), &got); err != nil {
+				t.Fatalf("replyAttributes(%q) = %q, JSON inválido: %v", tt.quotedSourceID, raw, err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("replyAttributes(%q) = %v, esperado %v", tt.quotedSourceID, got, tt.want)
+			}
+			if _, ok := got[attrInReplyTo]; ok {
+				t.Errorf("replyAttributes(%q) não deve definir %s antes da citação ser resolvida", tt.quotedSourceID, attrInReplyTo)
+			}
+		})
+	}
+}
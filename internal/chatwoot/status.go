@@ -0,0 +1,60 @@
+package chatwoot
+
+import (
+	"context"
+	"fmt"
+	"log"
+
+	"github.com/lib/pq"
+)
+
+// Valores do enum messages.status do Chatwoot
+const (
+	MessageStatusSent      = 0
+	MessageStatusDelivered = 1
+	MessageStatusRead      = 2
+	MessageStatusFailed    = 3
+)
+
+// UpdateMessageStatuses atualiza o status das mensagens enviadas (outgoing) do inbox, indexadas
+// pelo source_id, apenas quando ele avança: enviado → entregue → lido. Uma mensagem só passa a
+// falha se ainda estava como enviada. Com conversationID diferente de 0, apenas essa conversa é
+// considerada. Retorna quantas mensagens foram atualizadas.
+func (d *Database) UpdateMessageStatuses(ctx context.Context, inboxID, conversationID int, statuses map[string]int) (int, error) {
+	if len(statuses) == 0 {
+		return 0, nil
+	}
+
+	sourceIDs := make([]string, 0, len(statuses))
+	values := make([]int64, 0, len(statuses))
+	for sourceID, status := range statuses {
+		sourceIDs = append(sourceIDs, sourceID)
+		values = append(values, int64(status))
+	}
+
+	query := `
+		UPDATE messages m
+		SET status = u.status
+		FROM unnest($1::TEXT[], $2::INTEGER[]) AS u(source_id, status)
+		WHERE m.source_id = u.source_id
+			AND m.account_id = $3
+			AND m.inbox_id = $4
+			AND ($5 = 0 OR m.conversation_id = $5)
+			AND m.message_type = 1
+			AND ((u.status <> $6 AND COALESCE(m.status, 0) < u.status)
+				OR (u.status = $6 AND COALESCE(m.status, 0) = $7))
+	`
+	result, err := d.q.ExecContext(ctx, query, pq.Array(sourceIDs), pq.Array(values), d.cfg.Chatwoot.AccountID, inboxID,
+		conversationID, MessageStatusFailed, MessageStatusSent)
+	if err != nil {
+		return 0, fmt.Errorf("failed to update message statuses: %w", err)
+	}
+	updated, err := result.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("failed to count updated message statuses: %w", err)
+	}
+	if updated > 0 {
+		log.Printf("UpdateMessageStatuses: Updated status of %d messages", updated)
+	}
+	return int(updated), nil
+}
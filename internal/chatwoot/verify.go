@@ -0,0 +1,89 @@
+package chatwoot
+
+import (
+	"chatwoot-sync-go/internal/models"
+	"context"
+	"fmt"
+
+	"github.com/lib/pq"
+)
+
+// CountMessageSourceIDs conta as mensagens importadas do WhatsApp (source_id WAID:) das conversas,
+// incluindo os source_ids extras das mensagens enviadas pela ponte. Com since > 0 (unix, em
+// segundos), apenas mensagens criadas a partir de since são consideradas.
+func (d *Database) CountMessageSourceIDs(ctx context.Context, conversationIDs []int, since int64) (map[string]int, error) {
+	counts := make(map[string]int)
+	if len(conversationIDs) == 0 {
+		return counts, nil
+	}
+
+	ids := make([]int64, 0, len(conversationIDs))
+	for _, id := range conversationIDs {
+		ids = append(ids, int64(id))
+	}
+
+	query := `
+		SELECT source_id, COUNT(*)
+		FROM (
+			SELECT source_id
+			FROM messages
+			WHERE conversation_id = ANY($1) AND source_id LIKE 'WAID:%' AND created_at >= to_timestamp($2)
+			UNION ALL
+			SELECT bridged.source_id
+			FROM messages m,
+				json_array_elements_text(m.content_attributes::json -> 'bridged_source_ids') AS bridged(source_id)
+			WHERE m.conversation_id = ANY($1)
+				AND m.created_at >= to_timestamp($2)
+				AND json_typeof(m.content_attributes::json -> 'bridged_source_ids') = 'array'
+		) AS imported
+		GROUP BY source_id
+	`
+	rows, err := d.q.QueryContext(ctx, query, pq.Array(ids), since)
+	if err != nil {
+		return nil, fmt.Errorf("failed to count messages: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var sourceID string
+		var count int
+		if err := rows.Scan(&sourceID, &count); err != nil {
+			return nil, fmt.Errorf("failed to scan message count: %w", err)
+		}
+		counts[sourceID] = count
+	}
+
+	return counts, rows.Err()
+}
+
+// FindContactsWithoutConversation lista os contatos vinculados ao inbox que não têm nenhuma conversa nele
+func (d *Database) FindContactsWithoutConversation(ctx context.Context, inboxID int) ([]models.ChatwootExistingContact, error) {
+	query := `
+		SELECT c.id, COALESCE(c.name, ''), COALESCE(c.phone_number, ''), COALESCE(c.identifier, '')
+		FROM contacts c
+		JOIN contact_inboxes ci ON ci.contact_id = c.id AND ci.inbox_id = $2
+		WHERE c.account_id = $1
+			AND NOT EXISTS (
+				SELECT 1 FROM conversations con
+				WHERE con.contact_id = c.id AND con.inbox_id = $2 AND con.account_id = $1
+			)
+		GROUP BY c.id, c.name, c.phone_number, c.identifier
+		ORDER BY c.id
+	`
+	rows, err := d.q.QueryContext(ctx, query, d.cfg.Chatwoot.AccountID, inboxID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to find contacts without conversation: %w", err)
+	}
+	defer rows.Close()
+
+	var contacts []models.ChatwootExistingContact
+	for rows.Next() {
+		var contact models.ChatwootExistingContact
+		if err := rows.Scan(&contact.ContactID, &contact.Name, &contact.PhoneNumber, &contact.Identifier); err != nil {
+			return nil, fmt.Errorf("failed to scan contact: %w", err)
+		}
+		contacts = append(contacts, contact)
+	}
+
+	return contacts, rows.Err()
+}
@@ -0,0 +1,110 @@
+// Package cli implementa os subcomandos do binário chatwoot-sync
+package cli
+
+import (
+	"chatwoot-sync-go/internal/sync"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+)
+
+const programName = "chatwoot-sync"
+
+// Códigos de saída
+const (
+	exitOK          = 0
+	exitFailure     = 1   // Falha durante a execução
+	exitUsage       = 2   // Subcomando, flag ou configuração inválidos
+	exitMismatch    = 3   // verify encontrou divergências
+	exitInterrupted = 130 // Interrompido por SIGINT/SIGTERM, como 128+SIGINT nos shells
+)
+
+type command struct {
+	name    string
+	summary string
+	run     func(args []string) int
+}
+
+func commands() []command {
+	return []command{
+		{"sync", "Sync all chats from UAZAPI into Chatwoot (default command)", runSync},
+		{"dry-run", "Show what sync would write, without changing Chatwoot", runDryRun},
+		{"resync-chat", "Resync a single chat by phone number or wa_chatid", runResyncChat},
+		{"list-inboxes", "List the inboxes of the Chatwoot account", runListInboxes},
+		{"list-chats", "List the UAZAPI chats that pass the configured filters", runListChats},
+		{"verify", "Compare UAZAPI messages with Chatwoot and report discrepancies", runVerify},
+		{"dedupe", "Report WhatsApp messages imported more than once and optionally delete the extras", runDedupe},
+		{"rollback", "Delete the rows created by a sync run, or list the recent runs", runRollback},
+	}
+}
+
+// Run executa o subcomando indicado em args e retorna o código de saída. Sem subcomando,
+// ou quando o primeiro argumento é uma flag, executa sync.
+func Run(args []string) int {
+	if len(args) == 0 {
+		return runSync(nil)
+	}
+
+	name := args[0]
+	switch name {
+	case "help", "-h", "-help", "--help":
+		printUsage(os.Stdout)
+		return exitOK
+	}
+	if strings.HasPrefix(name, "-") {
+		return runSync(args)
+	}
+
+	for _, cmd := range commands() {
+		if cmd.name == name {
+			return cmd.run(args[1:])
+		}
+	}
+
+	fmt.Fprintf(os.Stderr, "Unknown command %q\n\n", name)
+	printUsage(os.Stderr)
+	return exitUsage
+}
+
+func printUsage(out io.Writer) {
+	fmt.Fprintf(out, "Usage: %s <command> [flags]\n\nCommands:\n", programName)
+	for _, cmd := range commands() {
+		fmt.Fprintf(out, "  %-14s %s\n", cmd.name, cmd.summary)
+	}
+	fmt.Fprintf(out, "\nRun '%s <command> --help' for the flags of each command.\n", programName)
+	fmt.Fprintln(out, "Flags override the values read from the environment and the .env file.")
+	fmt.Fprintln(out, "\nExit codes: 0 success, 1 failure, 2 invalid command, flag or configuration,")
+	fmt.Fprintln(out, "3 verify found discrepancies, 130 interrupted by SIGINT/SIGTERM.")
+}
+
+// runService executa run até terminar ou até receber SIGINT/SIGTERM, quando o serviço é
+// parado, aguardando a execução em andamento, e o código de saída indica a interrupção
+func runService(service *sync.Service, run func() error) int {
+	sigChan := make(chan os.Signal, 1)
+	signal.Notify(sigChan, os.Interrupt, syscall.SIGTERM)
+	defer signal.Stop(sigChan)
+
+	done := service.Go(run)
+
+	select {
+	case <-sigChan:
+		log.Println("Received interrupt signal, shutting down...")
+		service.Stop()
+		return exitInterrupted
+	case err := <-done:
+		if errors.Is(err, sync.ErrVerifyMismatch) {
+			log.Println("Verification found discrepancies")
+			return exitMismatch
+		}
+		if err != nil {
+			log.Printf("Command failed: %v", err)
+			return exitFailure
+		}
+		return exitOK
+	}
+}
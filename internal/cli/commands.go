@@ -0,0 +1,274 @@
+package cli
+
+import (
+	"chatwoot-sync-go/internal/sync"
+	"fmt"
+	"os"
+)
+
+func runSync(args []string) int {
+	cfg, ok := loadConfig()
+	if !ok {
+		return exitUsage
+	}
+
+	fs := newFlagSet("sync", "",
+		"Syncs all chats from UAZAPI into Chatwoot. In daemon mode the sync repeats every\n"+
+			"interval and, with the webhook enabled, messages are also received in real time.")
+	bindChatwootFlags(fs, cfg)
+	bindFilterFlags(fs, cfg)
+	bindSyncFlags(fs, cfg)
+	fs.BoolVar(&cfg.Sync.DryRun, "dry-run", cfg.Sync.DryRun, "Do not write anything to Chatwoot (SYNC_DRY_RUN)")
+	fs.BoolVar(&cfg.Sync.Daemon, "daemon", cfg.Sync.Daemon, "Keep running and sync periodically (SYNC_DAEMON)")
+	fs.IntVar(&cfg.Sync.IntervalSeconds, "interval", cfg.Sync.IntervalSeconds, "Seconds between sync cycles in daemon mode (SYNC_INTERVAL_SECONDS)")
+	fs.BoolVar(&cfg.Webhook.Enabled, "webhook", cfg.Webhook.Enabled, "Receive UAZAPI events in real time, requires -daemon (WEBHOOK_ENABLED)")
+	fs.StringVar(&cfg.Webhook.ListenAddr, "listen", cfg.Webhook.ListenAddr, "Webhook server address (WEBHOOK_LISTEN_ADDR)")
+	if code, ok := parseFlags(fs, args); !ok {
+		return code
+	}
+	if fs.NArg() > 0 {
+		return unexpectedArgs(fs)
+	}
+	if !validate(cfg.Validate) {
+		return exitUsage
+	}
+
+	service := sync.NewService(cfg)
+	run := service.Start
+	if cfg.Sync.Daemon {
+		run = service.Run
+	}
+	return runService(service, run)
+}
+
+func runDryRun(args []string) int {
+	cfg, ok := loadConfig()
+	if !ok {
+		return exitUsage
+	}
+
+	fs := newFlagSet("dry-run", "",
+		"Runs a single sync cycle reading UAZAPI and Chatwoot without writing anything, and\n"+
+			"prints the contacts, conversations and messages that sync would create.")
+	bindChatwootFlags(fs, cfg)
+	bindFilterFlags(fs, cfg)
+	bindSyncFlags(fs, cfg)
+	if code, ok := parseFlags(fs, args); !ok {
+		return code
+	}
+	if fs.NArg() > 0 {
+		return unexpectedArgs(fs)
+	}
+
+	oneShot(cfg)
+	cfg.Sync.DryRun = true
+	if !validate(cfg.Validate) {
+		return exitUsage
+	}
+
+	service := sync.NewService(cfg)
+	return runService(service, service.Start)
+}
+
+func runResyncChat(args []string) int {
+	cfg, ok := loadConfig()
+	if !ok {
+		return exitUsage
+	}
+
+	fs := newFlagSet("resync-chat", "<phone|wa_chatid>",
+		"Resyncs a single chat, ignoring its checkpoint: the contact and conversation are created\n"+
+			"if needed and every message missing in Chatwoot is inserted. Chat filters do not apply.")
+	bindChatwootFlags(fs, cfg)
+	fs.IntVar(&cfg.Sync.LimitMessages, "limit-messages", cfg.Sync.LimitMessages, "Messages fetched per page (SYNC_LIMIT_MESSAGES)")
+	fs.Var(&dateFlag{&cfg.Sync.MessagesSince, false}, "messages-since", "Ignore messages before this `date` (SYNC_MESSAGES_SINCE)")
+	fs.BoolVar(&cfg.Sync.DryRun, "dry-run", cfg.Sync.DryRun, "Only show what would be inserted (SYNC_DRY_RUN)")
+	bindRegionFlag(fs, cfg)
+	if code, ok := parseFlags(fs, args); !ok {
+		return code
+	}
+
+	// Aceitar flags também depois do chat
+	if fs.NArg() == 0 {
+		fmt.Fprintln(os.Stderr, "Missing chat: pass a phone number or wa_chatid")
+		fs.Usage()
+		return exitUsage
+	}
+	target := fs.Arg(0)
+	if code, ok := parseFlags(fs, fs.Args()[1:]); !ok {
+		return code
+	}
+	if fs.NArg() > 0 {
+		return unexpectedArgs(fs)
+	}
+
+	oneShot(cfg)
+	if !validate(cfg.Validate) {
+		return exitUsage
+	}
+
+	service := sync.NewService(cfg)
+	return runService(service, func() error {
+		return service.ResyncChat(target)
+	})
+}
+
+func runListInboxes(args []string) int {
+	cfg, ok := loadConfig()
+	if !ok {
+		return exitUsage
+	}
+
+	fs := newFlagSet("list-inboxes", "", "Lists the inboxes of the Chatwoot account, marking the configured one.")
+	fs.IntVar(&cfg.Chatwoot.AccountID, "account-id", cfg.Chatwoot.AccountID, "Chatwoot account ID (CHATWOOT_ACCOUNT_ID)")
+	fs.IntVar(&cfg.Chatwoot.InboxID, "inbox-id", cfg.Chatwoot.InboxID, "Chatwoot inbox ID (CHATWOOT_INBOX_ID)")
+	if code, ok := parseFlags(fs, args); !ok {
+		return code
+	}
+	if fs.NArg() > 0 {
+		return unexpectedArgs(fs)
+	}
+	if !validate(cfg.ValidateDatabase) {
+		return exitUsage
+	}
+
+	service := sync.NewService(cfg)
+	return runService(service, service.ListInboxes)
+}
+
+func runListChats(args []string) int {
+	cfg, ok := loadConfig()
+	if !ok {
+		return exitUsage
+	}
+
+	fs := newFlagSet("list-chats", "", "Lists the UAZAPI chats that pass the chat filters, without accessing Chatwoot.")
+	bindFilterFlags(fs, cfg)
+	if code, ok := parseFlags(fs, args); !ok {
+		return code
+	}
+	if fs.NArg() > 0 {
+		return unexpectedArgs(fs)
+	}
+	if !validate(cfg.ValidateUAZAPI) {
+		return exitUsage
+	}
+
+	service := sync.NewService(cfg)
+	return runService(service, service.ListChats)
+}
+
+func runVerify(args []string) int {
+	cfg, ok := loadConfig()
+	if !ok {
+		return exitUsage
+	}
+
+	fs := newFlagSet("verify", "",
+		"Compares, for each chat, the UAZAPI message IDs with the messages of the mapped Chatwoot\n"+
+			"conversation and reports missing, extra and duplicated messages, plus contacts without\n"+
+			"conversations. Nothing is written to Chatwoot. Exits with 3 when discrepancies are found.")
+	bindChatwootFlags(fs, cfg)
+	bindFilterFlags(fs, cfg)
+	fs.IntVar(&cfg.Sync.Workers, "workers", cfg.Sync.Workers, "Chats verified in parallel (SYNC_WORKERS)")
+	fs.IntVar(&cfg.Sync.LimitMessages, "limit-messages", cfg.Sync.LimitMessages, "Messages fetched per page (SYNC_LIMIT_MESSAGES)")
+	fs.Var(&dateFlag{&cfg.Sync.MessagesSince, false}, "messages-since", "Only compare messages from this `date` on (SYNC_MESSAGES_SINCE)")
+	format := fs.String("format", sync.VerifyFormatTable, "Report `format`: table, json or csv")
+	output := fs.String("output", "", "Write the report to this `file` instead of stdout")
+	if code, ok := parseFlags(fs, args); !ok {
+		return code
+	}
+	if fs.NArg() > 0 {
+		return unexpectedArgs(fs)
+	}
+	switch *format {
+	case sync.VerifyFormatTable, sync.VerifyFormatJSON, sync.VerifyFormatCSV:
+	default:
+		fmt.Fprintf(os.Stderr, "Invalid format %q: expected table, json or csv\n", *format)
+		return exitUsage
+	}
+
+	// verify só lê; o dry-run garante que nenhum caminho compartilhado com sync grave algo
+	oneShot(cfg)
+	cfg.Sync.DryRun = true
+	if !validate(cfg.Validate) {
+		return exitUsage
+	}
+
+	out := os.Stdout
+	if *output != "" {
+		file, err := os.Create(*output)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Failed to create report file: %v\n", err)
+			return exitFailure
+		}
+		defer file.Close()
+		out = file
+	}
+
+	service := sync.NewService(cfg)
+	return runService(service, func() error {
+		return service.Verify(*format, out)
+	})
+}
+
+func runDedupe(args []string) int {
+	cfg, ok := loadConfig()
+	if !ok {
+		return exitUsage
+	}
+
+	fs := newFlagSet("dedupe", "",
+		"Finds WhatsApp messages (source_id WAID:...) imported more than once in the inbox, in the\n"+
+			"same or in different conversations, and reports them. With -delete, the extra copies and\n"+
+			"their attachments are deleted, keeping the oldest row of each source_id.")
+	bindChatwootFlags(fs, cfg)
+	apply := fs.Bool("delete", false, "Delete the extra copies instead of only reporting them")
+	if code, ok := parseFlags(fs, args); !ok {
+		return code
+	}
+	if fs.NArg() > 0 {
+		return unexpectedArgs(fs)
+	}
+
+	oneShot(cfg)
+	if !validate(cfg.ValidateDatabase) {
+		return exitUsage
+	}
+
+	service := sync.NewService(cfg)
+	return runService(service, func() error {
+		return service.Dedupe(*apply)
+	})
+}
+
+func runRollback(args []string) int {
+	cfg, ok := loadConfig()
+	if !ok {
+		return exitUsage
+	}
+
+	fs := newFlagSet("rollback", "",
+		"Deletes the messages (with attachments), conversations, contact_inboxes and contacts created\n"+
+			"by a sync run, in that order and in a single transaction. Rows still used by data from\n"+
+			"outside the run are kept. Without -run, lists the recent runs of the account.")
+	fs.IntVar(&cfg.Chatwoot.AccountID, "account-id", cfg.Chatwoot.AccountID, "Chatwoot account ID (CHATWOOT_ACCOUNT_ID)")
+	runID := fs.String("run", "", "`ID` of the run to roll back, as printed by sync")
+	fs.BoolVar(&cfg.Sync.DryRun, "dry-run", cfg.Sync.DryRun, "Only show what would be deleted (SYNC_DRY_RUN)")
+	if code, ok := parseFlags(fs, args); !ok {
+		return code
+	}
+	if fs.NArg() > 0 {
+		return unexpectedArgs(fs)
+	}
+
+	oneShot(cfg)
+	if !validate(cfg.ValidateDatabase) {
+		return exitUsage
+	}
+
+	service := sync.NewService(cfg)
+	return runService(service, func() error {
+		return service.Rollback(*runID)
+	})
+}
@@ -0,0 +1,154 @@
+package cli
+
+import (
+	"chatwoot-sync-go/internal/config"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+)
+
+// newFlagSet cria o conjunto de flags de um subcomando com o texto de ajuda padrão
+func newFlagSet(name, args, description string) *flag.FlagSet {
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	fs.Usage = func() {
+		out := fs.Output()
+		fmt.Fprintf(out, "Usage: %s\n\n%s\n", strings.TrimSpace(programName+" "+name+" [flags] "+args), description)
+		fmt.Fprintln(out, "\nFlags (override the environment variables):")
+		fs.PrintDefaults()
+	}
+	return fs
+}
+
+// parseFlags interpreta os argumentos do subcomando. Retorna false com o código de saída
+// quando a execução deve terminar (ajuda solicitada ou flag inválida).
+func parseFlags(fs *flag.FlagSet, args []string) (int, bool) {
+	if err := fs.Parse(args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return exitOK, false
+		}
+		return exitUsage, false
+	}
+	return exitOK, true
+}
+
+// loadConfig lê a configuração do ambiente; as flags são registradas depois, usando esses
+// valores como padrão
+func loadConfig() (*config.Config, bool) {
+	cfg, err := config.LoadFromEnv()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Invalid configuration: %v\n", err)
+		return nil, false
+	}
+	return cfg, true
+}
+
+// validate aplica uma das validações da configuração, reportando o erro como erro de uso
+func validate(check func() error) bool {
+	if err := check(); err != nil {
+		fmt.Fprintf(os.Stderr, "Invalid configuration: %v\n", err)
+		return false
+	}
+	return true
+}
+
+// oneShot desliga o modo daemon e o webhook nos subcomandos que executam uma única vez
+func oneShot(cfg *config.Config) {
+	cfg.Sync.Daemon = false
+	cfg.Webhook.Enabled = false
+	cfg.Webhook.OutboundEnabled = false
+}
+
+func bindChatwootFlags(fs *flag.FlagSet, cfg *config.Config) {
+	fs.IntVar(&cfg.Chatwoot.AccountID, "account-id", cfg.Chatwoot.AccountID, "Chatwoot account ID (CHATWOOT_ACCOUNT_ID)")
+	fs.IntVar(&cfg.Chatwoot.InboxID, "inbox-id", cfg.Chatwoot.InboxID, "Chatwoot inbox ID (CHATWOOT_INBOX_ID)")
+	fs.StringVar(&cfg.Chatwoot.InboxName, "inbox-name", cfg.Chatwoot.InboxName, "Chatwoot inbox name used when the ID is not found (CHATWOOT_INBOX_NAME)")
+}
+
+// bindFilterFlags registra as flags dos filtros de chats
+func bindFilterFlags(fs *flag.FlagSet, cfg *config.Config) {
+	fs.BoolVar(&cfg.Sync.IncludeGroups, "include-groups", cfg.Sync.IncludeGroups, "Include group chats (SYNC_INCLUDE_GROUPS)")
+	fs.BoolVar(&cfg.Sync.IncludeArchived, "include-archived", cfg.Sync.IncludeArchived, "Include archived chats (SYNC_INCLUDE_ARCHIVED)")
+	fs.Var(&dateFlag{&cfg.Sync.ChatsSince, false}, "chats-since", "Only chats whose last message is on or after this `date` (SYNC_CHATS_SINCE)")
+	fs.Var(&dateFlag{&cfg.Sync.ChatsUntil, true}, "chats-until", "Only chats whose last message is on or before this `date` (SYNC_CHATS_UNTIL)")
+	fs.Var(&listFlag{&cfg.Sync.IncludeChats}, "include-chats", "Comma-separated `list` of phones or JIDs to sync, replacing SYNC_INCLUDE_CHATS")
+	fs.Var(&listFlag{&cfg.Sync.ExcludeChats}, "exclude-chats", "Comma-separated `list` of phones or JIDs to skip, replacing SYNC_EXCLUDE_CHATS")
+}
+
+// bindSyncFlags registra as flags da sincronização de mensagens
+func bindSyncFlags(fs *flag.FlagSet, cfg *config.Config) {
+	fs.IntVar(&cfg.Sync.Workers, "workers", cfg.Sync.Workers, "Chats processed in parallel (SYNC_WORKERS)")
+	fs.IntVar(&cfg.Sync.BatchSize, "batch-size", cfg.Sync.BatchSize, "Chats per batch (SYNC_BATCH_SIZE)")
+	fs.IntVar(&cfg.Sync.LimitMessages, "limit-messages", cfg.Sync.LimitMessages, "Messages fetched per page (SYNC_LIMIT_MESSAGES)")
+	fs.BoolVar(&cfg.Sync.Incremental, "incremental", cfg.Sync.Incremental, "Skip chats and messages already covered by checkpoints (SYNC_INCREMENTAL)")
+	fs.Var(&dateFlag{&cfg.Sync.MessagesSince, false}, "messages-since", "Ignore messages before this `date` (SYNC_MESSAGES_SINCE)")
+	fs.IntVar(&cfg.Sync.StatusLookbackHours, "status-lookback-hours", cfg.Sync.StatusLookbackHours, "Hours before the checkpoint re-read to update message statuses (SYNC_STATUS_LOOKBACK_HOURS)")
+	fs.BoolVar(&cfg.Sync.ImportAvatars, "import-avatars", cfg.Sync.ImportAvatars, "Import chat profile pictures as contact avatars (SYNC_IMPORT_AVATARS)")
+	bindRegionFlag(fs, cfg)
+}
+
+// bindRegionFlag registra a região usada nos telefones sem código do país
+func bindRegionFlag(fs *flag.FlagSet, cfg *config.Config) {
+	fs.Func("default-region", "ISO `region` of phone numbers without country code, e.g. BR, PT, US (SYNC_DEFAULT_REGION)", func(value string) error {
+		cfg.Sync.DefaultRegion = strings.ToUpper(strings.TrimSpace(value))
+		return nil
+	})
+}
+
+// dateFlag aceita datas no mesmo formato das variáveis SYNC_*_SINCE/UNTIL
+type dateFlag struct {
+	value    *time.Time
+	endOfDay bool
+}
+
+func (f *dateFlag) String() string {
+	if f.value == nil || f.value.IsZero() {
+		return ""
+	}
+	return f.value.Format(time.RFC3339)
+}
+
+func (f *dateFlag) Set(value string) error {
+	if value == "" {
+		*f.value = time.Time{}
+		return nil
+	}
+	parsed, err := config.ParseDate(value, f.endOfDay)
+	if err != nil {
+		return err
+	}
+	*f.value = parsed
+	return nil
+}
+
+// listFlag aceita itens separados por vírgula e substitui a lista vinda do ambiente
+type listFlag struct {
+	values *[]string
+}
+
+func (f *listFlag) String() string {
+	if f.values == nil {
+		return ""
+	}
+	return strings.Join(*f.values, ",")
+}
+
+func (f *listFlag) Set(value string) error {
+	var values []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			values = append(values, item)
+		}
+	}
+	*f.values = values
+	return nil
+}
+
+// unexpectedArgs reporta argumentos posicionais não aceitos pelo subcomando
+func unexpectedArgs(fs *flag.FlagSet) int {
+	fmt.Fprintf(os.Stderr, "Unexpected arguments: %s\n", strings.Join(fs.Args(), " "))
+	fs.Usage()
+	return exitUsage
+}
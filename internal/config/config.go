@@ -1,9 +1,13 @@
 package config
 
 import (
+	"bufio"
+	"chatwoot-sync-go/internal/phone"
 	"fmt"
 	"os"
 	"strconv"
+	"strings"
+	"time"
 
 	"github.com/joho/godotenv"
 )
@@ -12,11 +16,20 @@ type Config struct {
 	UAZAPI UAZAPIConfig
 	Chatwoot ChatwootConfig
 	Sync SyncConfig
+	Webhook WebhookConfig
 }
 
 type UAZAPIConfig struct {
-	BaseURL string
-	Token   string
+	BaseURL        string
+	Token          string
+	MaxConcurrency int // Requisições simultâneas à UAZAPI
+	MaxRetries     int // Novas tentativas em falhas temporárias (429, 5xx, timeout)
+
+	// Limites de requisições por segundo (0 desabilita) para listagem/envio e para download de mídia
+	RateLimit      float64
+	RateBurst      int
+	MediaRateLimit float64
+	MediaRateBurst int
 }
 
 type ChatwootConfig struct {
@@ -25,6 +38,7 @@ type ChatwootConfig struct {
 	AccountID int
 	InboxID   int
 	InboxName string
+	Storage   StorageConfig
 }
 
 type DBConfig struct {
@@ -34,6 +48,7 @@ type DBConfig struct {
 	User     string
 	Password string
 	SSLMode  string
+	MaxConnections int // Conexões simultâneas ao banco
 }
 
 type APIConfig struct {
@@ -41,13 +56,62 @@ type APIConfig struct {
 	Token   string
 }
 
+// StorageConfig aponta para o storage do ActiveStorage do Chatwoot (inserção de mídias direto no banco)
+type StorageConfig struct {
+	Service string
+	Path    string
+}
+
 type SyncConfig struct {
 	BatchSize      int
 	LimitChats     int
 	LimitMessages  int
+	IncludeGroups  bool
+	Workers        int // Chats processados em paralelo
+	Incremental    bool
+	DryRun         bool
+	Daemon                bool
+	IntervalSeconds       int
+	IntervalJitterSeconds int
+
+	// Filtros de chats e mensagens (valores zero/vazios não filtram)
+	ChatsSince      time.Time // Janela aplicada ao wa_lastMsgTimestamp do chat
+	ChatsUntil      time.Time
+	MessagesSince   time.Time // Mensagens anteriores são ignoradas
+	IncludeChats    []string  // Telefones ou JIDs; se preenchida, apenas esses chats são sincronizados
+	ExcludeChats    []string
+	IncludeArchived bool
+
+	DefaultRegion string // Região (ISO 3166-1) dos telefones gravados sem código do país
+	ImportAvatars bool   // Importa as fotos de perfil dos chats como avatar dos contatos
+
+	// Horas antes do checkpoint relidas na sincronização incremental para atualizar o status das mensagens
+	StatusLookbackHours int
+}
+
+// WebhookConfig configura o servidor HTTP que recebe eventos em tempo real
+type WebhookConfig struct {
+	Enabled         bool
+	ListenAddr      string
+	Secret          string
+	OutboundEnabled bool // Envia ao WhatsApp as respostas dos agentes no Chatwoot
 }
 
+// Load lê a configuração das variáveis de ambiente e a valida
 func Load() (*Config, error) {
+	cfg, err := LoadFromEnv()
+	if err != nil {
+		return nil, err
+	}
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
+
+// LoadFromEnv lê a configuração das variáveis de ambiente (e do .env) sem validá-la, para que
+// as flags da linha de comando possam sobrescrever os valores antes de Validate
+func LoadFromEnv() (*Config, error) {
 	// Load .env file if exists
 	_ = godotenv.Load()
 
@@ -55,6 +119,12 @@ func Load() (*Config, error) {
 		UAZAPI: UAZAPIConfig{
 			BaseURL: getEnv("UAZAPI_BASE_URL", "https://free.uazapi.com"),
 			Token:   getEnv("UAZAPI_TOKEN", ""),
+			MaxConcurrency: getEnvAsInt("UAZAPI_MAX_CONCURRENCY", 4),
+			MaxRetries:     getEnvAsInt("UAZAPI_MAX_RETRIES", 3),
+			RateLimit:      getEnvAsFloat("UAZAPI_RATE_LIMIT", 5),
+			RateBurst:      getEnvAsInt("UAZAPI_RATE_BURST", 10),
+			MediaRateLimit: getEnvAsFloat("UAZAPI_MEDIA_RATE_LIMIT", 1),
+			MediaRateBurst: getEnvAsInt("UAZAPI_MEDIA_RATE_BURST", 2),
 		},
 		Chatwoot: ChatwootConfig{
 			DB: DBConfig{
@@ -64,6 +134,7 @@ func Load() (*Config, error) {
 				User:     getEnv("CHATWOOT_DB_USER", "chatwoot"),
 				Password: getEnv("CHATWOOT_DB_PASSWORD", ""),
 				SSLMode:  getEnv("CHATWOOT_DB_SSLMODE", "disable"),
+				MaxConnections: getEnvAsInt("CHATWOOT_DB_MAX_CONNECTIONS", 10),
 			},
 			API: APIConfig{
 				BaseURL: getEnv("CHATWOOT_BASE_URL", ""),
@@ -72,25 +143,126 @@ func Load() (*Config, error) {
 			AccountID: getEnvAsInt("CHATWOOT_ACCOUNT_ID", 1),
 			InboxID:   getEnvAsInt("CHATWOOT_INBOX_ID", 1),
 			InboxName: getEnv("CHATWOOT_INBOX_NAME", "WhatsApp"),
+			Storage: StorageConfig{
+				Service: getEnv("CHATWOOT_STORAGE_SERVICE", "local"),
+				Path:    getEnv("CHATWOOT_STORAGE_PATH", ""),
+			},
 		},
 		Sync: SyncConfig{
 			BatchSize:     getEnvAsInt("SYNC_BATCH_SIZE", 1000),
 			LimitChats:    getEnvAsInt("SYNC_LIMIT_CHATS", 100000),
 			LimitMessages: getEnvAsInt("SYNC_LIMIT_MESSAGES", 10000),
+			IncludeGroups: getEnvAsBool("SYNC_INCLUDE_GROUPS", false),
+			Workers:       getEnvAsInt("SYNC_WORKERS", 4),
+			Incremental:   getEnvAsBool("SYNC_INCREMENTAL", true),
+			DryRun:        getEnvAsBool("SYNC_DRY_RUN", false),
+			Daemon:                getEnvAsBool("SYNC_DAEMON", false),
+			IntervalSeconds:       getEnvAsInt("SYNC_INTERVAL_SECONDS", 300),
+			IntervalJitterSeconds: getEnvAsInt("SYNC_INTERVAL_JITTER_SECONDS", 30),
+			IncludeArchived:       getEnvAsBool("SYNC_INCLUDE_ARCHIVED", true),
+			DefaultRegion:         strings.ToUpper(getEnv("SYNC_DEFAULT_REGION", "BR")),
+			ImportAvatars:         getEnvAsBool("SYNC_IMPORT_AVATARS", true),
+			StatusLookbackHours:   getEnvAsInt("SYNC_STATUS_LOOKBACK_HOURS", 24),
+		},
+		Webhook: WebhookConfig{
+			Enabled:    getEnvAsBool("WEBHOOK_ENABLED", false),
+			ListenAddr: getEnv("WEBHOOK_LISTEN_ADDR", ":8080"),
+			Secret:     getEnv("WEBHOOK_SECRET", ""),
+			OutboundEnabled: getEnvAsBool("WEBHOOK_OUTBOUND_ENABLED", false),
 		},
 	}
 
-	// Validate required fields
-	if cfg.UAZAPI.Token == "" {
-		return nil, fmt.Errorf("UAZAPI_TOKEN is required")
+	var err error
+	if cfg.Sync.ChatsSince, err = getEnvAsDate("SYNC_CHATS_SINCE", false); err != nil {
+		return nil, err
 	}
-	if cfg.Chatwoot.DB.Password == "" {
-		return nil, fmt.Errorf("CHATWOOT_DB_PASSWORD is required")
+	if cfg.Sync.ChatsUntil, err = getEnvAsDate("SYNC_CHATS_UNTIL", true); err != nil {
+		return nil, err
+	}
+	if cfg.Sync.MessagesSince, err = getEnvAsDate("SYNC_MESSAGES_SINCE", false); err != nil {
+		return nil, err
+	}
+	if cfg.Sync.IncludeChats, err = getEnvAsList("SYNC_INCLUDE_CHATS", "SYNC_INCLUDE_CHATS_FILE"); err != nil {
+		return nil, err
+	}
+	if cfg.Sync.ExcludeChats, err = getEnvAsList("SYNC_EXCLUDE_CHATS", "SYNC_EXCLUDE_CHATS_FILE"); err != nil {
+		return nil, err
 	}
 
 	return cfg, nil
 }
 
+// Validate verifica a configuração completa usada pela sincronização
+func (cfg *Config) Validate() error {
+	if err := cfg.ValidateUAZAPI(); err != nil {
+		return err
+	}
+	if err := cfg.ValidateDatabase(); err != nil {
+		return err
+	}
+	if cfg.Sync.Workers <= 0 {
+		return fmt.Errorf("SYNC_WORKERS must be greater than zero")
+	}
+	if cfg.Sync.Daemon && cfg.Sync.IntervalSeconds <= 0 {
+		return fmt.Errorf("SYNC_INTERVAL_SECONDS must be greater than zero in daemon mode")
+	}
+	if cfg.Sync.DryRun && cfg.Sync.Daemon {
+		return fmt.Errorf("SYNC_DRY_RUN cannot be combined with SYNC_DAEMON")
+	}
+	if !cfg.Sync.ChatsSince.IsZero() && !cfg.Sync.ChatsUntil.IsZero() && !cfg.Sync.ChatsUntil.After(cfg.Sync.ChatsSince) {
+		return fmt.Errorf("SYNC_CHATS_UNTIL must be after SYNC_CHATS_SINCE")
+	}
+	if cfg.Sync.StatusLookbackHours < 0 {
+		return fmt.Errorf("SYNC_STATUS_LOOKBACK_HOURS cannot be negative")
+	}
+	if !phone.IsSupportedRegion(cfg.Sync.DefaultRegion) {
+		return fmt.Errorf("SYNC_DEFAULT_REGION %q is not supported (supported: %s)",
+			cfg.Sync.DefaultRegion, strings.Join(phone.SupportedRegions(), ", "))
+	}
+	if cfg.Webhook.Enabled && !cfg.Sync.Daemon {
+		return fmt.Errorf("WEBHOOK_ENABLED requires SYNC_DAEMON=true")
+	}
+	if cfg.Webhook.Enabled && cfg.Webhook.Secret == "" {
+		return fmt.Errorf("WEBHOOK_SECRET is required when WEBHOOK_ENABLED=true")
+	}
+	if cfg.Webhook.OutboundEnabled && !cfg.Webhook.Enabled {
+		return fmt.Errorf("WEBHOOK_OUTBOUND_ENABLED requires WEBHOOK_ENABLED=true")
+	}
+
+	return nil
+}
+
+// ValidateUAZAPI verifica apenas a configuração do cliente UAZAPI
+func (cfg *Config) ValidateUAZAPI() error {
+	if cfg.UAZAPI.Token == "" {
+		return fmt.Errorf("UAZAPI_TOKEN is required")
+	}
+	if cfg.UAZAPI.MaxConcurrency <= 0 {
+		return fmt.Errorf("UAZAPI_MAX_CONCURRENCY must be greater than zero")
+	}
+	if cfg.UAZAPI.MaxRetries < 0 {
+		return fmt.Errorf("UAZAPI_MAX_RETRIES cannot be negative")
+	}
+	if cfg.UAZAPI.RateLimit < 0 || cfg.UAZAPI.MediaRateLimit < 0 {
+		return fmt.Errorf("UAZAPI_RATE_LIMIT and UAZAPI_MEDIA_RATE_LIMIT cannot be negative")
+	}
+	if (cfg.UAZAPI.RateLimit > 0 && cfg.UAZAPI.RateBurst <= 0) || (cfg.UAZAPI.MediaRateLimit > 0 && cfg.UAZAPI.MediaRateBurst <= 0) {
+		return fmt.Errorf("UAZAPI_RATE_BURST and UAZAPI_MEDIA_RATE_BURST must be greater than zero")
+	}
+	return nil
+}
+
+// ValidateDatabase verifica apenas a configuração do banco do Chatwoot
+func (cfg *Config) ValidateDatabase() error {
+	if cfg.Chatwoot.DB.Password == "" {
+		return fmt.Errorf("CHATWOOT_DB_PASSWORD is required")
+	}
+	if cfg.Chatwoot.DB.MaxConnections <= 0 {
+		return fmt.Errorf("CHATWOOT_DB_MAX_CONNECTIONS must be greater than zero")
+	}
+	return nil
+}
+
 func getEnv(key, defaultValue string) string {
 	if value := os.Getenv(key); value != "" {
 		return value
@@ -110,3 +282,88 @@ func getEnvAsInt(key string, defaultValue int) int {
 	return value
 }
 
+func getEnvAsFloat(key string, defaultValue float64) float64 {
+	valueStr := os.Getenv(key)
+	if valueStr == "" {
+		return defaultValue
+	}
+	value, err := strconv.ParseFloat(valueStr, 64)
+	if err != nil {
+		return defaultValue
+	}
+	return value
+}
+
+func getEnvAsBool(key string, defaultValue bool) bool {
+	valueStr := os.Getenv(key)
+	if valueStr == "" {
+		return defaultValue
+	}
+	value, err := strconv.ParseBool(valueStr)
+	if err != nil {
+		return defaultValue
+	}
+	return value
+}
+
+// ParseDate lê uma data no formato 2006-01-02 ou RFC3339. Com endOfDay, uma data sem
+// horário inclui o dia inteiro (o valor retornado é o início do dia seguinte).
+func ParseDate(valueStr string, endOfDay bool) (time.Time, error) {
+	if value, err := time.Parse(time.RFC3339, valueStr); err == nil {
+		return value, nil
+	}
+	value, err := time.ParseInLocation("2006-01-02", valueStr, time.Local)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("expected a date (YYYY-MM-DD) or RFC3339 timestamp, got %q", valueStr)
+	}
+	if endOfDay {
+		value = value.AddDate(0, 0, 1)
+	}
+	return value, nil
+}
+
+func getEnvAsDate(key string, endOfDay bool) (time.Time, error) {
+	valueStr := os.Getenv(key)
+	if valueStr == "" {
+		return time.Time{}, nil
+	}
+	value, err := ParseDate(valueStr, endOfDay)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid %s: %w", key, err)
+	}
+	return value, nil
+}
+
+// getEnvAsList junta os itens separados por vírgula da variável key com os do arquivo
+// indicado em fileKey (um por linha; linhas vazias e iniciadas por # são ignoradas)
+func getEnvAsList(key, fileKey string) ([]string, error) {
+	var values []string
+	for _, item := range strings.Split(os.Getenv(key), ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			values = append(values, item)
+		}
+	}
+
+	path := os.Getenv(fileKey)
+	if path == "" {
+		return values, nil
+	}
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open %s: %w", fileKey, err)
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		values = append(values, line)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", fileKey, err)
+	}
+	return values, nil
+}
@@ -6,3 +6,38 @@ type ChatwootUser struct {
 	UserID   int
 }
 
+// ChatwootInbox representa um inbox da conta do Chatwoot
+type ChatwootInbox struct {
+	ID        int
+	Name      string
+	InboxType string
+}
+
+
+// ChatwootWebhookEvent representa o corpo dos webhooks de mensagem do Chatwoot
+type ChatwootWebhookEvent struct {
+	Event        string                      `json:"event"` // "message_created", ...
+	ID           int                         `json:"id"`
+	Content      string                      `json:"content"`
+	MessageType  string                      `json:"message_type"` // "incoming", "outgoing", "activity", "template"
+	Private      bool                        `json:"private"`
+	SourceID     string                      `json:"source_id"`
+	Attachments  []ChatwootWebhookAttachment `json:"attachments"`
+	Conversation ChatwootWebhookConversation `json:"conversation"`
+	Inbox        struct {
+		ID int `json:"id"`
+	} `json:"inbox"`
+}
+
+// ChatwootWebhookAttachment representa um anexo de mensagem no webhook do Chatwoot
+type ChatwootWebhookAttachment struct {
+	ID       int    `json:"id"`
+	FileType string `json:"file_type"` // "image", "audio", "video", "file"
+	DataURL  string `json:"data_url"`
+}
+
+// ChatwootWebhookConversation representa a conversa de uma mensagem no webhook do Chatwoot
+type ChatwootWebhookConversation struct {
+	ID      int `json:"id"`
+	InboxID int `json:"inbox_id"`
+}
@@ -1,5 +1,10 @@
 package models
 
+import (
+	"encoding/json"
+	"time"
+)
+
 // UAZAPI Models
 type UAZAPIChat struct {
 	ID                    string   `json:"id"`
@@ -36,6 +41,7 @@ type UAZAPIMessage struct {
 	Text              string `json:"text"`
 	Quoted            string `json:"quoted"`
 	FileURL           string `json:"fileURL"`
+	Content           json.RawMessage `json:"content"` // Objeto da mídia (ex.: fileName de documentos) ou texto
 	SenderPN          string `json:"sender_pn"`
 	SenderLID         string `json:"sender_lid"`
 }
@@ -68,11 +74,37 @@ type UAZAPIMessagesResponse struct {
 	HasMore          bool           `json:"hasMore"`
 }
 
+// UAZAPISendResponse representa a mensagem retornada pelos endpoints de envio da UAZAPI
+type UAZAPISendResponse struct {
+	ID               string `json:"id"`
+	MessageID        string `json:"messageid"`
+	ChatID           string `json:"chatid"`
+	MessageTimestamp int64  `json:"messageTimestamp"`
+	Status           string `json:"status"`
+}
+
+// UAZAPIWebhookEvent representa o corpo enviado pelos webhooks da UAZAPI
+type UAZAPIWebhookEvent struct {
+	EventType string               `json:"EventType"` // "messages", "messages_update", "chats", ...
+	Message   *UAZAPIMessage       `json:"message"`
+	Chat      *UAZAPIChat          `json:"chat"`
+	Event     *UAZAPIMessageUpdate `json:"event"`
+	Owner     string               `json:"owner"`
+}
+
+// UAZAPIMessageUpdate representa uma atualização de mensagens (ex.: confirmação de entrega/leitura)
+type UAZAPIMessageUpdate struct {
+	Chat       string   `json:"Chat"`
+	MessageIDs []string `json:"MessageIDs"`
+	Type       string   `json:"Type"`
+}
+
 // Chatwoot Models
 type ChatwootContact struct {
 	PhoneNumber string
 	Name        string
 	Identifier  string
+	LID         string // JID @lid do WhatsApp, quando conhecido
 	FirstTimestamp int64
 	LastTimestamp  int64
 }
@@ -85,6 +117,17 @@ type ChatwootMessage struct {
 	SenderID        int
 	SourceID        string // Format: "WAID:{message_id}"
 	MessageTimestamp int64
+	InReplyTo       string              // source_id (WAID:) da mensagem citada, se houver
+	Status          int                 // messages.status: 0 = sent, 1 = delivered, 2 = read, 3 = failed
+	Attachment      *ChatwootAttachment // Opcional, gravado via ActiveStorage
+}
+
+// ChatwootAttachment representa um arquivo anexado gravado diretamente nas tabelas do ActiveStorage
+type ChatwootAttachment struct {
+	FileType    int // 0 = image, 1 = audio, 2 = video, 3 = file
+	FileName    string
+	ContentType string
+	Data        []byte
 }
 
 type ChatwootFKs struct {
@@ -93,3 +136,62 @@ type ChatwootFKs struct {
 	ConversationID int
 }
 
+
+// SyncCheckpoint registra até onde um chat já foi sincronizado
+type SyncCheckpoint struct {
+	ChatID               string
+	LastMessageTimestamp int64 // messageTimestamp da mensagem mais recente importada
+	LastMessageID        string
+	LastChatTimestamp    int64 // wa_lastMsgTimestamp do chat na última sincronização
+}
+
+// ChatwootExistingContact representa um contato já existente no Chatwoot (consultas somente leitura)
+type ChatwootExistingContact struct {
+	PhoneNumber    string
+	ContactID      int
+	ConversationID int // 0 se o contato ainda não tem conversa no inbox
+	Name           string
+	Identifier     string
+}
+
+// DuplicateMessage é um source_id do WhatsApp importado mais de uma vez no inbox.
+// Copies vem ordenado da cópia mais antiga para a mais recente.
+type DuplicateMessage struct {
+	SourceID string
+	Copies   []MessageCopy
+}
+
+// MessageCopy é uma das linhas de messages com o mesmo source_id
+type MessageCopy struct {
+	MessageID      int64
+	ConversationID int
+	CreatedAt      time.Time
+}
+
+// SyncRun é uma execução da sincronização registrada no diário, usada pelo rollback
+type SyncRun struct {
+	RunID        string
+	InboxID      int
+	StartedAt    time.Time
+	RolledBackAt *time.Time
+	Rows         int  // Linhas ainda registradas no diário da execução
+	Partial      bool // Desfeita, mas com linhas ou alterações mantidas no diário
+}
+
+// RollbackResult conta, por tabela, as linhas (ou alterações) registradas pela execução e as
+// apagadas (ou desfeitas) no rollback. Linhas ainda referenciadas por dados de outras execuções
+// (ou criados no Chatwoot) são mantidas, e o rollback fica parcial.
+type RollbackResult struct {
+	Recorded map[string]int
+	Deleted  map[string]int
+	Partial  bool
+}
+
+// UnixSeconds converte timestamps em milissegundos (como os da UAZAPI) para segundos; valores já
+// em segundos são mantidos
+func UnixSeconds(timestamp int64) int64 {
+	if timestamp > 10000000000 {
+		return timestamp / 1000
+	}
+	return timestamp
+}
@@ -0,0 +1,231 @@
+// Package phone normaliza números de telefone para o formato E.164 (+<código do país><número>),
+// usando o JID do WhatsApp como fonte de verdade e uma região padrão para números nacionais
+package phone
+
+import (
+	"sort"
+	"strings"
+)
+
+// region descreve como os números nacionais de um país são escritos
+type region struct {
+	countryCode string
+	trunkPrefix string // Prefixo de discagem nacional removido antes do número (ex.: 0 no Brasil)
+	minLength   int    // Tamanho do número nacional, sem o código do país
+	maxLength   int
+}
+
+// regions são as regiões aceitas como padrão, indexadas pelo código ISO 3166-1
+var regions = map[string]region{
+	// Países de língua portuguesa
+	"BR": {"55", "0", 10, 11},
+	"PT": {"351", "", 9, 9},
+	"AO": {"244", "", 9, 9},
+	"MZ": {"258", "", 8, 9},
+	"CV": {"238", "", 7, 7},
+	"GW": {"245", "", 7, 9},
+	"ST": {"239", "", 7, 7},
+	"TL": {"670", "", 7, 8},
+
+	// Américas
+	"US": {"1", "1", 10, 10},
+	"CA": {"1", "1", 10, 10},
+	"MX": {"52", "", 10, 10},
+	"AR": {"54", "0", 10, 11},
+	"BO": {"591", "0", 8, 8},
+	"CL": {"56", "", 9, 9},
+	"CO": {"57", "", 10, 10},
+	"EC": {"593", "0", 8, 9},
+	"PE": {"51", "0", 8, 9},
+	"PY": {"595", "0", 9, 9},
+	"UY": {"598", "0", 8, 8},
+	"VE": {"58", "0", 10, 10},
+
+	// Europa
+	"AT": {"43", "0", 4, 13},
+	"BE": {"32", "0", 8, 9},
+	"CH": {"41", "0", 9, 9},
+	"DE": {"49", "0", 6, 13},
+	"ES": {"34", "", 9, 9},
+	"FR": {"33", "0", 9, 9},
+	"GB": {"44", "0", 9, 10},
+	"IE": {"353", "0", 7, 9},
+	"IT": {"39", "", 6, 11},
+	"LU": {"352", "", 4, 11},
+	"NL": {"31", "0", 9, 9},
+
+	// Outros
+	"AU": {"61", "0", 9, 9},
+	"CN": {"86", "0", 9, 11},
+	"IL": {"972", "0", 8, 9},
+	"IN": {"91", "0", 10, 10},
+	"JP": {"81", "0", 9, 10},
+	"ZA": {"27", "0", 9, 9},
+}
+
+// countryCodes são os códigos de país atribuídos pela ITU-T (E.164), usados para validar
+// números internacionais
+var countryCodes = makeSet(strings.Fields(`
+	1 7 20 27 30 31 32 33 34 36 39 40 41 43 44 45 46 47 48 49 51 52 53 54 55 56 57 58
+	60 61 62 63 64 65 66 81 82 84 86 90 91 92 93 94 95 98
+	211 212 213 216 218 220 221 222 223 224 225 226 227 228 229 230 231 232 233 234 235
+	236 237 238 239 240 241 242 243 244 245 246 247 248 249 250 251 252 253 254 255 256
+	257 258 260 261 262 263 264 265 266 267 268 269 290 291 297 298 299
+	350 351 352 353 354 355 356 357 358 359 370 371 372 373 374 375 376 377 378 380 381
+	382 383 385 386 387 389 420 421 423 500 501 502 503 504 505 506 507 508 509 590 591
+	592 593 594 595 596 597 598 599 670 672 673 674 675 676 677 678 679 680 681 682 683
+	685 686 687 688 689 690 691 692 850 852 853 855 856 880 886 960 961 962 963 964 965
+	966 967 968 970 971 972 973 974 975 976 977 992 993 994 995 996 998
+`))
+
+func makeSet(values []string) map[string]bool {
+	set := make(map[string]bool, len(values))
+	for _, value := range values {
+		set[value] = true
+	}
+	return set
+}
+
+// IsSupportedRegion indica se a região pode ser usada como padrão
+func IsSupportedRegion(code string) bool {
+	_, ok := regions[strings.ToUpper(code)]
+	return ok
+}
+
+// SupportedRegions lista, em ordem alfabética, as regiões aceitas como padrão
+func SupportedRegions() []string {
+	codes := make([]string, 0, len(regions))
+	for code := range regions {
+		codes = append(codes, code)
+	}
+	sort.Strings(codes)
+	return codes
+}
+
+// Normalizer converte telefones em E.164. Números sem código do país são interpretados na
+// região padrão.
+type Normalizer struct {
+	region    region
+	hasRegion bool
+}
+
+// NewNormalizer cria um Normalizer para a região padrão informada. Com uma região desconhecida,
+// apenas números internacionais são aceitos.
+func NewNormalizer(defaultRegion string) *Normalizer {
+	r, ok := regions[strings.ToUpper(defaultRegion)]
+	return &Normalizer{region: r, hasRegion: ok}
+}
+
+// FromJID extrai o telefone em E.164 de um JID de usuário do WhatsApp ({número}@s.whatsapp.net,
+// com ou sem o sufixo de dispositivo). Retorna "" para LIDs, grupos e JIDs inválidos.
+func FromJID(jid string) string {
+	at := strings.LastIndex(jid, "@")
+	if at < 0 {
+		return ""
+	}
+	switch strings.ToLower(jid[at+1:]) {
+	case "s.whatsapp.net", "c.us":
+	default:
+		return ""
+	}
+
+	user := jid[:at]
+	if colon := strings.Index(user, ":"); colon >= 0 {
+		user = user[:colon]
+	}
+	if user == "" || strings.Trim(user, "0123456789") != "" {
+		return ""
+	}
+	return international(user)
+}
+
+// Normalize converte o telefone em E.164, retornando "" se ele não for um número válido.
+// Aceita JIDs, números com + ou 00 e números nacionais da região padrão, com ou sem
+// pontuação e prefixo de discagem nacional.
+func (n *Normalizer) Normalize(raw string) string {
+	raw = strings.TrimSpace(raw)
+	if raw == "" {
+		return ""
+	}
+	if strings.Contains(raw, "@") {
+		return FromJID(raw)
+	}
+
+	digits := digitsOnly(raw)
+	if strings.HasPrefix(raw, "+") {
+		return international(digits)
+	}
+	if strings.HasPrefix(digits, "00") {
+		return international(strings.TrimPrefix(digits, "00"))
+	}
+
+	if n.hasRegion {
+		if e164 := n.national(digits); e164 != "" {
+			return e164
+		}
+	}
+
+	// Números de outros países gravados sem o +
+	return international(digits)
+}
+
+// national interpreta os dígitos como um número da região padrão
+func (n *Normalizer) national(digits string) string {
+	r := n.region
+	fits := func(number string) bool {
+		return len(number) >= r.minLength && len(number) <= r.maxLength
+	}
+
+	// Já inclui o código do país (ex.: 5511999998888 no Brasil)
+	if strings.HasPrefix(digits, r.countryCode) && fits(digits[len(r.countryCode):]) {
+		return "+" + digits
+	}
+	if fits(digits) {
+		return "+" + r.countryCode + digits
+	}
+	if r.trunkPrefix != "" && strings.HasPrefix(digits, r.trunkPrefix) && fits(digits[len(r.trunkPrefix):]) {
+		return "+" + r.countryCode + digits[len(r.trunkPrefix):]
+	}
+	return ""
+}
+
+// international valida os dígitos de um número com código do país e o retorna em E.164
+func international(digits string) string {
+	if len(digits) < 8 || len(digits) > 15 {
+		return ""
+	}
+	for size := 1; size <= 3; size++ {
+		if countryCodes[digits[:size]] {
+			return "+" + digits
+		}
+	}
+	return ""
+}
+
+// Alternatives retorna as outras grafias do mesmo número usadas para encontrar contatos já
+// existentes. Celulares brasileiros podem estar gravados com ou sem o nono dígito: o JID de
+// contas antigas do WhatsApp não tem o 9, enquanto cadastros manuais costumam tê-lo.
+func Alternatives(e164 string) []string {
+	if !strings.HasPrefix(e164, "+55") {
+		return nil
+	}
+
+	number := strings.TrimPrefix(e164, "+55")
+	switch {
+	case len(number) == 11 && number[2] == '9':
+		return []string{"+55" + number[:2] + number[3:]}
+	case len(number) == 10 && number[2] >= '6' && number[2] <= '9':
+		return []string{"+55" + number[:2] + "9" + number[2:]}
+	}
+	return nil
+}
+
+func digitsOnly(value string) string {
+	var b strings.Builder
+	for _, r := range value {
+		if r >= '0' && r <= '9' {
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,78 @@
+package phone
+
+import (
+	"testing"
+)
+
+func TestNormalize(t *testing.T) {
+	tests := []struct {
+		name   string
+		region string
+		raw    string
+		want   string
+	}{
+		{"nacional com prefixo 0", "BR", "011 98765-4321", "+5511987654321"},
+		{"nacional com código do país sem +", "BR", "5511987654321", "+5511987654321"},
+		{"outro país sem +", "BR", "351912345678", "+351912345678"},
+		{"internacional com 00", "BR", "0044 20 7946 0958", "+442079460958"},
+		{"EUA com prefixo 1", "US", "1 415 555 2671", "+14155552671"},
+		{"região desconhecida com número nacional", "XX", "98765", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := NewNormalizer(tt.region).Normalize(tt.raw); got != tt.want {
+				t.Errorf("Normalize(%q) na região %s = %q, esperado %q", tt.raw, tt.region, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNinthDigit(t *testing.T) {
+	br := NewNormalizer("BR")
+
+	// Conta antiga: o JID não tem o nono dígito, mas o cadastro manual no Chatwoot tem
+	tests := []struct {
+		name   string
+		jid    string
+		manual string
+	}{
+		{"JID sem o 9", "551187654321@s.whatsapp.net", "(11) 98765-4321"},
+		{"JID com o 9", "5511987654321:7@s.whatsapp.net", "11 8765-4321"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fromJID := FromJID(tt.jid)
+			manual := br.Normalize(tt.manual)
+			if fromJID == "" || manual == "" || fromJID == manual {
+				t.Fatalf("FromJID(%q) = %q e Normalize(%q) = %q, esperadas duas grafias válidas", tt.jid, fromJID, tt.manual, manual)
+			}
+			if alternatives := Alternatives(fromJID); len(alternatives) != 1 || alternatives[0] != manual {
+				t.Errorf("Alternatives(%q) = %v, esperado [%s]", fromJID, alternatives, manual)
+			}
+			if alternatives := Alternatives(manual); len(alternatives) != 1 || alternatives[0] != fromJID {
+				t.Errorf("Alternatives(%q) = %v, esperado [%s]", manual, alternatives, fromJID)
+			}
+		})
+	}
+
+	// Fixos e números de 11 dígitos sem o 9 não ganham outra grafia
+	for _, e164 := range []string{"+551133334444", "+5511887654321"} {
+		if alternatives := Alternatives(e164); alternatives != nil {
+			t.Errorf("Alternatives(%q) = %v, esperado nenhuma", e164, alternatives)
+		}
+	}
+}
+
+func TestLIDJIDs(t *testing.T) {
+	// LIDs não são telefones, mesmo quando os dígitos parecem um número válido
+	for _, jid := range []string{"123456789012345@lid", "5511987654321@lid", "5511987654321:12@lid"} {
+		if got := FromJID(jid); got != "" {
+			t.Errorf("FromJID(%q) = %q, esperado vazio", jid, got)
+		}
+		if got := NewNormalizer("BR").Normalize(jid); got != "" {
+			t.Errorf("Normalize(%q) = %q, esperado vazio", jid, got)
+		}
+	}
+}
@@ -0,0 +1,71 @@
+package storage
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// LocalStorage grava arquivos no layout do ActiveStorage::Service::DiskService
+type LocalStorage struct {
+	root        string
+	serviceName string
+}
+
+func NewLocalStorage(root, serviceName string) (*LocalStorage, error) {
+	info, err := os.Stat(root)
+	if err != nil {
+		return nil, fmt.Errorf("failed to access storage path %s: %w", root, err)
+	}
+	if !info.IsDir() {
+		return nil, fmt.Errorf("storage path %s is not a directory", root)
+	}
+
+	return &LocalStorage{
+		root:        root,
+		serviceName: serviceName,
+	}, nil
+}
+
+func (s *LocalStorage) ServiceName() string {
+	return s.serviceName
+}
+
+// path retorna o caminho do arquivo da chave: {root}/{key[0:2]}/{key[2:4]}/{key}
+func (s *LocalStorage) path(key string) (string, error) {
+	if len(key) < 4 {
+		return "", fmt.Errorf("invalid storage key %q", key)
+	}
+	return filepath.Join(s.root, key[0:2], key[2:4], key), nil
+}
+
+// Put grava o arquivo em {root}/{key[0:2]}/{key[2:4]}/{key}, como o DiskService do Rails
+func (s *LocalStorage) Put(key string, data []byte, contentType string) error {
+	path, err := s.path(key)
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("failed to create storage directory: %w", err)
+	}
+
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		return fmt.Errorf("failed to write file: %w", err)
+	}
+
+	return nil
+}
+
+// Delete remove o arquivo gravado sob a chave
+func (s *LocalStorage) Delete(key string) error {
+	path, err := s.path(key)
+	if err != nil {
+		return err
+	}
+
+	if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to delete file: %w", err)
+	}
+	return nil
+}
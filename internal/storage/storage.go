@@ -0,0 +1,31 @@
+package storage
+
+import (
+	"chatwoot-sync-go/internal/config"
+	"fmt"
+)
+
+// Storage grava arquivos no mesmo serviço usado pelo ActiveStorage do Chatwoot
+type Storage interface {
+	// ServiceName retorna o valor gravado em active_storage_blobs.service_name
+	ServiceName() string
+	// Put grava o conteúdo do arquivo sob a chave do blob
+	Put(key string, data []byte, contentType string) error
+	// Delete remove o arquivo da chave; uma chave inexistente não é erro
+	Delete(key string) error
+}
+
+// New cria o storage configurado, ou retorna nil se nenhum foi configurado
+func New(cfg *config.Config) (Storage, error) {
+	storageCfg := cfg.Chatwoot.Storage
+	if storageCfg.Path == "" {
+		return nil, nil
+	}
+
+	switch storageCfg.Service {
+	case "local":
+		return NewLocalStorage(storageCfg.Path, storageCfg.Service)
+	default:
+		return nil, fmt.Errorf("unsupported storage service %q (supported: local)", storageCfg.Service)
+	}
+}
@@ -0,0 +1,152 @@
+package sync
+
+import (
+	"chatwoot-sync-go/internal/chatwoot"
+	"chatwoot-sync-go/internal/models"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"mime"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// maxAvatarSize limita o tamanho das fotos de perfil baixadas
+const maxAvatarSize = 5 << 20
+
+// errAvatarExpired indica que a URL da foto no CDN do WhatsApp expirou ou foi removida
+var errAvatarExpired = errors.New("avatar URL expired")
+
+// chatAvatarURL retorna a URL da foto de perfil do chat, preferindo a imagem completa à miniatura
+func chatAvatarURL(chat models.UAZAPIChat) string {
+	for _, imageURL := range []string{chat.Image, chat.ImagePreview} {
+		imageURL = strings.TrimSpace(imageURL)
+		if strings.HasPrefix(imageURL, "https://") || strings.HasPrefix(imageURL, "http://") {
+			return imageURL
+		}
+	}
+	return ""
+}
+
+// avatarSource identifica a foto pela URL sem a query string, que no CDN do WhatsApp traz a
+// assinatura e a validade do link e muda mesmo quando a foto é a mesma
+func avatarSource(imageURL string) string {
+	parsed, err := url.Parse(imageURL)
+	if err != nil {
+		return imageURL
+	}
+	parsed.RawQuery = ""
+	parsed.Fragment = ""
+	return parsed.String()
+}
+
+// syncContactAvatar importa a foto de perfil do chat como avatar do contato, se ela mudou desde a
+// última importação. Falhas apenas geram avisos: o avatar não impede a sincronização do chat.
+func (s *Service) syncContactAvatar(ctx context.Context, contactID int, chat models.UAZAPIChat) {
+	if !s.cfg.Sync.ImportAvatars || s.cfg.Sync.DryRun || contactID == 0 {
+		return
+	}
+	if !s.chatwoot.HasStorage() && !s.api.IsConfigured() {
+		return
+	}
+
+	imageURL := chatAvatarURL(chat)
+	if imageURL == "" {
+		return
+	}
+	source := avatarSource(imageURL)
+
+	current, err := s.chatwoot.ContactAvatarSource(ctx, contactID)
+	if err != nil {
+		log.Printf("Warning: %v", err)
+		return
+	}
+	if current == source {
+		return
+	}
+
+	avatar, err := downloadAvatar(ctx, imageURL)
+	if errors.Is(err, errAvatarExpired) {
+		// A próxima listagem de chats traz um link novo
+		log.Printf("Skipping avatar of contact %d: %v", contactID, err)
+		return
+	}
+	if err != nil {
+		log.Printf("Warning: failed to download avatar of contact %d: %v", contactID, err)
+		return
+	}
+
+	if s.chatwoot.HasStorage() {
+		err = s.chatwoot.SetContactAvatar(ctx, contactID, avatar, source)
+	} else {
+		err = s.updateAvatarViaAPI(ctx, contactID, avatar, source, current)
+	}
+	if err != nil {
+		log.Printf("Warning: failed to update avatar of contact %d: %v", contactID, err)
+		return
+	}
+
+	s.addStatsAvatarsUpdated(1)
+}
+
+// updateAvatarViaAPI envia a foto pela API do Chatwoot, quando não há storage, e registra a origem
+// dela e a troca no diário da execução
+func (s *Service) updateAvatarViaAPI(ctx context.Context, contactID int, avatar *models.ChatwootAttachment, source, previousSource string) error {
+	previousBlobIDs, err := s.chatwoot.ContactAvatarBlobIDs(ctx, contactID)
+	if err != nil {
+		return err
+	}
+	if err := s.api.UpdateContactAvatar(ctx, contactID, avatar); err != nil {
+		return err
+	}
+	if err := s.chatwoot.SetContactAvatarSource(ctx, contactID, source); err != nil {
+		return err
+	}
+	return s.chatwoot.RecordContactAvatar(ctx, contactID, previousBlobIDs, previousSource)
+}
+
+// downloadAvatar baixa a foto de perfil. Links expirados ou removidos do CDN retornam errAvatarExpired.
+func downloadAvatar(ctx context.Context, imageURL string) (*models.ChatwootAttachment, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", imageURL, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create download request: %w", err)
+	}
+	resp, err := mediaHTTPClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to download avatar: %w", err)
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+	case http.StatusForbidden, http.StatusNotFound, http.StatusGone:
+		return nil, fmt.Errorf("%w (status %d)", errAvatarExpired, resp.StatusCode)
+	default:
+		return nil, fmt.Errorf("failed to download avatar: status %d", resp.StatusCode)
+	}
+
+	data, err := io.ReadAll(io.LimitReader(resp.Body, maxAvatarSize+1))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read avatar: %w", err)
+	}
+	if len(data) == 0 || len(data) > maxAvatarSize {
+		return nil, fmt.Errorf("invalid avatar size: %d bytes", len(data))
+	}
+
+	contentType, _, err := mime.ParseMediaType(resp.Header.Get("Content-Type"))
+	if err != nil || !strings.HasPrefix(contentType, "image/") {
+		contentType = http.DetectContentType(data)
+	}
+	if !strings.HasPrefix(contentType, "image/") {
+		return nil, fmt.Errorf("avatar is not an image (%s)", contentType)
+	}
+
+	return &models.ChatwootAttachment{
+		FileName:    "avatar" + chatwoot.ExtensionFromMimeType(contentType),
+		ContentType: contentType,
+		Data:        data,
+	}, nil
+}
@@ -0,0 +1,87 @@
+package sync
+
+import (
+	"chatwoot-sync-go/internal/models"
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestChatAvatarURL(t *testing.T) {
+	tests := []struct {
+		name string
+		chat models.UAZAPIChat
+		want string
+	}{
+		{"sem foto", models.UAZAPIChat{}, ""},
+		{"só espaços", models.UAZAPIChat{Image: "  ", ImagePreview: "\n"}, ""},
+		{"imagem não é URL", models.UAZAPIChat{Image: "changed", ImagePreview: "https://pps.whatsapp.net/v/preview.jpg"}, "https://pps.whatsapp.net/v/preview.jpg"},
+		{"data URL ignorada", models.UAZAPIChat{Image: "data:image/jpeg;base64,AAAA"}, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := chatAvatarURL(tt.chat); got != tt.want {
+				t.Errorf("chatAvatarURL() = %q, esperado %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestAvatarSource(t *testing.T) {
+	// Links renovados da mesma foto têm a mesma origem, e a foto não é baixada de novo
+	first := avatarSource("https://pps.whatsapp.net/v/t61/123_n.jpg?oh=01_abc&oe=65A1B2C3")
+	renewed := avatarSource("https://pps.whatsapp.net/v/t61/123_n.jpg?oh=01_def&oe=65B2C3D4")
+	if first != renewed {
+		t.Errorf("links renovados com origens diferentes: %q e %q", first, renewed)
+	}
+	if other := avatarSource("https://pps.whatsapp.net/v/t61/456_n.jpg?oh=01_abc&oe=65A1B2C3"); other == first {
+		t.Errorf("fotos diferentes com a mesma origem %q", other)
+	}
+}
+
+func TestDownloadAvatar(t *testing.T) {
+	jpeg := []byte("\xff\xd8\xff\xe0\x00\x10JFIF\x00")
+	tests := []struct {
+		name        string
+		status      int
+		contentType string
+		body        []byte
+		wantExpired bool
+		wantErr     bool
+		wantFile    string
+	}{
+		{"link expirado", http.StatusForbidden, "", nil, true, true, ""},
+		{"foto removida", http.StatusNotFound, "", nil, true, true, ""},
+		{"erro do CDN não é expiração", http.StatusBadGateway, "", nil, false, true, ""},
+		{"resposta vazia", http.StatusOK, "image/jpeg", nil, false, true, ""},
+		{"não é imagem", http.StatusOK, "text/html", []byte("<html></html>"), false, true, ""},
+		{"tipo detectado pelo conteúdo", http.StatusOK, "application/octet-stream", jpeg, false, false, "avatar.jpg"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if tt.contentType != "" {
+					w.Header().Set("Content-Type", tt.contentType)
+				}
+				w.WriteHeader(tt.status)
+				w.Write(tt.body)
+			}))
+			defer server.Close()
+
+			avatar, err := downloadAvatar(context.Background(), server.URL+"/v/t61/123_n.jpg?oe=65A1B2C3")
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("downloadAvatar() erro = %v, esperado erro %v", err, tt.wantErr)
+			}
+			if errors.Is(err, errAvatarExpired) != tt.wantExpired {
+				t.Errorf("downloadAvatar() erro = %v, esperado expirado %v", err, tt.wantExpired)
+			}
+			if err == nil && avatar.FileName != tt.wantFile {
+				t.Errorf("FileName = %q, esperado %q", avatar.FileName, tt.wantFile)
+			}
+		})
+	}
+}
@@ -0,0 +1,89 @@
+package sync
+
+import (
+	"chatwoot-sync-go/internal/models"
+	"context"
+	"log"
+)
+
+// loadCheckpoints carrega os checkpoints do inbox quando a sincronização incremental está habilitada
+func (s *Service) loadCheckpoints(ctx context.Context, inboxID int) error {
+	s.setCheckpoints(nil)
+	if !s.cfg.Sync.Incremental {
+		log.Println("Incremental sync disabled, all messages will be fetched")
+		return nil
+	}
+
+	// Em dry-run a tabela não é criada; sem ela, todos os chats são tratados como novos
+	if s.cfg.Sync.DryRun {
+		exists, err := s.chatwoot.HasCheckpointTable(ctx)
+		if err != nil {
+			return err
+		}
+		if !exists {
+			s.setCheckpoints(make(map[string]models.SyncCheckpoint))
+			return nil
+		}
+	} else if err := s.chatwoot.EnsureCheckpointTable(ctx); err != nil {
+		return err
+	}
+
+	checkpoints, err := s.chatwoot.GetCheckpoints(ctx, inboxID)
+	if err != nil {
+		return err
+	}
+	s.setCheckpoints(checkpoints)
+	return nil
+}
+
+// setCheckpoints substitui os checkpoints carregados em memória
+func (s *Service) setCheckpoints(checkpoints map[string]models.SyncCheckpoint) {
+	s.checkpointsMutex.Lock()
+	defer s.checkpointsMutex.Unlock()
+	s.checkpoints = checkpoints
+}
+
+// getCheckpoint retorna o checkpoint do chat, se houver
+func (s *Service) getCheckpoint(chatID string) (models.SyncCheckpoint, bool) {
+	s.checkpointsMutex.RLock()
+	defer s.checkpointsMutex.RUnlock()
+	cp, ok := s.checkpoints[chatID]
+	return cp, ok
+}
+
+// isChatUnchanged indica se o chat não recebeu mensagens desde a última sincronização
+func (s *Service) isChatUnchanged(chat models.UAZAPIChat, chatID string) bool {
+	cp, ok := s.getCheckpoint(chatID)
+	if !ok || chat.WALastMsgTimestamp == 0 {
+		return false
+	}
+	return chat.WALastMsgTimestamp <= cp.LastChatTimestamp
+}
+
+// saveCheckpoint registra a mensagem mais recente sincronizada e o timestamp do chat
+func (s *Service) saveCheckpoint(ctx context.Context, chatID string, chatTimestamp int64, newest *models.UAZAPIMessage, inboxID int) {
+	if !s.cfg.Sync.Incremental || s.cfg.Sync.DryRun {
+		return
+	}
+
+	cp, _ := s.getCheckpoint(chatID)
+	cp.ChatID = chatID
+	if chatTimestamp > cp.LastChatTimestamp {
+		cp.LastChatTimestamp = chatTimestamp
+	}
+	if newest != nil && newest.MessageTimestamp >= cp.LastMessageTimestamp {
+		cp.LastMessageTimestamp = newest.MessageTimestamp
+		cp.LastMessageID = newest.MessageID
+	}
+
+	if err := s.chatwoot.SaveCheckpoint(ctx, inboxID, cp); err != nil {
+		log.Printf("Warning: failed to save checkpoint for chat %s: %v", chatID, err)
+		return
+	}
+
+	s.checkpointsMutex.Lock()
+	if s.checkpoints != nil {
+		s.checkpoints[chatID] = cp
+	}
+	s.checkpointsMutex.Unlock()
+}
@@ -0,0 +1,74 @@
+package sync
+
+import (
+	"chatwoot-sync-go/internal/webhook"
+	"log"
+	"math/rand"
+	"time"
+)
+
+// Run mantém o serviço em execução contínua, repetindo a sincronização incremental
+// a cada intervalo configurado até que Stop seja chamado
+func (s *Service) Run() error {
+	s.wg.Add(1)
+	defer s.wg.Done()
+
+	ctx := s.ctx
+	if err := s.connect(ctx); err != nil {
+		if ctx.Err() != nil {
+			return nil
+		}
+		return err
+	}
+	defer s.chatwoot.Close()
+
+	// Cada ciclo é uma execução no diário; o webhook registra no ciclo em andamento (ou no último)
+	if err := s.startRun(ctx); err != nil {
+		return err
+	}
+
+	// Receber mensagens em tempo real enquanto os ciclos periódicos cobrem eventuais lacunas
+	if s.cfg.Webhook.Enabled {
+		server := webhook.NewServer(s.cfg, s)
+		server.Start(ctx)
+		defer func() {
+			if err := server.Shutdown(10 * time.Second); err != nil {
+				log.Printf("Warning: failed to shut down webhook server: %v", err)
+			}
+		}()
+	}
+
+	interval := time.Duration(s.cfg.Sync.IntervalSeconds) * time.Second
+	jitter := time.Duration(s.cfg.Sync.IntervalJitterSeconds) * time.Second
+	random := rand.New(rand.NewSource(time.Now().UnixNano()))
+
+	log.Printf("Daemon mode enabled: syncing every %s (jitter up to %s)", interval, jitter)
+
+	for {
+		cycleStart := time.Now()
+		if err := s.runCycle(ctx); err != nil && ctx.Err() == nil {
+			// Em modo daemon, uma falha no ciclo não encerra o serviço
+			log.Printf("Sync cycle failed: %v", err)
+		}
+		log.Printf("Sync cycle finished in %s", time.Since(cycleStart).Round(time.Second))
+
+		wait := interval
+		if jitter > 0 {
+			wait += time.Duration(random.Int63n(int64(jitter)))
+		}
+		log.Printf("Next sync cycle in %s", wait.Round(time.Second))
+
+		timer := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			log.Println("Daemon stopped")
+			return nil
+		case <-timer.C:
+		}
+
+		if err := s.startRun(ctx); err != nil {
+			log.Printf("Warning: failed to start a new sync run, rows keep being recorded in the previous one: %v", err)
+		}
+	}
+}
@@ -0,0 +1,98 @@
+package sync
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+)
+
+// dedupeBatchSize limita quantas mensagens são apagadas por transação
+const dedupeBatchSize = 500
+
+// Dedupe procura source_ids do WhatsApp importados mais de uma vez no inbox (inclusive em
+// conversas diferentes do mesmo contato) e imprime um relatório. Com apply, apaga as cópias
+// extras, mantendo a mais antiga de cada source_id.
+func (s *Service) Dedupe(apply bool) error {
+	s.wg.Add(1)
+	defer s.wg.Done()
+
+	ctx := s.ctx
+	if err := s.connect(ctx); err != nil {
+		return err
+	}
+	defer s.chatwoot.Close()
+
+	duplicates, err := s.chatwoot.FindDuplicateMessages(ctx, s.inboxID)
+	if err != nil {
+		return err
+	}
+
+	var extras []int64
+	keptIDs := make(map[int64]int64)
+	crossConversation := 0
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "SOURCE_ID\tMANTIDA\tCONVERSA\tEXTRAS\tCONVERSAS DAS EXTRAS")
+	for _, duplicate := range duplicates {
+		kept := duplicate.Copies[0]
+		extraIDs := make([]string, 0, len(duplicate.Copies)-1)
+		conversations := make([]string, 0, len(duplicate.Copies)-1)
+		otherConversation := false
+		for _, extra := range duplicate.Copies[1:] {
+			extras = append(extras, extra.MessageID)
+			keptIDs[extra.MessageID] = kept.MessageID
+			extraIDs = append(extraIDs, strconv.FormatInt(extra.MessageID, 10))
+			conversations = append(conversations, strconv.Itoa(extra.ConversationID))
+			if extra.ConversationID != kept.ConversationID {
+				otherConversation = true
+			}
+		}
+		if otherConversation {
+			crossConversation++
+		}
+
+		fmt.Fprintf(w, "%s\t%d\t%d\t%s\t%s\n", duplicate.SourceID, kept.MessageID, kept.ConversationID,
+			strings.Join(extraIDs, ","), strings.Join(conversations, ","))
+	}
+	w.Flush()
+
+	deleted := 0
+	if apply && !s.cfg.Sync.DryRun {
+		for start := 0; start < len(extras); start += dedupeBatchSize {
+			end := start + dedupeBatchSize
+			if end > len(extras) {
+				end = len(extras)
+			}
+			batch := make(map[int64]int64, end-start)
+			for _, extraID := range extras[start:end] {
+				batch[extraID] = keptIDs[extraID]
+			}
+			count, err := s.chatwoot.DeleteDuplicateMessages(ctx, batch)
+			if err != nil {
+				return fmt.Errorf("failed to delete duplicate messages (%d deleted so far): %w", deleted, err)
+			}
+			deleted += count
+		}
+	}
+
+	log.Println("")
+	log.Println("========================================")
+	log.Println("       MENSAGENS DUPLICADAS")
+	log.Println("========================================")
+	log.Printf("Source IDs duplicados:             %d", len(duplicates))
+	log.Printf("Em conversas diferentes:           %d", crossConversation)
+	log.Printf("Cópias extras:                     %d", len(extras))
+	switch {
+	case apply && s.cfg.Sync.DryRun:
+		log.Println("Dry-run: nenhuma mensagem foi apagada.")
+	case apply:
+		log.Printf("Cópias apagadas:                   %d", deleted)
+	default:
+		log.Println("Nenhuma mensagem foi apagada (use --delete para remover as cópias extras).")
+	}
+	log.Println("========================================")
+	log.Println("")
+	return nil
+}
@@ -0,0 +1,246 @@
+package sync
+
+import (
+	"chatwoot-sync-go/internal/chatwoot"
+	"chatwoot-sync-go/internal/models"
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"sort"
+	"strings"
+	"text/tabwriter"
+)
+
+// chatPlan descreve o que a sincronização faria em um chat no modo dry-run
+type chatPlan struct {
+	ChatID               string
+	Name                 string
+	CreateContact        bool
+	CreateConversation   bool
+	NameUpdate           string // Novo nome, se o nome atual do contato seria substituído
+	MessagesToInsert     int
+	MediaToInsert        int
+	ParticipantsToCreate int
+}
+
+// planContacts resolve os contatos do lote sem gravar nada, registrando no plano o que seria criado.
+// Retorna FKs com IDs zerados para contatos/conversas que ainda não existem.
+func (s *Service) planContacts(
+	ctx context.Context,
+	contacts []models.ChatwootContact,
+	chatMap map[string]models.UAZAPIChat,
+	inboxID int,
+) (map[string]*models.ChatwootFKs, error) {
+	existing, err := s.chatwoot.FindContactsAndConversations(ctx, contacts, inboxID)
+	if err != nil {
+		return nil, err
+	}
+
+	fksMap := make(map[string]*models.ChatwootFKs, len(contacts))
+	for _, contact := range contacts {
+		chat := chatMap[contact.PhoneNumber]
+		plan := s.chatPlan(resolveChatID(chat))
+		plan.Name = contact.Name
+
+		fks := &models.ChatwootFKs{PhoneNumber: contact.PhoneNumber}
+		if found, ok := existing[contact.PhoneNumber]; ok {
+			fks.ContactID = found.ContactID
+			fks.ConversationID = found.ConversationID
+			plan.CreateConversation = found.ConversationID == 0
+			if wouldUpdateName(found.Name, contact) {
+				plan.NameUpdate = contact.Name
+			}
+		} else {
+			plan.CreateContact = true
+			plan.CreateConversation = true
+		}
+		fksMap[contact.PhoneNumber] = fks
+	}
+
+	return fksMap, nil
+}
+
+// planContact resolve o contato e a conversa de um chat individual sem gravar nada
+func (s *Service) planContact(
+	ctx context.Context,
+	db *chatwoot.Database,
+	contact models.ChatwootContact,
+	chat models.UAZAPIChat,
+	inboxID int,
+) (*models.ChatwootFKs, error) {
+	if contact.PhoneNumber == "" {
+		return s.planLIDContact(ctx, contact, chat, inboxID)
+	}
+
+	contact, err := s.matchContactPhone(ctx, db, contact)
+	if err != nil {
+		return nil, err
+	}
+	fksMap, err := s.planContacts(ctx, []models.ChatwootContact{contact},
+		map[string]models.UAZAPIChat{contact.PhoneNumber: chat}, inboxID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create contact: %w", err)
+	}
+	return fksMap[contact.PhoneNumber], nil
+}
+
+// planLIDContact resolve sem gravar nada o contato conhecido apenas pelo LID
+func (s *Service) planLIDContact(ctx context.Context, contact models.ChatwootContact, chat models.UAZAPIChat, inboxID int) (*models.ChatwootFKs, error) {
+	existing, err := s.chatwoot.FindLIDConversation(ctx, contact.LID, inboxID)
+	if err != nil {
+		return nil, err
+	}
+
+	plan := s.chatPlan(resolveChatID(chat))
+	plan.Name = contact.Name
+
+	fks := &models.ChatwootFKs{}
+	if existing == nil {
+		plan.CreateContact = true
+		plan.CreateConversation = true
+		return fks, nil
+	}
+
+	fks.ContactID = existing.ContactID
+	fks.ConversationID = existing.ConversationID
+	plan.CreateConversation = existing.ConversationID == 0
+	return fks, nil
+}
+
+// planGroup resolve o contato e a conversa do grupo sem gravar nada
+func (s *Service) planGroup(ctx context.Context, group models.ChatwootContact, inboxID int) (*models.ChatwootFKs, error) {
+	existing, err := s.chatwoot.FindGroupConversation(ctx, group.Identifier, inboxID)
+	if err != nil {
+		return nil, err
+	}
+
+	plan := s.chatPlan(group.Identifier)
+	plan.Name = group.Name
+
+	fks := &models.ChatwootFKs{}
+	if existing == nil {
+		plan.CreateContact = true
+		plan.CreateConversation = true
+		return fks, nil
+	}
+
+	fks.ContactID = existing.ContactID
+	fks.ConversationID = existing.ConversationID
+	plan.CreateConversation = existing.ConversationID == 0
+	if existing.Name != group.Name {
+		plan.NameUpdate = group.Name
+	}
+	return fks, nil
+}
+
+// planParticipants conta os participantes do grupo que ainda não existem como contato
+func (s *Service) planParticipants(ctx context.Context, chatID string, contacts []models.ChatwootContact) (map[string]int, error) {
+	existing, err := s.chatwoot.FindContacts(ctx, contacts)
+	if err != nil {
+		return nil, err
+	}
+
+	s.chatPlan(chatID).ParticipantsToCreate = len(contacts) - len(existing)
+	return existing, nil
+}
+
+// planMessages registra quantas mensagens seriam inseridas no chat
+func (s *Service) planMessages(chatID string, messages, media int) {
+	plan := s.chatPlan(chatID)
+	plan.MessagesToInsert += messages
+	plan.MediaToInsert += media
+}
+
+// chatPlan retorna (criando se necessário) o plano do chat
+func (s *Service) chatPlan(chatID string) *chatPlan {
+	s.statsMutex.Lock()
+	defer s.statsMutex.Unlock()
+
+	plan, exists := s.plans[chatID]
+	if !exists {
+		plan = &chatPlan{ChatID: chatID}
+		s.plans[chatID] = plan
+	}
+	return plan
+}
+
+// wouldUpdateName replica a regra de UpdateContactNames: só substitui nomes vazios ou iguais ao número
+func wouldUpdateName(currentName string, contact models.ChatwootContact) bool {
+	newName := strings.TrimSpace(contact.Name)
+	if newName == "" || newName == currentName {
+		return false
+	}
+	return currentName == "" || currentName == strings.TrimPrefix(contact.PhoneNumber, "+")
+}
+
+// resolveChatID retorna o JID usado para identificar o chat
+func resolveChatID(chat models.UAZAPIChat) string {
+	if chat.WAChatID != "" {
+		return chat.WAChatID
+	}
+	return chat.WAChatLID
+}
+
+// printPlan imprime o plano por chat do modo dry-run
+func (s *Service) printPlan() {
+	s.statsMutex.Lock()
+	plans := make([]*chatPlan, 0, len(s.plans))
+	for _, plan := range s.plans {
+		plans = append(plans, plan)
+	}
+	s.statsMutex.Unlock()
+
+	sort.Slice(plans, func(i, j int) bool {
+		return plans[i].ChatID < plans[j].ChatID
+	})
+
+	contactsToCreate := 0
+	conversationsToCreate := 0
+	messagesToInsert := 0
+	namesToUpdate := 0
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "CHAT\tNOME\tCONTATO\tCONVERSA\tMENSAGENS\tMÍDIAS\tPARTICIPANTES\tNOVO NOME")
+	for _, plan := range plans {
+		if !plan.CreateContact && !plan.CreateConversation && plan.NameUpdate == "" &&
+			plan.MessagesToInsert == 0 && plan.ParticipantsToCreate == 0 {
+			continue
+		}
+
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%d\t%d\t%d\t%s\n",
+			plan.ChatID, plan.Name, planAction(plan.CreateContact), planAction(plan.CreateConversation),
+			plan.MessagesToInsert, plan.MediaToInsert, plan.ParticipantsToCreate, plan.NameUpdate)
+
+		if plan.CreateContact {
+			contactsToCreate++
+		}
+		if plan.CreateConversation {
+			conversationsToCreate++
+		}
+		if plan.NameUpdate != "" {
+			namesToUpdate++
+		}
+		messagesToInsert += plan.MessagesToInsert
+	}
+	w.Flush()
+
+	log.Println("")
+	log.Println("========================================")
+	log.Println("      PLANO DE SINCRONIZAÇÃO (DRY-RUN)")
+	log.Println("========================================")
+	log.Printf("Contatos a criar:                  %d", contactsToCreate)
+	log.Printf("Conversas a criar:                 %d", conversationsToCreate)
+	log.Printf("Mensagens a inserir:               %d", messagesToInsert)
+	log.Printf("Nomes a atualizar:                 %d", namesToUpdate)
+	log.Println("Nenhuma alteração foi gravada.")
+	log.Println("========================================")
+	log.Println("")
+}
+
+func planAction(create bool) string {
+	if create {
+		return "criar"
+	}
+	return "existente"
+}
@@ -0,0 +1,33 @@
+package sync
+
+import (
+	"chatwoot-sync-go/internal/models"
+	"testing"
+)
+
+func TestWouldUpdateName(t *testing.T) {
+	tests := []struct {
+		name        string
+		currentName string
+		phone       string
+		newName     string
+		want        bool
+	}{
+		{"contato sem nome", "", "+5511987654321", "Maria", true},
+		{"nome igual ao número", "5511987654321", "+5511987654321", "Maria", true},
+		{"nome é a grafia sem o nono dígito", "551187654321", "+551187654321", "Maria", true},
+		{"nome com + não é substituído, como no banco", "+5511987654321", "+5511987654321", "Maria", false},
+		{"número de outro contato", "5511912345678", "+5511987654321", "Maria", false},
+		{"nome já definido", "Maria Silva", "+5511987654321", "Maria", false},
+		{"nome novo só com espaços", "5511987654321", "+5511987654321", "   ", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			contact := models.ChatwootContact{Name: tt.newName, PhoneNumber: tt.phone}
+			if got := wouldUpdateName(tt.currentName, contact); got != tt.want {
+				t.Errorf("wouldUpdateName(%q, %q) = %v, esperado %v", tt.currentName, tt.newName, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,140 @@
+package sync
+
+import (
+	"chatwoot-sync-go/internal/config"
+	"chatwoot-sync-go/internal/models"
+	"chatwoot-sync-go/internal/uazapi"
+	"strings"
+)
+
+// chatFilter seleciona quais chats e mensagens são sincronizados (variáveis SYNC_CHATS_*,
+// SYNC_MESSAGES_SINCE, SYNC_INCLUDE/EXCLUDE_CHATS e SYNC_INCLUDE_ARCHIVED)
+type chatFilter struct {
+	since           int64 // Unix em segundos; 0 = sem limite
+	until           int64
+	messagesSince   int64
+	include         map[string]bool
+	exclude         map[string]bool
+	includeArchived bool
+}
+
+func newChatFilter(cfg config.SyncConfig) *chatFilter {
+	f := &chatFilter{
+		include:         filterKeys(cfg.IncludeChats),
+		exclude:         filterKeys(cfg.ExcludeChats),
+		includeArchived: cfg.IncludeArchived,
+	}
+	if !cfg.ChatsSince.IsZero() {
+		f.since = cfg.ChatsSince.Unix()
+	}
+	if !cfg.ChatsUntil.IsZero() {
+		f.until = cfg.ChatsUntil.Unix()
+	}
+	if !cfg.MessagesSince.IsZero() {
+		f.messagesSince = cfg.MessagesSince.Unix()
+	}
+	return f
+}
+
+// query retorna os filtros que podem ser aplicados já na listagem da UAZAPI
+func (f *chatFilter) query(isGroup bool) uazapi.ChatFilter {
+	return uazapi.ChatFilter{
+		IsGroup:         isGroup,
+		ExcludeArchived: !f.includeArchived,
+		Since:           f.since,
+	}
+}
+
+// allowsChat informa se o chat passa por todos os filtros
+func (f *chatFilter) allowsChat(chat models.UAZAPIChat) bool {
+	if chat.WAArchived && !f.includeArchived {
+		return false
+	}
+	lastMessage := models.UnixSeconds(chat.WALastMsgTimestamp)
+	if f.since > 0 && lastMessage < f.since {
+		return false
+	}
+	if f.until > 0 && lastMessage >= f.until {
+		return false
+	}
+	return f.allowsKeys(chatKeys(chat.WAChatID, chat.WAChatLID, chat.Phone))
+}
+
+// allowsChatID aplica apenas as listas de inclusão/exclusão; usado pelo webhook, cujos
+// eventos nem sempre trazem os dados do chat
+func (f *chatFilter) allowsChatID(chatID, phone string) bool {
+	return f.allowsKeys(chatKeys(chatID, "", phone))
+}
+
+func (f *chatFilter) allowsKeys(keys []string) bool {
+	for _, key := range keys {
+		if f.exclude[key] {
+			return false
+		}
+	}
+	if len(f.include) == 0 {
+		return true
+	}
+	for _, key := range keys {
+		if f.include[key] {
+			return true
+		}
+	}
+	return false
+}
+
+// messageCutoff retorna o timestamp (em segundos) a partir do qual as mensagens do chat são
+// buscadas, combinando o checkpoint incremental com SYNC_MESSAGES_SINCE
+func (f *chatFilter) messageCutoff(checkpoint int64) int64 {
+	since := models.UnixSeconds(checkpoint)
+	if f.messagesSince > since {
+		since = f.messagesSince
+	}
+	return since
+}
+
+// filterKeys normaliza as entradas das listas de inclusão/exclusão
+func filterKeys(entries []string) map[string]bool {
+	keys := make(map[string]bool, len(entries))
+	for _, entry := range entries {
+		if key := filterKey(entry); key != "" {
+			keys[key] = true
+		}
+	}
+	return keys
+}
+
+// filterKey reduz telefones e JIDs de contatos aos dígitos do número; os demais JIDs
+// (grupos, LIDs) são comparados por inteiro
+func filterKey(entry string) string {
+	entry = strings.ToLower(strings.TrimSpace(entry))
+	if at := strings.Index(entry, "@"); at >= 0 {
+		switch entry[at+1:] {
+		case "s.whatsapp.net", "c.us":
+			return digitsOnly(entry[:at])
+		}
+		return entry
+	}
+	return digitsOnly(entry)
+}
+
+// chatKeys retorna as chaves pelas quais um chat pode aparecer nas listas
+func chatKeys(chatID, lid, phone string) []string {
+	var keys []string
+	for _, value := range []string{chatID, lid, phone} {
+		if key := filterKey(value); key != "" {
+			keys = append(keys, key)
+		}
+	}
+	return keys
+}
+
+func digitsOnly(value string) string {
+	var b strings.Builder
+	for _, r := range value {
+		if r >= '0' && r <= '9' {
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,80 @@
+package sync
+
+import (
+	"chatwoot-sync-go/internal/config"
+	"chatwoot-sync-go/internal/models"
+	"testing"
+	"time"
+)
+
+func TestChatFilterAllowsChat(t *testing.T) {
+	since := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	until := time.Date(2024, 7, 1, 0, 0, 0, 0, time.UTC)
+	inRange := time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)
+
+	chat := func(chatID string, lastMessage time.Time) models.UAZAPIChat {
+		return models.UAZAPIChat{WAChatID: chatID, WALastMsgTimestamp: lastMessage.UnixMilli()}
+	}
+	archived := chat("5511987654321@s.whatsapp.net", inRange)
+	archived.WAArchived = true
+	lidChat := models.UAZAPIChat{WAChatLID: "123456789@lid", WALastMsgTimestamp: inRange.Unix()}
+	phoneChat := models.UAZAPIChat{WAChatID: "5511987654321@s.whatsapp.net", Phone: "+55 11 98765-4321", WALastMsgTimestamp: inRange.Unix()}
+	linkedChat := models.UAZAPIChat{WAChatID: "5511987654321@s.whatsapp.net", WAChatLID: "123456789@lid", WALastMsgTimestamp: inRange.Unix()}
+
+	tests := []struct {
+		name string
+		cfg  config.SyncConfig
+		chat models.UAZAPIChat
+		want bool
+	}{
+		{"arquivado excluído", config.SyncConfig{}, archived, false},
+		{"arquivado incluído", config.SyncConfig{IncludeArchived: true}, archived, true},
+		{"antes de since", config.SyncConfig{ChatsSince: since}, chat("5511987654321@s.whatsapp.net", since.Add(-time.Second)), false},
+		{"igual a since", config.SyncConfig{ChatsSince: since}, chat("5511987654321@s.whatsapp.net", since), true},
+		{"igual a until", config.SyncConfig{ChatsUntil: until}, chat("5511987654321@s.whatsapp.net", until), false},
+		{"dentro do intervalo", config.SyncConfig{ChatsSince: since, ChatsUntil: until}, chat("5511987654321@s.whatsapp.net", inRange), true},
+		{"incluído pelo telefone", config.SyncConfig{IncludeChats: []string{"+55 (11) 98765-4321"}}, chat("5511987654321@s.whatsapp.net", inRange), true},
+		{"incluído pelo JID c.us", config.SyncConfig{IncludeChats: []string{"5511987654321@c.us"}}, chat("5511987654321@s.whatsapp.net", inRange), true},
+		{"fora da inclusão", config.SyncConfig{IncludeChats: []string{"5511912345678"}}, chat("5511987654321@s.whatsapp.net", inRange), false},
+		{"excluído pelo telefone do chat", config.SyncConfig{ExcludeChats: []string{"11 98765-4321", "5511987654321"}}, phoneChat, false},
+		{"exclusão tem prioridade", config.SyncConfig{IncludeChats: []string{"5511987654321"}, ExcludeChats: []string{"5511987654321@s.whatsapp.net"}}, chat("5511987654321@s.whatsapp.net", inRange), false},
+		{"grupo incluído pelo JID", config.SyncConfig{IncludeChats: []string{"120363025246125486@G.US"}}, chat("120363025246125486@g.us", inRange), true},
+		{"grupo não confundido com telefone", config.SyncConfig{ExcludeChats: []string{"120363025246125486"}}, chat("120363025246125486@g.us", inRange), true},
+		{"LID incluído", config.SyncConfig{IncludeChats: []string{"123456789@lid"}}, lidChat, true},
+		{"excluído pelo LID mesmo com telefone", config.SyncConfig{ExcludeChats: []string{"123456789@LID"}}, linkedChat, false},
+		{"incluído pelo telefone mesmo com LID", config.SyncConfig{IncludeChats: []string{"+5511987654321"}}, linkedChat, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := newChatFilter(tt.cfg).allowsChat(tt.chat); got != tt.want {
+				t.Errorf("allowsChat() = %v, esperado %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestChatFilterMessageCutoff(t *testing.T) {
+	messagesSince := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name       string
+		cfg        config.SyncConfig
+		checkpoint int64
+		want       int64
+	}{
+		{"sem checkpoint nem limite", config.SyncConfig{}, 0, 0},
+		{"checkpoint em milissegundos", config.SyncConfig{}, 1717243200123, 1717243200},
+		{"checkpoint em segundos", config.SyncConfig{}, 1717243200, 1717243200},
+		{"limite mais recente que o checkpoint", config.SyncConfig{MessagesSince: messagesSince}, 1600000000, messagesSince.Unix()},
+		{"checkpoint mais recente que o limite", config.SyncConfig{MessagesSince: messagesSince}, 1717243200000, 1717243200},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := newChatFilter(tt.cfg).messageCutoff(tt.checkpoint); got != tt.want {
+				t.Errorf("messageCutoff(%d) = %d, esperado %d", tt.checkpoint, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,141 @@
+package sync
+
+import (
+	"chatwoot-sync-go/internal/chatwoot"
+	"chatwoot-sync-go/internal/models"
+	"context"
+	"fmt"
+	"log"
+	"strings"
+)
+
+// isGroupChatID indica se o JID pertence a um grupo do WhatsApp
+func isGroupChatID(chatID string) bool {
+	return strings.HasSuffix(chatID, "@g.us")
+}
+
+// processGroupChat sincroniza as mensagens do grupo, criando sua conversa se necessário
+func (s *Service) processGroupChat(
+	ctx context.Context,
+	chat models.UAZAPIChat,
+	inboxID int,
+	chatwootUser *models.ChatwootUser,
+) error {
+	chatID := chat.WAChatID
+	if chatID == "" {
+		s.addStatsChatsSkipped(1)
+		return nil
+	}
+
+	// Pular grupos sem mensagens novas desde o último checkpoint
+	if s.isChatUnchanged(chat, chatID) {
+		s.addStatsChatsUnchanged(1)
+		return nil
+	}
+
+	group := models.ChatwootContact{
+		Name:           s.getGroupName(chat),
+		Identifier:     chatID,
+		FirstTimestamp: chat.WALastMsgTimestamp,
+		LastTimestamp:  chat.WALastMsgTimestamp,
+	}
+	contactID := 0
+	lookup := func() (int, error) {
+		existing, err := s.chatwoot.FindGroupConversation(ctx, chatID, inboxID)
+		if err != nil || existing == nil {
+			return 0, err
+		}
+		return existing.ConversationID, nil
+	}
+	resolve := func(db *chatwoot.Database) (*models.ChatwootFKs, error) {
+		var fks *models.ChatwootFKs
+		var err error
+		if s.cfg.Sync.DryRun {
+			fks, err = s.planGroup(ctx, group, inboxID)
+		} else {
+			fks, err = db.CreateGroupConversation(ctx, group, inboxID)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to create group conversation: %w", err)
+		}
+		if !s.cfg.Sync.DryRun {
+			s.addStatsContactsCreatedUpdated(1)
+		}
+
+		log.Printf("Group %s mapped to contact_id=%d, conversation_id=%d", chatID, fks.ContactID, fks.ConversationID)
+		contactID = fks.ContactID
+		return fks, nil
+	}
+	if err := s.syncChatMessages(ctx, chatID, chat.WALastMsgTimestamp, lookup, resolve, inboxID, chatwootUser); err != nil {
+		return err
+	}
+
+	s.syncContactAvatar(ctx, contactID, chat)
+	return nil
+}
+
+// resolveGroupParticipants busca ou cria os contatos dos remetentes das mensagens recebidas no grupo.
+// Retorna um mapa identifier -> contact_id.
+func (s *Service) resolveGroupParticipants(ctx context.Context, db *chatwoot.Database, chatID string, messages []models.UAZAPIMessage) (map[string]int, error) {
+	contacts := make([]models.ChatwootContact, 0)
+	seen := make(map[string]bool)
+
+	for _, msg := range messages {
+		if msg.FromMe {
+			continue
+		}
+		participant, ok := s.participantFromMessage(msg)
+		if !ok || seen[participant.Identifier] {
+			continue
+		}
+		seen[participant.Identifier] = true
+		contacts = append(contacts, participant)
+	}
+
+	if len(contacts) == 0 {
+		return make(map[string]int), nil
+	}
+
+	log.Printf("Resolving %d group participants", len(contacts))
+	if s.cfg.Sync.DryRun {
+		return s.planParticipants(ctx, chatID, contacts)
+	}
+	return db.EnsureContacts(ctx, contacts)
+}
+
+// participantFromMessage monta o contato do remetente de uma mensagem de grupo,
+// preferindo o JID de telefone (sender_pn) ao LID, que também é guardado no contato
+func (s *Service) participantFromMessage(msg models.UAZAPIMessage) (models.ChatwootContact, bool) {
+	jid := msg.SenderPN
+	if jid == "" {
+		jid = msg.Sender
+	}
+	if jid == "" {
+		return models.ChatwootContact{}, false
+	}
+
+	contact := models.ChatwootContact{
+		Name:       msg.SenderName,
+		Identifier: jid,
+		LID:        lidJID(msg.SenderLID, msg.Sender),
+	}
+	if contact.LID != "" {
+		contact.Identifier = contact.LID
+	}
+	if phoneNumber := s.contactPhone(jid, ""); phoneNumber != "" {
+		contact.PhoneNumber = phoneNumber
+		contact.Identifier = s.buildIdentifier(phoneNumber)
+	}
+
+	return contact, true
+}
+
+func (s *Service) getGroupName(chat models.UAZAPIChat) string {
+	if chat.Name != "" {
+		return chat.Name
+	}
+	if chat.WAName != "" {
+		return chat.WAName
+	}
+	return chat.WAChatID
+}
@@ -0,0 +1,81 @@
+package sync
+
+import (
+	"chatwoot-sync-go/internal/chatwoot"
+	"chatwoot-sync-go/internal/models"
+	"fmt"
+	"os"
+	"text/tabwriter"
+	"time"
+)
+
+// ListChats imprime os chats da UAZAPI que passam pelos filtros configurados, sem acessar o Chatwoot
+func (s *Service) ListChats() error {
+	s.wg.Add(1)
+	defer s.wg.Done()
+
+	chats, err := s.fetchChats(s.ctx)
+	if err != nil {
+		return err
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "CHAT\tNOME\tTELEFONE\tÚLTIMA MENSAGEM\tARQUIVADO")
+	listed := 0
+	for _, chat := range chats {
+		if !s.filter.allowsChat(chat) {
+			continue
+		}
+
+		name := s.getContactName(chat)
+		if chat.WAIsGroup {
+			name = s.getGroupName(chat)
+		}
+		lastMessage := "-"
+		if chat.WALastMsgTimestamp > 0 {
+			lastMessage = time.Unix(models.UnixSeconds(chat.WALastMsgTimestamp), 0).Format("2006-01-02 15:04")
+		}
+		archived := "não"
+		if chat.WAArchived {
+			archived = "sim"
+		}
+
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n", resolveChatID(chat), name, chat.Phone, lastMessage, archived)
+		listed++
+	}
+	w.Flush()
+
+	fmt.Printf("\n%d chats\n", listed)
+	return nil
+}
+
+// ListInboxes imprime os inboxes da conta do Chatwoot, indicando o inbox configurado
+func (s *Service) ListInboxes() error {
+	s.wg.Add(1)
+	defer s.wg.Done()
+
+	db, err := chatwoot.NewDatabase(s.cfg)
+	if err != nil {
+		return fmt.Errorf("failed to connect to database: %w", err)
+	}
+	defer db.Close()
+
+	inboxes, err := db.ListInboxes(s.ctx)
+	if err != nil {
+		return err
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "ID\tNOME\tTIPO\tCONFIGURADO")
+	for _, inbox := range inboxes {
+		configured := ""
+		if inbox.ID == s.cfg.Chatwoot.InboxID {
+			configured = "sim"
+		}
+		fmt.Fprintf(w, "%d\t%s\t%s\t%s\n", inbox.ID, inbox.Name, inbox.InboxType, configured)
+	}
+	w.Flush()
+
+	fmt.Printf("\n%d inboxes in account %d\n", len(inboxes), s.cfg.Chatwoot.AccountID)
+	return nil
+}
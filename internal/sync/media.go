@@ -0,0 +1,235 @@
+package sync
+
+import (
+	"chatwoot-sync-go/internal/chatwoot"
+	"chatwoot-sync-go/internal/models"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"mime"
+	"net/http"
+	"path"
+	"strings"
+	"time"
+)
+
+// mediaHTTPClient é usado para baixar mídias quando a UAZAPI retorna apenas o link
+var mediaHTTPClient = &http.Client{Timeout: 120 * time.Second}
+
+// mediaKind descreve como um tipo de mensagem de mídia da UAZAPI é representado no Chatwoot
+type mediaKind struct {
+	FileType    int    // Valor de attachments.file_type (0 = image, 1 = audio, 2 = video, 3 = file)
+	Placeholder string // Conteúdo usado quando a mídia não pode ser anexada
+}
+
+// mediaKinds mapeia o messageType da UAZAPI (em minúsculas) para o tipo de anexo do Chatwoot
+var mediaKinds = map[string]mediaKind{
+	"imagemessage":               {FileType: 0, Placeholder: "[Imagem]"},
+	"videomessage":               {FileType: 2, Placeholder: "[Vídeo]"},
+	"ptvmessage":                 {FileType: 2, Placeholder: "[Vídeo]"},
+	"audiomessage":               {FileType: 1, Placeholder: "[Áudio]"},
+	"pttmessage":                 {FileType: 1, Placeholder: "[Áudio]"},
+	"documentmessage":            {FileType: 3, Placeholder: "[Documento]"},
+	"documentwithcaptionmessage": {FileType: 3, Placeholder: "[Documento]"},
+	"stickermessage":             {FileType: 0, Placeholder: "[Figurinha]"},
+}
+
+// apiMedia agrupa as mídias de um chat enviadas pela API do Chatwoot. Como a API grava
+// fora da transação do chat, o envio acontece somente após o commit.
+type apiMedia struct {
+	chatID       string
+	messages     []models.UAZAPIMessage
+	fks          *models.ChatwootFKs
+	participants map[string]int
+}
+
+// getMediaKind retorna o tipo de mídia da mensagem, se ela for uma mensagem de mídia
+func getMediaKind(msg models.UAZAPIMessage) (mediaKind, bool) {
+	kind, ok := mediaKinds[strings.ToLower(msg.MessageType)]
+	return kind, ok
+}
+
+// syncMediaMessage baixa a mídia da UAZAPI e cria a mensagem com anexo via API do Chatwoot
+func (s *Service) syncMediaMessage(
+	ctx context.Context,
+	msg models.UAZAPIMessage,
+	kind mediaKind,
+	fks *models.ChatwootFKs,
+) error {
+	attachment, err := s.downloadAttachment(ctx, msg, kind)
+	if err != nil {
+		return err
+	}
+
+	messageType := "incoming"
+	if msg.FromMe {
+		messageType = "outgoing"
+	}
+
+	sourceID := fmt.Sprintf("WAID:%s", msg.MessageID)
+	created, err := s.api.CreateMessageWithAttachment(
+		ctx,
+		fks.ConversationID,
+		msg.Text,
+		messageType,
+		attachment,
+		sourceID,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to create message with attachment: %w", err)
+	}
+
+	// A mensagem foi criada fora do banco; registrá-la no diário para que o rollback a alcance
+	if id, ok := created["id"].(float64); ok {
+		if err := s.chatwoot.RecordMessage(ctx, int64(id)); err != nil {
+			log.Printf("Warning: failed to record media message %s in journal: %v", sourceID, err)
+		}
+	}
+
+	// A citação é resolvida ao fim do chat, junto com as das demais mensagens
+	if quoted := quotedSourceID(msg); quoted != "" {
+		if err := s.chatwoot.SetMessageReplyTo(ctx, sourceID, fks.ConversationID, quoted); err != nil {
+			log.Printf("Warning: failed to set reply for media message %s: %v", sourceID, err)
+		}
+	}
+
+	// A API cria a mensagem como enviada; aplicar o status de entrega do WhatsApp
+	if status := messageStatus(msg); status != chatwoot.MessageStatusSent {
+		if _, err := s.chatwoot.UpdateMessageStatuses(ctx, s.inboxID, fks.ConversationID, map[string]int{sourceID: status}); err != nil {
+			log.Printf("Warning: failed to update status for media message %s: %v", sourceID, err)
+		}
+	}
+
+	// A API grava created_at com o horário atual; restaurar o horário original do WhatsApp
+	if err := s.chatwoot.UpdateMessageTimestamp(ctx, sourceID, fks.ConversationID, msg.MessageTimestamp); err != nil {
+		log.Printf("Warning: failed to update timestamp for media message %s: %v", sourceID, err)
+	}
+
+	return nil
+}
+
+// attachMedia baixa as mídias pendentes do lote e as anexa às mensagens para inserção via ActiveStorage.
+// Mensagens cuja mídia falhar continuam no lote com o conteúdo de texto (legenda ou placeholder).
+func (s *Service) attachMedia(ctx context.Context, batch []models.ChatwootMessage, pending map[string]models.UAZAPIMessage) (int, int) {
+	attached := 0
+	failed := 0
+	for i := range batch {
+		msg, isPending := pending[batch[i].SourceID]
+		if !isPending {
+			continue
+		}
+
+		kind, _ := getMediaKind(msg)
+		attachment, err := s.downloadAttachment(ctx, msg, kind)
+		if err != nil {
+			log.Printf("Warning: failed to download media message %s: %v", msg.MessageID, err)
+			failed++
+			continue
+		}
+
+		batch[i].Content = msg.Text
+		batch[i].Attachment = attachment
+		attached++
+	}
+	return attached, failed
+}
+
+// downloadAttachment baixa a mídia da UAZAPI e monta o anexo para gravação no storage
+func (s *Service) downloadAttachment(ctx context.Context, msg models.UAZAPIMessage, kind mediaKind) (*models.ChatwootAttachment, error) {
+	media, err := s.uazapi.DownloadMedia(ctx, msg.MessageID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to download media: %w", err)
+	}
+
+	data, contentType, err := fetchMediaData(ctx, media, msg.FileURL)
+	if err != nil {
+		return nil, err
+	}
+	if len(data) == 0 {
+		return nil, fmt.Errorf("empty media for message %s", msg.MessageID)
+	}
+
+	return &models.ChatwootAttachment{
+		FileType:    kind.FileType,
+		FileName:    mediaFileName(msg, contentType),
+		ContentType: contentType,
+		Data:        data,
+	}, nil
+}
+
+// mediaFileName retorna o nome original do documento, quando a UAZAPI o informa, ou um nome
+// derivado do ID da mensagem, único por mensagem
+func mediaFileName(msg models.UAZAPIMessage, contentType string) string {
+	var content struct {
+		FileName string `json:"fileName"`
+	}
+	if json.Unmarshal(msg.Content, &content) == nil {
+		if name := path.Base(strings.ReplaceAll(strings.TrimSpace(content.FileName), "\\", "/")); name != "." && name != "/" {
+			return name
+		}
+	}
+
+	mediaType, _, _ := mime.ParseMediaType(contentType)
+	return msg.MessageID + chatwoot.ExtensionFromMimeType(mediaType)
+}
+
+// fetchMediaData retorna o conteúdo da mídia, decodificando o base64 ou baixando pelo link
+func fetchMediaData(ctx context.Context, media *models.UAZAPIMediaResponse, fallbackURL string) ([]byte, string, error) {
+	contentType := "application/octet-stream"
+	if parsed, _, err := mime.ParseMediaType(media.MimeType); err == nil {
+		contentType = parsed
+	}
+
+	if media.Base64Data != "" {
+		encoded := media.Base64Data
+		if strings.HasPrefix(encoded, "data:") {
+			parts := strings.SplitN(encoded, ",", 2)
+			if len(parts) != 2 {
+				return nil, "", fmt.Errorf("invalid data URL format")
+			}
+			encoded = parts[1]
+		}
+		data, err := base64.StdEncoding.DecodeString(encoded)
+		if err != nil {
+			return nil, "", fmt.Errorf("failed to decode base64: %w", err)
+		}
+		return data, contentType, nil
+	}
+
+	fileURL := media.FileURL
+	if fileURL == "" {
+		fileURL = fallbackURL
+	}
+	if fileURL == "" {
+		return nil, "", fmt.Errorf("no media data returned")
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "GET", fileURL, nil)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to create download request: %w", err)
+	}
+	resp, err := mediaHTTPClient.Do(req)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to download file: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, "", fmt.Errorf("failed to download file: status %d", resp.StatusCode)
+	}
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to read file: %w", err)
+	}
+	if media.MimeType == "" {
+		if parsed, _, err := mime.ParseMediaType(resp.Header.Get("Content-Type")); err == nil {
+			contentType = parsed
+		}
+	}
+
+	return data, contentType, nil
+}
@@ -0,0 +1,36 @@
+package sync
+
+import (
+	"chatwoot-sync-go/internal/models"
+	"encoding/json"
+	"testing"
+)
+
+func TestMediaFileName(t *testing.T) {
+	tests := []struct {
+		name        string
+		content     string
+		contentType string
+		want        string
+	}{
+		{"documento com nome", `{"fileName":"contrato.pdf","mimetype":"application/pdf"}`, "application/pdf", "contrato.pdf"},
+		{"nome com caminho", `{"fileName":"../../etc/passwd"}`, "text/plain", "passwd"},
+		{"nome com barra invertida", `{"fileName":"C:\\docs\\nota.txt"}`, "text/plain", "nota.txt"},
+		{"sem nome", `{"mimetype":"image/png"}`, "image/png", "3EB0ABC.png"},
+		{"conteúdo em texto", `"legenda"`, "application/pdf", "3EB0ABC.pdf"},
+		{"tipo com parâmetros", ``, "image/png; charset=binary", "3EB0ABC.png"},
+		{"jpeg não vira jfif", ``, "image/jpeg", "3EB0ABC.jpg"},
+		{"áudio de voz com codecs", ``, "audio/ogg; codecs=opus", "3EB0ABC.ogg"},
+		{"imagem fora da tabela", ``, "image/x-unknown", "3EB0ABC.jpg"},
+		{"tipo desconhecido", ``, "", "3EB0ABC.bin"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			msg := models.UAZAPIMessage{MessageID: "3EB0ABC", Content: json.RawMessage(tt.content)}
+			if got := mediaFileName(msg, tt.contentType); got != tt.want {
+				t.Errorf("mediaFileName() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
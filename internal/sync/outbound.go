@@ -0,0 +1,157 @@
+package sync
+
+import (
+	"chatwoot-sync-go/internal/models"
+	"context"
+	"fmt"
+	"log"
+	"net/url"
+	"path"
+	"strings"
+)
+
+// uazapiMediaTypes mapeia o file_type dos anexos do Chatwoot para o type do /send/media da UAZAPI
+var uazapiMediaTypes = map[string]string{
+	"image": "image",
+	"video": "video",
+	"audio": "audio",
+	"file":  "document",
+}
+
+// HandleChatwootEvent envia ao WhatsApp as respostas públicas dos agentes criadas no Chatwoot
+func (s *Service) HandleChatwootEvent(ctx context.Context, event *models.ChatwootWebhookEvent) error {
+	if ctx.Err() != nil {
+		return fmt.Errorf("service is stopping")
+	}
+
+	if event.Event != "message_created" || event.MessageType != "outgoing" || event.Private {
+		return nil
+	}
+
+	// Mensagens com source_id já vieram do WhatsApp (importadas ou enviadas por esta ponte)
+	if event.SourceID != "" {
+		return nil
+	}
+
+	inboxID := event.Inbox.ID
+	if inboxID == 0 {
+		inboxID = event.Conversation.InboxID
+	}
+	if inboxID != s.inboxID {
+		return nil
+	}
+
+	// O destinatário vem do banco do Chatwoot, não do payload: a mensagem precisa existir na
+	// conversa do inbox e o número é o do contato dessa conversa
+	recipient, err := s.chatwoot.FindMessageRecipient(ctx, event.ID, event.Conversation.ID, s.inboxID)
+	if err != nil {
+		return err
+	}
+	if recipient == nil {
+		log.Printf("Outbound: message %d not found as a pending outgoing message of conversation %d, skipping",
+			event.ID, event.Conversation.ID)
+		return nil
+	}
+
+	chatID, number := s.recipientFromContact(*recipient)
+	if number == "" {
+		log.Printf("Outbound: no WhatsApp recipient for conversation %d, skipping message %d",
+			event.Conversation.ID, event.ID)
+		return nil
+	}
+
+	// Manter o chat bloqueado até gravar o source_id, para que o eco do webhook da UAZAPI
+	// encontre a mensagem já marcada e não a importe de novo
+	unlock := s.lockChat(chatID)
+	defer unlock()
+
+	sourceIDs, err := s.sendToWhatsApp(ctx, number, event)
+	if len(sourceIDs) > 0 {
+		if markErr := s.chatwoot.MarkMessageBridged(ctx, event.ID, sourceIDs); markErr != nil {
+			log.Printf("Outbound: failed to stamp source_id on message %d: %v", event.ID, markErr)
+		}
+	}
+	if err != nil {
+		return fmt.Errorf("failed to send message %d to %s: %w", event.ID, chatID, err)
+	}
+
+	log.Printf("Outbound: delivered Chatwoot message %d to %s (%s)", event.ID, chatID, strings.Join(sourceIDs, ", "))
+	return nil
+}
+
+// sendToWhatsApp envia o texto ou os anexos da mensagem e retorna os source_ids (WAID:) das mensagens enviadas
+func (s *Service) sendToWhatsApp(ctx context.Context, number string, event *models.ChatwootWebhookEvent) ([]string, error) {
+	sourceIDs := make([]string, 0, 1)
+
+	if len(event.Attachments) == 0 {
+		if strings.TrimSpace(event.Content) == "" {
+			return sourceIDs, nil
+		}
+		resp, err := s.uazapi.SendText(ctx, number, event.Content)
+		if err != nil {
+			return sourceIDs, err
+		}
+		return append(sourceIDs, fmt.Sprintf("WAID:%s", resp.MessageID)), nil
+	}
+
+	// A UAZAPI envia um arquivo por mensagem; o texto vai como legenda do primeiro anexo
+	caption := event.Content
+	for _, attachment := range event.Attachments {
+		mediaType, ok := uazapiMediaTypes[attachment.FileType]
+		if !ok || attachment.DataURL == "" {
+			log.Printf("Outbound: skipping unsupported attachment %d (%s)", attachment.ID, attachment.FileType)
+			continue
+		}
+
+		fileName := ""
+		if mediaType == "document" {
+			fileName = attachmentFileName(attachment.DataURL)
+		}
+
+		resp, err := s.uazapi.SendMedia(ctx, number, mediaType, attachment.DataURL, caption, fileName)
+		if err != nil {
+			return sourceIDs, err
+		}
+		sourceIDs = append(sourceIDs, fmt.Sprintf("WAID:%s", resp.MessageID))
+		caption = ""
+	}
+
+	return sourceIDs, nil
+}
+
+// recipientFromContact retorna o JID do chat e o número (ou JID de grupo) usado no envio
+func (s *Service) recipientFromContact(contact models.ChatwootContact) (string, string) {
+	identifier := contact.Identifier
+	switch {
+	case isGroupChatID(identifier):
+		return identifier, identifier
+	case strings.HasSuffix(identifier, "@s.whatsapp.net"):
+		return identifier, strings.TrimSuffix(identifier, "@s.whatsapp.net")
+	}
+
+	phoneNumber := s.phones.Normalize(contact.PhoneNumber)
+	if phoneNumber == "" {
+		// Contato conhecido apenas pelo LID: a UAZAPI aceita o JID como destino
+		if strings.HasSuffix(identifier, "@lid") {
+			return identifier, identifier
+		}
+		if strings.HasSuffix(contact.LID, "@lid") {
+			return contact.LID, contact.LID
+		}
+		return "", ""
+	}
+	return s.buildIdentifier(phoneNumber), strings.TrimPrefix(phoneNumber, "+")
+}
+
+// attachmentFileName extrai o nome do arquivo da URL do anexo
+func attachmentFileName(dataURL string) string {
+	parsed, err := url.Parse(dataURL)
+	if err != nil {
+		return ""
+	}
+	name := path.Base(parsed.Path)
+	if name == "." || name == "/" {
+		return ""
+	}
+	return name
+}
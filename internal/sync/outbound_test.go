@@ -0,0 +1,62 @@
+package sync
+
+import (
+	"chatwoot-sync-go/internal/models"
+	"chatwoot-sync-go/internal/phone"
+	"testing"
+)
+
+func TestRecipientFromContact(t *testing.T) {
+	s := &Service{phones: phone.NewNormalizer("BR")}
+
+	tests := []struct {
+		name       string
+		contact    models.ChatwootContact
+		wantChatID string
+		wantNumber string
+	}{
+		{
+			name:       "JID de telefone",
+			contact:    models.ChatwootContact{Identifier: "5511987654321@s.whatsapp.net", PhoneNumber: "+5511987654321"},
+			wantChatID: "5511987654321@s.whatsapp.net",
+			wantNumber: "5511987654321",
+		},
+		{
+			name:       "grupo",
+			contact:    models.ChatwootContact{Identifier: "120363025246125486@g.us"},
+			wantChatID: "120363025246125486@g.us",
+			wantNumber: "120363025246125486@g.us",
+		},
+		{
+			name:       "apenas telefone",
+			contact:    models.ChatwootContact{PhoneNumber: "+55 (11) 98765-4321"},
+			wantChatID: "5511987654321@s.whatsapp.net",
+			wantNumber: "5511987654321",
+		},
+		{
+			name:       "apenas LID no identifier",
+			contact:    models.ChatwootContact{Identifier: "123456789@lid"},
+			wantChatID: "123456789@lid",
+			wantNumber: "123456789@lid",
+		},
+		{
+			name:       "LID nos atributos",
+			contact:    models.ChatwootContact{Identifier: "cliente-42", LID: "123456789@lid"},
+			wantChatID: "123456789@lid",
+			wantNumber: "123456789@lid",
+		},
+		{
+			name:    "sem destinatário",
+			contact: models.ChatwootContact{Identifier: "cliente-42"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			chatID, number := s.recipientFromContact(tt.contact)
+			if chatID != tt.wantChatID || number != tt.wantNumber {
+				t.Errorf("recipientFromContact() = (%q, %q), want (%q, %q)", chatID, number, tt.wantChatID, tt.wantNumber)
+			}
+		})
+	}
+}
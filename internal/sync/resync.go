@@ -0,0 +1,148 @@
+package sync
+
+import (
+	"chatwoot-sync-go/internal/models"
+	"context"
+	"fmt"
+	"log"
+	"strings"
+)
+
+// ResyncChat sincroniza novamente um único chat, informado pelo telefone ou pelo wa_chatid, sem
+// listar os chats da instância. O checkpoint do chat é ignorado: todas as mensagens são relidas
+// e apenas as que faltam no Chatwoot são inseridas.
+func (s *Service) ResyncChat(target string) error {
+	s.wg.Add(1)
+	defer s.wg.Done()
+
+	chatID := s.resolveTargetChatID(target)
+	if chatID == "" {
+		return fmt.Errorf("invalid chat %q: expected a phone number or WhatsApp JID", target)
+	}
+
+	ctx := s.ctx
+	if err := s.connect(ctx); err != nil {
+		if ctx.Err() != nil {
+			return nil
+		}
+		return err
+	}
+	defer s.chatwoot.Close()
+
+	s.resetStats()
+	s.setCheckpoints(nil)
+	if err := s.startRun(ctx); err != nil {
+		return err
+	}
+
+	chat, err := s.uazapi.FindChat(ctx, chatID)
+	if err != nil {
+		return fmt.Errorf("failed to find chat %s: %w", chatID, err)
+	}
+	if chat == nil {
+		return fmt.Errorf("chat %s not found in UAZAPI", chatID)
+	}
+
+	job, ok := s.newChatJob(*chat)
+	if !ok {
+		return fmt.Errorf("chat %s has no phone number to create a contact", chatID)
+	}
+
+	log.Printf("Resyncing chat %s...", job.chatID)
+	s.addStatsChatsProcessed(1)
+	syncErr := s.syncChat(ctx, job, s.inboxID, s.chatwootUser)
+	if ctx.Err() != nil {
+		log.Println("Resync stopped by user")
+		return nil
+	}
+
+	s.printChatReport(ctx, job, syncErr)
+	if s.cfg.Sync.DryRun {
+		s.printPlan()
+	}
+	if syncErr != nil {
+		return fmt.Errorf("failed to resync chat %s: %w", job.chatID, syncErr)
+	}
+	return nil
+}
+
+// resolveTargetChatID converte o telefone informado no JID do chat; JIDs são usados como estão
+func (s *Service) resolveTargetChatID(target string) string {
+	target = strings.TrimSpace(target)
+	if strings.Contains(target, "@") {
+		return strings.ToLower(target)
+	}
+	phoneNumber := s.phones.Normalize(target)
+	if phoneNumber == "" {
+		return ""
+	}
+	return s.buildIdentifier(phoneNumber)
+}
+
+// printChatReport imprime o resultado da ressincronização de um chat
+func (s *Service) printChatReport(ctx context.Context, job chatJob, syncErr error) {
+	conversation := "não encontrada"
+	if conversationID, err := s.findJobConversation(ctx, job, s.inboxID); err != nil {
+		log.Printf("Warning: failed to look up conversation for chat %s: %v", job.chatID, err)
+	} else if conversationID > 0 {
+		conversation = fmt.Sprintf("%d", conversationID)
+	}
+
+	name := job.contact.Name
+	if job.isGroup {
+		name = s.getGroupName(job.chat)
+	}
+
+	result := "OK"
+	if syncErr != nil {
+		result = fmt.Sprintf("falha (%v)", syncErr)
+	}
+
+	s.statsMutex.Lock()
+	defer s.statsMutex.Unlock()
+
+	log.Println("")
+	log.Println("========================================")
+	log.Println("         RELATÓRIO DO CHAT")
+	log.Println("========================================")
+	log.Printf("Chat:                              %s", job.chatID)
+	log.Printf("Nome:                              %s", name)
+	log.Printf("Conversa no Chatwoot:              %s", conversation)
+	log.Printf("Mensagens verificadas:             %d", s.stats.TotalMessagesChecked)
+	log.Printf("Mensagens já existentes:           %d", s.stats.MessagesAlreadyExist)
+	log.Printf("Mensagens novas inseridas:         %d", s.stats.MessagesInserted)
+	log.Printf("Mídias inseridas como anexo:       %d", s.stats.MediaMessagesInserted)
+	log.Printf("Mídias com falha (texto):          %d", s.stats.MediaMessagesFailed)
+	log.Printf("Resultado:                         %s", result)
+	if runID := s.chatwoot.RunID(); runID != "" {
+		log.Printf("Execução (para rollback):          %s", runID)
+	}
+	log.Println("========================================")
+	log.Println("")
+}
+
+// findJobConversation retorna o ID da conversa mais recente do chat no Chatwoot (0 se não houver)
+func (s *Service) findJobConversation(ctx context.Context, job chatJob, inboxID int) (int, error) {
+	conversationIDs, err := s.findJobConversations(ctx, job, inboxID)
+	if err != nil || len(conversationIDs) == 0 {
+		return 0, err
+	}
+	return conversationIDs[0], nil
+}
+
+// findJobConversations retorna as conversas do chat no Chatwoot, da mais recente para a mais
+// antiga, com a mesma identidade que a sincronização usa: o telefone (inclusive a grafia com ou
+// sem o nono dígito já gravada), o JID do grupo ou o LID, cujos contatos são mesclados no do telefone
+func (s *Service) findJobConversations(ctx context.Context, job chatJob, inboxID int) ([]int, error) {
+	contact := job.contact
+	switch {
+	case job.isGroup:
+		contact = models.ChatwootContact{Identifier: job.chatID}
+	case contact.PhoneNumber != "":
+		var err error
+		if contact, err = s.matchContactPhone(ctx, s.chatwoot, contact); err != nil {
+			return nil, err
+		}
+	}
+	return s.chatwoot.FindChatConversations(ctx, contact, inboxID)
+}
@@ -0,0 +1,132 @@
+package sync
+
+import (
+	"chatwoot-sync-go/internal/chatwoot"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"log"
+	"os"
+	"text/tabwriter"
+	"time"
+)
+
+// rollbackListLimit limita as execuções listadas quando rollback é chamado sem --run
+const rollbackListLimit = 20
+
+// newRunID gera o ID de uma execução: data e hora UTC seguidos de um sufixo aleatório
+func newRunID() (string, error) {
+	suffix := make([]byte, 3)
+	if _, err := rand.Read(suffix); err != nil {
+		return "", fmt.Errorf("failed to generate run ID: %w", err)
+	}
+	return time.Now().UTC().Format("20060102-150405") + "-" + hex.EncodeToString(suffix), nil
+}
+
+// startRun inicia uma nova execução no diário; as linhas criadas a partir daí ficam associadas
+// a ela e podem ser removidas com rollback. Em dry-run nada é registrado.
+func (s *Service) startRun(ctx context.Context) error {
+	if s.cfg.Sync.DryRun {
+		return nil
+	}
+
+	runID, err := newRunID()
+	if err != nil {
+		return err
+	}
+	if err := s.chatwoot.StartRun(ctx, runID, s.inboxID); err != nil {
+		return err
+	}
+	log.Printf("Sync run %s started (undo with: chatwoot-sync rollback --run %s)", runID, runID)
+	return nil
+}
+
+// Rollback desfaz a execução runID: apaga as linhas criadas e desfaz as mesclas de contatos e as
+// trocas de foto. Sem runID, lista as execuções mais recentes da conta. Em dry-run apenas informa
+// o que seria desfeito.
+func (s *Service) Rollback(runID string) error {
+	s.wg.Add(1)
+	defer s.wg.Done()
+
+	ctx := s.ctx
+	db, err := chatwoot.NewDatabase(s.cfg)
+	if err != nil {
+		return fmt.Errorf("failed to connect to database: %w", err)
+	}
+	defer db.Close()
+
+	if runID == "" {
+		return s.listRuns(ctx, db)
+	}
+
+	run, err := db.GetRun(ctx, runID)
+	if err != nil {
+		return err
+	}
+	if run == nil {
+		return fmt.Errorf("run %s not found in account %d", runID, s.cfg.Chatwoot.AccountID)
+	}
+	if run.RolledBackAt != nil {
+		log.Printf("Run %s was already rolled back at %s; removing what is left", runID, run.RolledBackAt.Format(time.RFC3339))
+	}
+
+	result, err := db.RollbackRun(ctx, runID, !s.cfg.Sync.DryRun)
+	if err != nil {
+		return fmt.Errorf("failed to roll back run %s: %w", runID, err)
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "TABELA\tREGISTRADAS\tDESFEITAS\tMANTIDAS")
+	tables := append(append([]string{}, chatwoot.JournalTables...), chatwoot.JournalChanges...)
+	for _, table := range tables {
+		recorded, deleted := result.Recorded[table], result.Deleted[table]
+		fmt.Fprintf(w, "%s\t%d\t%d\t%d\n", table, recorded, deleted, recorded-deleted)
+	}
+	w.Flush()
+
+	log.Println("")
+	log.Println("========================================")
+	log.Println("        ROLLBACK DA EXECUÇÃO")
+	log.Println("========================================")
+	log.Printf("Execução:                          %s", run.RunID)
+	log.Printf("Inbox:                             %d", run.InboxID)
+	log.Printf("Iniciada em:                       %s", run.StartedAt.Format(time.RFC3339))
+	if s.cfg.Sync.DryRun {
+		log.Println("Dry-run: nada foi desfeito; a tabela mostra o que seria revertido.")
+	}
+	if result.Partial {
+		log.Println("Rollback parcial: linhas mantidas ainda são usadas por dados de fora da execução,")
+		log.Println("e alterações mantidas foram sobrepostas depois dela. Elas continuam no diário.")
+	} else {
+		log.Println("Rollback completo: nada da execução ficou no diário.")
+	}
+	log.Println("========================================")
+	log.Println("")
+	return nil
+}
+
+// listRuns imprime as execuções mais recentes da conta
+func (s *Service) listRuns(ctx context.Context, db *chatwoot.Database) error {
+	runs, err := db.ListRuns(ctx, rollbackListLimit)
+	if err != nil {
+		return err
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "EXECUÇÃO\tINBOX\tINICIADA EM\tLINHAS\tDESFEITA EM")
+	for _, run := range runs {
+		rolledBack := ""
+		if run.RolledBackAt != nil {
+			rolledBack = run.RolledBackAt.Format(time.RFC3339)
+		}
+		if run.Partial {
+			rolledBack += " (parcial)"
+		}
+		fmt.Fprintf(w, "%s\t%d\t%s\t%d\t%s\n", run.RunID, run.InboxID, run.StartedAt.Format(time.RFC3339), run.Rows, rolledBack)
+	}
+	w.Flush()
+
+	fmt.Printf("\n%d runs in account %d (use --run <id> to roll one back)\n", len(runs), s.cfg.Chatwoot.AccountID)
+	return nil
+}
@@ -4,7 +4,10 @@ import (
 	"chatwoot-sync-go/internal/chatwoot"
 	"chatwoot-sync-go/internal/config"
 	"chatwoot-sync-go/internal/models"
+	"chatwoot-sync-go/internal/phone"
 	"chatwoot-sync-go/internal/uazapi"
+	"context"
+	"errors"
 	"fmt"
 	"log"
 	"sort"
@@ -16,74 +19,167 @@ type Stats struct {
 	TotalChatsProcessed    int
 	ChatsWithMessages      int
 	ChatsSkipped           int
+	ChatsUnchanged         int
+	ChatsFiltered          int
+	ChatsFailed            int
 	TotalMessagesChecked   int
 	MessagesAlreadyExist   int
 	MessagesInserted       int
+	MediaMessagesInserted  int
+	MediaMessagesFailed    int
 	ContactsCreatedUpdated int
+	AvatarsUpdated         int
+	MessageStatusesUpdated int
+}
+
+// chatLock serializa o processamento de um mesmo chat entre a sincronização em lote e o webhook
+type chatLock struct {
+	mutex sync.Mutex
+	refs  int
 }
 
 type Service struct {
 	cfg         *config.Config
 	uazapi      *uazapi.Client
 	chatwoot    *chatwoot.Database
-	stopChan    chan struct{}
+	api         *chatwoot.APIClient
+	ctx         context.Context // Cancelado por Stop; interrompe requisições e consultas em andamento
+	cancel      context.CancelFunc
+	stopOnce    sync.Once
 	wg          sync.WaitGroup
 	stats       Stats
 	statsMutex  sync.Mutex
+	cycleMutex  sync.Mutex
+	checkpoints map[string]models.SyncCheckpoint
+	checkpointsMutex sync.RWMutex
+
+	chatLocks      map[string]*chatLock
+	chatLocksMutex sync.Mutex
+
+	filter   *chatFilter
+	phones   *phone.Normalizer
+	plans    map[string]*chatPlan // Plano por chat no modo dry-run
+	cycleErr error                // Erro permanente que interrompeu o ciclo atual
+
+	inboxID      int
+	chatwootUser *models.ChatwootUser
 }
 
 func NewService(cfg *config.Config) *Service {
+	ctx, cancel := context.WithCancel(context.Background())
 	return &Service{
 		cfg:      cfg,
 		uazapi:   uazapi.NewClient(cfg),
-		stopChan: make(chan struct{}),
+		api:      chatwoot.NewAPIClient(cfg),
+		ctx:      ctx,
+		cancel:   cancel,
+		chatLocks: make(map[string]*chatLock),
+		filter:    newChatFilter(cfg.Sync),
+		phones:    phone.NewNormalizer(cfg.Sync.DefaultRegion),
 	}
 }
 
 func (s *Service) Start() error {
-	// Inicializar estatísticas
-	s.stats = Stats{}
+	s.wg.Add(1)
+	defer s.wg.Done()
+
+	ctx := s.ctx
+	if err := s.connect(ctx); err != nil {
+		if ctx.Err() != nil {
+			return nil
+		}
+		return err
+	}
+	defer s.chatwoot.Close()
+
+	if err := s.startRun(ctx); err != nil {
+		return err
+	}
+
+	// Uma interrupção via Stop não é tratada como falha da sincronização
+	if err := s.runCycle(ctx); err != nil && ctx.Err() == nil {
+		return err
+	}
+	return nil
+}
 
+// connect abre a conexão com o banco do Chatwoot e resolve o inbox e o usuário usados na sincronização
+func (s *Service) connect(ctx context.Context) error {
 	// Conectar ao banco do Chatwoot
 	db, err := chatwoot.NewDatabase(s.cfg)
 	if err != nil {
 		return fmt.Errorf("failed to connect to database: %w", err)
 	}
 	s.chatwoot = db
-	defer s.chatwoot.Close()
 
-	// Criar cliente da API do Chatwoot
+	if s.chatwoot.HasStorage() {
+		log.Println("ActiveStorage configured, media messages will be inserted as attachments directly in the database")
+	} else if s.api.IsConfigured() {
+		log.Println("Chatwoot API configured, media messages will be synced as attachments")
+	} else {
+		log.Println("Chatwoot API not configured, media messages will be synced as text placeholders")
+	}
+
 	// Obter inbox
-	inboxID, err := s.chatwoot.GetInbox()
+	inboxID, err := s.chatwoot.GetInbox(ctx)
 	if err != nil {
+		s.chatwoot.Close()
 		return fmt.Errorf("failed to get inbox: %w", err)
 	}
 	log.Printf("Using inbox ID: %d", inboxID)
 
 	// Obter usuário do Chatwoot
-	chatwootUser, err := s.chatwoot.GetChatwootUser(s.cfg.Chatwoot.API.Token)
+	chatwootUser, err := s.chatwoot.GetChatwootUser(ctx, s.cfg.Chatwoot.API.Token)
 	if err != nil {
+		s.chatwoot.Close()
 		return fmt.Errorf("failed to get chatwoot user: %w", err)
 	}
 	log.Printf("Chatwoot User: %s (ID: %d)", chatwootUser.UserType, chatwootUser.UserID)
 
-	// Buscar todos os chats (apenas não-grupos)
-	log.Println("Fetching chats from UAZAPI...")
-	chats, err := s.uazapi.GetAllChats(s.cfg.Sync.LimitChats, false)
+	s.inboxID = inboxID
+	s.chatwootUser = chatwootUser
+	return nil
+}
+
+// runCycle executa uma sincronização completa (ou incremental) de todos os chats.
+// Apenas um ciclo roda por vez; chamadas concorrentes são ignoradas.
+func (s *Service) runCycle(ctx context.Context) error {
+	if !s.cycleMutex.TryLock() {
+		log.Println("Previous sync cycle still running, skipping")
+		return nil
+	}
+	defer s.cycleMutex.Unlock()
+
+	// Inicializar estatísticas
+	s.resetStats()
+	if s.cfg.Sync.DryRun {
+		log.Println("Dry-run mode: no changes will be written to Chatwoot")
+	}
+
+	inboxID := s.inboxID
+	chatwootUser := s.chatwootUser
+
+	// Carregar checkpoints da sincronização incremental
+	if err := s.loadCheckpoints(ctx, inboxID); err != nil {
+		return fmt.Errorf("failed to load checkpoints: %w", err)
+	}
+
+	chats, err := s.fetchChats(ctx)
 	if err != nil {
-		return fmt.Errorf("failed to fetch chats: %w", err)
+		return err
 	}
 	log.Printf("Found %d chats to sync", len(chats))
 
 	// Processar chats em lotes
 	batchSize := s.cfg.Sync.BatchSize
 	for i := 0; i < len(chats); i += batchSize {
-		select {
-		case <-s.stopChan:
+		if ctx.Err() != nil {
 			log.Println("Sync stopped by user")
 			s.printReport()
+			if s.cfg.Sync.DryRun {
+				s.printPlan()
+			}
 			return nil
-		default:
 		}
 
 		end := i + batchSize
@@ -94,191 +190,528 @@ func (s *Service) Start() error {
 		batch := chats[i:end]
 		log.Printf("Processing batch %d-%d of %d chats", i+1, end, len(chats))
 
-		if err := s.processChatsBatch(batch, inboxID, chatwootUser); err != nil {
+		if err := s.processChatsBatch(ctx, batch, inboxID, chatwootUser); err != nil {
 			log.Printf("Error processing batch: %v", err)
 			// Continue com próximo batch mesmo se houver erro
 		}
+
+		if err := s.cycleError(); err != nil {
+			s.printReport()
+			return fmt.Errorf("sync cycle aborted: %w", err)
+		}
 	}
 
 	s.printReport()
+	if s.cfg.Sync.DryRun {
+		s.printPlan()
+	}
 	log.Println("Sync completed successfully")
 	return nil
 }
 
+// fetchChats busca todos os chats da UAZAPI (grupos apenas se habilitados)
+func (s *Service) fetchChats(ctx context.Context) ([]models.UAZAPIChat, error) {
+	log.Println("Fetching chats from UAZAPI...")
+	chats, err := s.uazapi.GetAllChats(ctx, s.cfg.Sync.LimitChats, s.filter.query(false))
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch chats: %w", err)
+	}
+	if s.cfg.Sync.IncludeGroups {
+		log.Println("Fetching group chats from UAZAPI...")
+		groups, err := s.uazapi.GetAllChats(ctx, s.cfg.Sync.LimitChats, s.filter.query(true))
+		if err != nil {
+			return nil, fmt.Errorf("failed to fetch group chats: %w", err)
+		}
+		log.Printf("Found %d group chats", len(groups))
+		chats = append(chats, groups...)
+	}
+	return chats, nil
+}
+
+// chatJob é um chat do lote já filtrado, pronto para ser processado por um worker
+type chatJob struct {
+	chat    models.UAZAPIChat
+	chatID  string
+	contact models.ChatwootContact // Vazio para grupos
+	isGroup bool
+}
+
+// processChatsBatch distribui os chats do lote entre SYNC_WORKERS workers e aguarda todos terminarem.
+// A concorrência de HTTP e de banco é limitada separadamente pelo cliente UAZAPI e pelo pool de conexões.
 func (s *Service) processChatsBatch(
+	ctx context.Context,
 	chats []models.UAZAPIChat,
 	inboxID int,
 	chatwootUser *models.ChatwootUser,
 ) error {
-	// Preparar contatos apenas para chats que têm mensagens
-	contacts := make([]models.ChatwootContact, 0, len(chats))
-	chatMap := make(map[string]models.UAZAPIChat)
-	chatsWithMessages := make(map[string]bool)
-	skippedCount := 0
+	s.addStatsChatsProcessed(len(chats))
 
-	// Primeiro, verificar quais chats têm mensagens
-	log.Printf("Checking which chats have messages...")
+	// Filtros baratos ficam no despachante; a busca de mensagens fica nos workers
+	skippedCount := 0
+	filteredCount := 0
+	pending := make([]chatJob, 0, len(chats))
 	for _, chat := range chats {
-		if chat.WAIsGroup || chat.Phone == "" {
-			skippedCount++
-			continue // Pular grupos e chats sem telefone
+		if !s.filter.allowsChat(chat) {
+			filteredCount++
+			continue
 		}
 
-		chatID := chat.WAChatID
-		if chatID == "" {
-			chatID = chat.WAChatLID
-		}
-		if chatID == "" {
+		if chat.WAIsGroup && !s.cfg.Sync.IncludeGroups {
 			skippedCount++
 			continue
 		}
 
-		// Verificar se o chat tem mensagens
-		messages, err := s.uazapi.GetAllMessages(chatID, s.cfg.Sync.LimitMessages)
-		if err != nil {
-			log.Printf("Warning: failed to check messages for chat %s: %v", chatID, err)
+		job, ok := s.newChatJob(chat)
+		if !ok {
 			skippedCount++
 			continue
 		}
+		pending = append(pending, job)
+	}
+	s.addStatsChatsSkipped(skippedCount)
+	s.addStatsChatsFiltered(filteredCount)
+
+	// Chats do mesmo contato (ex.: o do LID e o do telefone) ficam para a rodada seguinte, depois
+	// que o anterior terminou, para que dois workers não disputem o mesmo contato
+	for len(pending) > 0 && ctx.Err() == nil && s.cycleError() == nil {
+		var deferred []chatJob
+		pending, deferred = splitContactJobs(pending)
+		if len(deferred) > 0 {
+			log.Printf("Deferring %d chats that share a contact with another chat in this batch", len(deferred))
+		}
+		s.runChatJobs(ctx, pending, inboxID, chatwootUser)
+		pending = deferred
+	}
+	return nil
+}
 
-		if len(messages) == 0 {
-			log.Printf("Skipping chat %s (phone: %s) - no messages", chatID, chat.Phone)
-			skippedCount++
-			continue // Ignorar chats sem mensagens
+// splitContactJobs separa os jobs em uma rodada com no máximo um chat por contato e os demais,
+// que ficam para a rodada seguinte. Grupos sempre entram na rodada.
+func splitContactJobs(jobs []chatJob) ([]chatJob, []chatJob) {
+	var round, deferred []chatJob
+	seenContacts := make(map[string]bool)
+	for _, job := range jobs {
+		if !job.isGroup {
+			if seenContacts[job.contact.Identifier] {
+				deferred = append(deferred, job)
+				continue
+			}
+			seenContacts[job.contact.Identifier] = true
 		}
+		round = append(round, job)
+	}
+	return round, deferred
+}
 
-		phoneNumber := s.normalizePhoneNumber(chat.Phone)
-		if phoneNumber == "" {
-			skippedCount++
-			continue
+// runChatJobs processa os jobs com SYNC_WORKERS workers e aguarda todos terminarem
+func (s *Service) runChatJobs(ctx context.Context, pending []chatJob, inboxID int, chatwootUser *models.ChatwootUser) {
+	jobs := make(chan chatJob)
+	var workers sync.WaitGroup
+	for i := 0; i < s.cfg.Sync.Workers; i++ {
+		workers.Add(1)
+		go func() {
+			defer workers.Done()
+			for job := range jobs {
+				if ctx.Err() != nil || s.cycleError() != nil {
+					continue // Esvaziar a fila sem processar
+				}
+				s.processChat(ctx, job, inboxID, chatwootUser)
+			}
+		}()
+	}
+
+	for _, job := range pending {
+		if ctx.Err() != nil || s.cycleError() != nil {
+			break
 		}
+		jobs <- job
+	}
+	close(jobs)
+	workers.Wait()
+}
 
-		// Chat tem mensagens, adicionar à lista
-		chatsWithMessages[phoneNumber] = true
-		contacts = append(contacts, models.ChatwootContact{
-			PhoneNumber:   phoneNumber,
-			Name:          s.getContactName(chat),
-			Identifier:   s.buildIdentifier(phoneNumber),
-			FirstTimestamp: chat.WALastMsgTimestamp,
-			LastTimestamp:  chat.WALastMsgTimestamp,
-		})
+// newChatJob monta o job de um chat, retornando false para chats que não podem ser sincronizados
+// (sem telefone, LID ou JID). Grupos têm uma conversa por grupo e um contato por participante.
+func (s *Service) newChatJob(chat models.UAZAPIChat) (chatJob, bool) {
+	if chat.WAIsGroup {
+		return chatJob{chat: chat, chatID: chat.WAChatID, isGroup: true}, true
+	}
+	chatID := resolveChatID(chat)
+	if chatID == "" {
+		return chatJob{}, false
+	}
 
-		chatMap[phoneNumber] = chat
+	contact, ok := s.individualContact(chat.WAChatID, chat.WAChatLID, chat.Phone)
+	if !ok {
+		return chatJob{}, false
 	}
+	contact.Name = s.getContactName(chat)
+	contact.FirstTimestamp = chat.WALastMsgTimestamp
+	contact.LastTimestamp = chat.WALastMsgTimestamp
+
+	return chatJob{
+		chat:    chat,
+		chatID:  chatID,
+		contact: contact,
+	}, true
+}
 
-	// Atualizar estatísticas
-	s.addStatsChatsProcessed(len(chats))
-	s.addStatsChatsSkipped(skippedCount)
+// processChat processa um chat do lote dentro de um worker
+func (s *Service) processChat(ctx context.Context, job chatJob, inboxID int, chatwootUser *models.ChatwootUser) {
+	if err := s.syncChat(ctx, job, inboxID, chatwootUser); err != nil {
+		s.handleChatError(job.chatID, err)
+	}
+}
+
+// syncChat sincroniza um chat individual ou de grupo
+func (s *Service) syncChat(ctx context.Context, job chatJob, inboxID int, chatwootUser *models.ChatwootUser) error {
+	if job.isGroup {
+		return s.processGroupChat(ctx, job.chat, inboxID, chatwootUser)
+	}
 
-	if len(contacts) == 0 {
-		log.Printf("No chats with messages to process")
+	chat := job.chat
+	chatID := job.chatID
+
+	// Pular chats sem mensagens novas desde o último checkpoint
+	if s.isChatUnchanged(chat, chatID) {
+		s.addStatsChatsUnchanged(1)
 		return nil
 	}
 
-	log.Printf("Found %d chats with messages out of %d total chats", len(contacts), len(chats))
-	s.addStatsChatsWithMessages(len(contacts))
+	// Contato, conversa, mensagens e última atividade são gravados em uma única transação
+	contact := job.contact
+	contactID := 0
+	lookup := func() (int, error) {
+		return s.findJobConversation(ctx, job, inboxID)
+	}
+	resolve := func(db *chatwoot.Database) (*models.ChatwootFKs, error) {
+		fks, err := s.resolveContactConversation(ctx, db, contact, chat, inboxID)
+		if fks != nil {
+			contactID = fks.ContactID
+		}
+		return fks, err
+	}
+	if err := s.syncChatMessages(ctx, chatID, chat.WALastMsgTimestamp, lookup, resolve, inboxID, chatwootUser); err != nil {
+		return err
+	}
+
+	// A foto é baixada fora da transação, depois que o contato já foi gravado
+	s.syncContactAvatar(ctx, contactID, chat)
+	return nil
+}
+
+// conversationLookup retorna, sem gravar nada, o ID da conversa já existente do chat (0 se não houver)
+type conversationLookup func() (int, error)
+
+// conversationResolver busca ou cria o contato e a conversa de um chat usando a conexão (ou transação) informada
+type conversationResolver func(db *chatwoot.Database) (*models.ChatwootFKs, error)
 
-	// Criar contatos e conversas apenas para chats com mensagens
-	log.Printf("Creating/updating %d contacts and conversations...", len(contacts))
-	fksMap, err := s.chatwoot.CreateContactsAndConversations(contacts, inboxID)
+// resolveContactConversation busca ou cria o contato e a conversa de um chat individual
+func (s *Service) resolveContactConversation(
+	ctx context.Context,
+	db *chatwoot.Database,
+	contact models.ChatwootContact,
+	chat models.UAZAPIChat,
+	inboxID int,
+) (*models.ChatwootFKs, error) {
+	var fks *models.ChatwootFKs
+	var err error
+	if s.cfg.Sync.DryRun {
+		fks, err = s.planContact(ctx, db, contact, chat, inboxID)
+	} else {
+		fks, err = s.ensureContactConversation(ctx, db, contact, inboxID)
+	}
 	if err != nil {
-		return fmt.Errorf("failed to create contacts: %w", err)
+		return nil, err
 	}
 
-	log.Printf("Created/updated %d contacts and conversations", len(fksMap))
-	s.addStatsContactsCreatedUpdated(len(fksMap))
-	
-	if len(fksMap) == 0 {
-		log.Printf("WARNING: No FKs returned from CreateContactsAndConversations, but %d contacts were provided", len(contacts))
-		log.Printf("This might indicate that contacts already exist but query didn't return them, or query failed")
-		return fmt.Errorf("no contacts/conversations created or found")
+	if fks == nil {
+		return nil, fmt.Errorf("no contact/conversation created or found for %s", contact.Identifier)
+	}
+	if s.cfg.Sync.DryRun {
+		return fks, nil
+	}
+	if fks.ContactID == 0 || fks.ConversationID == 0 {
+		return nil, fmt.Errorf("invalid FK for %s: contact_id=%d, conversation_id=%d",
+			contact.Identifier, fks.ContactID, fks.ConversationID)
 	}
 
-	// Processar mensagens para cada chat
-	for phoneNumber, fks := range fksMap {
-		if fks == nil {
-			log.Printf("WARNING: FK is nil for phone %s, skipping", phoneNumber)
-			continue
+	s.addStatsContactsCreatedUpdated(1)
+	return fks, nil
+}
+
+// syncChatMessages importa as mensagens de um chat. As mensagens são lidas uma única vez, em
+// stream, e as já importadas são descartadas página a página; só as novas ficam em memória até
+// o fim do chat, pois precisam ser inseridas da mais antiga para a mais recente. O contato, a
+// conversa, as mensagens e a última atividade são gravados em uma única transação.
+func (s *Service) syncChatMessages(
+	ctx context.Context,
+	chatID string,
+	chatTimestamp int64,
+	lookup conversationLookup,
+	resolve conversationResolver,
+	inboxID int,
+	chatwootUser *models.ChatwootUser,
+) error {
+	// Serializar com o webhook para não importar a mesma mensagem duas vezes
+	unlock := s.lockChat(chatID)
+	defer unlock()
+
+	conversationID, err := lookup()
+	if err != nil {
+		return fmt.Errorf("failed to look up conversation: %w", err)
+	}
+
+	pending, statuses, newest, err := s.streamNewMessages(ctx, chatID, conversationID)
+	if err != nil {
+		return fmt.Errorf("failed to fetch messages: %w", err)
+	}
+	if newest == nil {
+		log.Printf("Skipping chat %s - no new messages", chatID)
+		s.saveCheckpoint(ctx, chatID, chatTimestamp, nil, inboxID)
+		s.addStatsChatsSkipped(1)
+		return nil
+	}
+
+	return s.storeChatMessages(ctx, chatID, chatTimestamp, conversationID, pending, statuses, newest, resolve, inboxID, chatwootUser)
+}
+
+// storeChatMessages grava as mensagens novas lidas de um chat e atualiza o status das já
+// importadas. Mesmo sem mensagens novas o contato e a conversa são resolvidos, como na
+// sincronização completa, para que mudanças de nome e identidade do chat sejam aplicadas.
+func (s *Service) storeChatMessages(
+	ctx context.Context,
+	chatID string,
+	chatTimestamp int64,
+	conversationID int,
+	pending []models.UAZAPIMessage,
+	statuses map[string]int,
+	newest *models.UAZAPIMessage,
+	resolve conversationResolver,
+	inboxID int,
+	chatwootUser *models.ChatwootUser,
+) error {
+	if len(pending) == 0 {
+		s.addStatsChatsUnchanged(1)
+	} else {
+		s.addStatsChatsWithMessages(1)
+	}
+
+	// Contato, conversa e mensagens novas são gravados juntos; o status das já importadas é
+	// atualizado na conversa resolvida dentro da transação
+	var media *apiMedia
+	var resolvedConversationID int
+	err := s.withChatTx(ctx, func(db *chatwoot.Database) error {
+		fks, err := resolve(db)
+		if err != nil {
+			return err
 		}
-		if fks.ContactID == 0 || fks.ConversationID == 0 {
-			log.Printf("WARNING: Invalid FK for phone %s: contact_id=%d, conversation_id=%d, skipping", 
-				phoneNumber, fks.ContactID, fks.ConversationID)
-			continue
+		resolvedConversationID = fks.ConversationID
+
+		// A deduplicação foi feita contra a conversa encontrada antes da transação
+		if fks.ConversationID != conversationID && fks.ConversationID != 0 {
+			count := len(pending)
+			pending, err = s.filterNewMessages(ctx, db, pending, fks.ConversationID)
+			if err != nil {
+				return err
+			}
+			s.addStatsMessagesAlreadyExist(count - len(pending))
 		}
-		select {
-		case <-s.stopChan:
-			return nil
-		default:
+
+		media, err = s.insertNewMessages(ctx, db, chatID, pending, fks, inboxID, chatwootUser)
+		if err != nil {
+			return err
 		}
+		return s.applyMessageStatuses(ctx, db, fks.ConversationID, statuses)
+	})
+	if err != nil {
+		return err
+	}
 
-		// Verificar se o chat ainda tem mensagens (pode ter mudado)
-		if !chatsWithMessages[phoneNumber] {
-			continue
+	// A API do Chatwoot grava fora da transação, então as mídias só são enviadas após o commit
+	// Mídias que não foram gravadas nem como texto mantêm o checkpoint, e o chat é tentado de novo
+	if media != nil {
+		if err := s.syncMediaMessages(ctx, media, inboxID, chatwootUser); err != nil {
+			return err
 		}
+	}
+	s.linkReplies(ctx, resolvedConversationID)
 
-		chat, exists := chatMap[phoneNumber]
-		if !exists {
-			continue
+	s.saveCheckpoint(ctx, chatID, chatTimestamp, newest, inboxID)
+	return nil
+}
+
+// streamNewMessages lê as mensagens do chat página a página (apenas as posteriores ao checkpoint,
+// menos SYNC_STATUS_LOOKBACK_HOURS, quando houver) e retorna as que ainda não existem na conversa,
+// o status das mensagens enviadas lidas e a mensagem mais recente.
+func (s *Service) streamNewMessages(ctx context.Context, chatID string, conversationID int) ([]models.UAZAPIMessage, map[string]int, *models.UAZAPIMessage, error) {
+	var checkpoint int64
+	if cp, ok := s.getCheckpoint(chatID); ok && cp.LastMessageTimestamp > 0 {
+		// Reler as últimas horas antes do checkpoint para atualizar o status das mensagens já importadas
+		checkpoint = models.UnixSeconds(cp.LastMessageTimestamp) - int64(s.cfg.Sync.StatusLookbackHours)*3600
+		if checkpoint < 0 {
+			checkpoint = 0
 		}
+	}
+	since := s.filter.messageCutoff(checkpoint)
 
-		chatID := chat.WAChatID
-		if chatID == "" {
-			chatID = chat.WAChatLID
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var pending []models.UAZAPIMessage
+	statuses := make(map[string]int)
+	var newest *models.UAZAPIMessage
+	for page := range s.uazapi.StreamMessages(ctx, chatID, s.cfg.Sync.LimitMessages, since) {
+		if page.Err != nil {
+			return nil, nil, nil, page.Err
 		}
-		if chatID == "" {
-			continue
+
+		for i := range page.Messages {
+			if newest == nil || page.Messages[i].MessageTimestamp > newest.MessageTimestamp {
+				msg := page.Messages[i]
+				newest = &msg
+			}
 		}
 
-		if err := s.syncChatMessages(chatID, fks, inboxID, chatwootUser); err != nil {
-			log.Printf("Error syncing messages for chat %s: %v", chatID, err)
-			// Continue com próximo chat
+		newMessages, err := s.filterNewMessages(ctx, s.chatwoot, page.Messages, conversationID)
+		if err != nil {
+			return nil, nil, nil, err
+		}
+		s.addStatsMessagesChecked(len(page.Messages))
+		s.addStatsMessagesAlreadyExist(len(page.Messages) - len(newMessages))
+		pending = append(pending, newMessages...)
+		for sourceID, status := range messageStatusUpdates(page.Messages) {
+			statuses[sourceID] = status
 		}
 	}
+	// O stream também termina sem erro quando ctx é cancelado; não tratar como chat completo
+	if err := ctx.Err(); err != nil {
+		return nil, nil, nil, err
+	}
 
-	return nil
+	if newest != nil {
+		log.Printf("Found %d new messages for chat %s", len(pending), chatID)
+	}
+	return pending, statuses, newest, nil
 }
 
-func (s *Service) syncChatMessages(
+// withChatTx executa a gravação de um chat em uma transação. Em dry-run nada é gravado,
+// então fn recebe a conexão direta.
+func (s *Service) withChatTx(ctx context.Context, fn func(db *chatwoot.Database) error) error {
+	if s.cfg.Sync.DryRun {
+		return fn(s.chatwoot)
+	}
+	return s.chatwoot.WithTx(ctx, fn)
+}
+
+// importMessages insere no Chatwoot as mensagens ainda não importadas da conversa.
+// É usado pelo webhook; as mídias enviadas pela API do Chatwoot são retornadas para
+// envio após o commit da transação.
+func (s *Service) importMessages(
+	ctx context.Context,
+	db *chatwoot.Database,
 	chatID string,
+	messages []models.UAZAPIMessage,
 	fks *models.ChatwootFKs,
 	inboxID int,
 	chatwootUser *models.ChatwootUser,
-) error {
-	// Buscar todas as mensagens do chat
-	messages, err := s.uazapi.GetAllMessages(chatID, s.cfg.Sync.LimitMessages)
+) (*apiMedia, error) {
+	pending, err := s.filterNewMessages(ctx, db, messages, fks.ConversationID)
 	if err != nil {
-		return fmt.Errorf("failed to fetch messages: %w", err)
+		return nil, err
 	}
+	s.addStatsMessagesChecked(len(messages))
+	s.addStatsMessagesAlreadyExist(len(messages) - len(pending))
+
+	media, err := s.insertNewMessages(ctx, db, chatID, pending, fks, inboxID, chatwootUser)
+	if err != nil {
+		return nil, err
+	}
+	return media, s.applyMessageStatuses(ctx, db, fks.ConversationID, messageStatusUpdates(messages))
+}
 
+// filterNewMessages descarta as mensagens que já existem na conversa
+func (s *Service) filterNewMessages(
+	ctx context.Context,
+	db *chatwoot.Database,
+	messages []models.UAZAPIMessage,
+	conversationID int,
+) ([]models.UAZAPIMessage, error) {
 	if len(messages) == 0 {
-		return nil
+		return nil, nil
 	}
 
-	log.Printf("Processing %d total messages for chat %s", len(messages), chatID)
-	s.addStatsMessagesChecked(len(messages))
+	// Conversas que ainda não existem não têm mensagens
+	if conversationID == 0 {
+		return messages, nil
+	}
 
-	// Verificar mensagens existentes
 	sourceIDs := make([]string, 0, len(messages))
 	for _, msg := range messages {
 		sourceIDs = append(sourceIDs, fmt.Sprintf("WAID:%s", msg.MessageID))
 	}
 
-	existing, err := s.chatwoot.CheckExistingMessages(sourceIDs, fks.ConversationID)
+	existing, err := db.CheckExistingMessages(ctx, sourceIDs, conversationID)
 	if err != nil {
-		return fmt.Errorf("failed to check existing messages: %w", err)
+		return nil, fmt.Errorf("failed to check existing messages: %w", err)
+	}
+
+	newMessages := make([]models.UAZAPIMessage, 0, len(messages)-len(existing))
+	for _, msg := range messages {
+		if !existing[fmt.Sprintf("WAID:%s", msg.MessageID)] {
+			newMessages = append(newMessages, msg)
+		}
+	}
+	return newMessages, nil
+}
+
+// insertNewMessages insere mensagens já deduplicadas na conversa, da mais antiga para a mais recente,
+// e atualiza a última atividade. As mídias enviadas pela API do Chatwoot são retornadas para envio
+// após o commit da transação.
+func (s *Service) insertNewMessages(
+	ctx context.Context,
+	db *chatwoot.Database,
+	chatID string,
+	messages []models.UAZAPIMessage,
+	fks *models.ChatwootFKs,
+	inboxID int,
+	chatwootUser *models.ChatwootUser,
+) (*apiMedia, error) {
+	if len(messages) == 0 {
+		return nil, nil
 	}
 
-	log.Printf("Found %d existing messages out of %d total for chat %s", len(existing), len(messages), chatID)
-	s.addStatsMessagesAlreadyExist(len(existing))
+	// Em grupos, cada mensagem recebida é atribuída ao contato do participante
+	var participants map[string]int
+	if isGroupChatID(chatID) {
+		var err error
+		participants, err = s.resolveGroupParticipants(ctx, db, chatID, messages)
+		if err != nil {
+			return nil, fmt.Errorf("failed to resolve group participants: %w", err)
+		}
+	}
 
 	// Filtrar apenas mensagens novas
 	newMessages := make([]models.ChatwootMessage, 0)
+	mediaMessages := make([]models.UAZAPIMessage, 0)
+	pendingMedia := make(map[string]models.UAZAPIMessage)
 	var lastTimestamp int64
 
 	for _, msg := range messages {
 		sourceID := fmt.Sprintf("WAID:%s", msg.MessageID)
-		if existing[sourceID] {
-			continue
+
+		if msg.MessageTimestamp > lastTimestamp {
+			lastTimestamp = msg.MessageTimestamp
+		}
+
+		// Mídias são gravadas via ActiveStorage no mesmo lote, ou enviadas pela API do Chatwoot
+		if _, isMedia := getMediaKind(msg); isMedia {
+			if s.chatwoot.HasStorage() {
+				pendingMedia[sourceID] = msg
+			} else if s.api.IsConfigured() {
+				mediaMessages = append(mediaMessages, msg)
+				continue
+			}
 		}
 
 		content := s.extractMessageContent(msg)
@@ -286,19 +719,7 @@ func (s *Service) syncChatMessages(
 			continue // Pular mensagens vazias
 		}
 
-		messageType := "0" // incoming
-		senderType := "Contact"
-		senderID := fks.ContactID
-
-		if msg.FromMe {
-			messageType = "1" // outgoing
-			senderType = chatwootUser.UserType
-			senderID = chatwootUser.UserID
-		}
-
-		if msg.MessageTimestamp > lastTimestamp {
-			lastTimestamp = msg.MessageTimestamp
-		}
+		messageType, senderType, senderID := s.messageSender(msg, fks, chatwootUser, participants)
 
 		newMessages = append(newMessages, models.ChatwootMessage{
 			Content:          content,
@@ -308,14 +729,22 @@ func (s *Service) syncChatMessages(
 			SenderID:         senderID,
 			SourceID:         sourceID,
 			MessageTimestamp: msg.MessageTimestamp,
+			InReplyTo:        quotedSourceID(msg),
+			Status:           messageStatus(msg),
 		})
 	}
 
-	log.Printf("Prepared %d new messages to insert for chat %s", len(newMessages), chatID)
+	log.Printf("Prepared %d new messages and %d media messages to insert for chat %s",
+		len(newMessages), len(mediaMessages), chatID)
 
-	if len(newMessages) == 0 {
+	if len(newMessages) == 0 && len(mediaMessages) == 0 {
 		log.Printf("No new messages to insert for chat %s", chatID)
-		return nil
+		return nil, nil
+	}
+
+	if s.cfg.Sync.DryRun {
+		s.planMessages(chatID, len(newMessages)+len(mediaMessages), len(pendingMedia)+len(mediaMessages))
+		return nil, nil
 	}
 
 	// Ordenar mensagens por timestamp (mais antigas primeiro) para garantir ordem cronológica
@@ -335,9 +764,17 @@ func (s *Service) syncChatMessages(
 		}
 
 		batch := newMessages[i:end]
-		inserted, err := s.chatwoot.InsertMessages(batch, inboxID)
+		mediaAttached, mediaFailed := s.attachMedia(ctx, batch, pendingMedia)
+		inserted, err := db.InsertMessages(ctx, batch, inboxID)
 		if err != nil {
-			return fmt.Errorf("failed to insert messages: %w", err)
+			return nil, fmt.Errorf("failed to insert messages: %w", err)
+		}
+		s.addStatsMediaMessagesInserted(mediaAttached)
+		s.addStatsMediaMessagesFailed(mediaFailed)
+
+		// Liberar o conteúdo das mídias já gravadas
+		for j := range batch {
+			batch[j].Attachment = nil
 		}
 
 		totalInserted += inserted
@@ -350,34 +787,200 @@ func (s *Service) syncChatMessages(
 
 	// Atualizar última atividade
 	if lastTimestamp > 0 {
-		if err := s.chatwoot.UpdateConversationLastActivity(fks.ConversationID, lastTimestamp); err != nil {
-			log.Printf("Warning: failed to update conversation activity: %v", err)
+		if err := db.UpdateConversationLastActivity(ctx, fks.ConversationID, lastTimestamp); err != nil {
+			return nil, fmt.Errorf("failed to update conversation activity: %w", err)
 		}
 	}
 
+	if len(mediaMessages) == 0 {
+		return nil, nil
+	}
+	return &apiMedia{
+		chatID:       chatID,
+		messages:     mediaMessages,
+		fks:          fks,
+		participants: participants,
+	}, nil
+}
+
+// syncMediaMessages envia as mídias do chat em ordem cronológica, usando texto como fallback em caso
+// de falha. Retorna erro se alguma mensagem não foi gravada nem como texto.
+func (s *Service) syncMediaMessages(ctx context.Context, media *apiMedia, inboxID int, chatwootUser *models.ChatwootUser) error {
+	chatID, messages, fks, participants := media.chatID, media.messages, media.fks, media.participants
+	sort.Slice(messages, func(i, j int) bool {
+		return messages[i].MessageTimestamp < messages[j].MessageTimestamp
+	})
+
+	log.Printf("Syncing %d media messages for chat %s", len(messages), chatID)
+
+	inserted := 0
+	failed := 0
+	lost := 0
+	for _, msg := range messages {
+		kind, _ := getMediaKind(msg)
+		messageType, senderType, senderID := s.messageSender(msg, fks, chatwootUser, participants)
+		err := s.syncMediaMessage(ctx, msg, kind, fks)
+		if err == nil {
+			inserted++
+			// A API atribui mensagens recebidas ao contato da conversa; corrigir em grupos
+			if senderID != fks.ContactID && !msg.FromMe {
+				sourceID := fmt.Sprintf("WAID:%s", msg.MessageID)
+				if err := s.chatwoot.UpdateMessageSender(ctx, sourceID, fks.ConversationID, senderType, senderID); err != nil {
+					log.Printf("Warning: failed to update sender for media message %s: %v", sourceID, err)
+				}
+			}
+			continue
+		}
+
+		log.Printf("Warning: failed to sync media message %s for chat %s: %v", msg.MessageID, chatID, err)
+		failed++
+
+		// Inserir como texto para não perder a mensagem (e não tentar novamente a cada execução)
+		fallback := models.ChatwootMessage{
+			Content:          s.extractMessageContent(msg),
+			ConversationID:   fks.ConversationID,
+			MessageType:      messageType,
+			SenderType:       senderType,
+			SenderID:         senderID,
+			SourceID:         fmt.Sprintf("WAID:%s", msg.MessageID),
+			MessageTimestamp: msg.MessageTimestamp,
+			InReplyTo:        quotedSourceID(msg),
+			Status:           messageStatus(msg),
+		}
+		if _, err := s.chatwoot.InsertMessages(ctx, []models.ChatwootMessage{fallback}, inboxID); err != nil {
+			log.Printf("Warning: failed to insert fallback for media message %s: %v", msg.MessageID, err)
+			lost++
+		}
+	}
+
+	log.Printf("Synced %d media messages for chat %s (%d failed)", inserted, chatID, failed)
+	s.addStatsMediaMessagesInserted(inserted)
+	s.addStatsMediaMessagesFailed(failed)
+	if lost > 0 {
+		return fmt.Errorf("failed to insert %d media messages for chat %s, even as text", lost, chatID)
+	}
 	return nil
 }
 
-func (s *Service) normalizePhoneNumber(phone string) string {
-	// Remove espaços e caracteres especiais
-	phone = strings.ReplaceAll(phone, " ", "")
-	phone = strings.ReplaceAll(phone, "-", "")
-	phone = strings.ReplaceAll(phone, "(", "")
-	phone = strings.ReplaceAll(phone, ")", "")
+// messageSender retorna o message_type, sender_type e sender_id da mensagem no Chatwoot
+func (s *Service) messageSender(
+	msg models.UAZAPIMessage,
+	fks *models.ChatwootFKs,
+	chatwootUser *models.ChatwootUser,
+	participants map[string]int,
+) (string, string, int) {
+	if msg.FromMe {
+		return "1", chatwootUser.UserType, chatwootUser.UserID // outgoing
+	}
+
+	senderID := fks.ContactID
+	if participants != nil {
+		if participant, ok := s.participantFromMessage(msg); ok {
+			if contactID, found := participants[participant.Identifier]; found {
+				senderID = contactID
+			}
+		}
+	}
+
+	return "0", "Contact", senderID // incoming
+}
+
+// ensureContactConversation busca ou cria o contato e a conversa de um chat individual. O LID é
+// associado ao contato do telefone (mesclando contatos criados antes só com o LID); sem telefone,
+// o contato é identificado apenas pelo LID.
+func (s *Service) ensureContactConversation(
+	ctx context.Context,
+	db *chatwoot.Database,
+	contact models.ChatwootContact,
+	inboxID int,
+) (*models.ChatwootFKs, error) {
+	if contact.PhoneNumber == "" {
+		fks, err := db.CreateLIDConversation(ctx, contact, inboxID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create contact: %w", err)
+		}
+		return fks, nil
+	}
+
+	contact, err := s.matchContactPhone(ctx, db, contact)
+	if err != nil {
+		return nil, err
+	}
+	if err := db.LinkContactLID(ctx, contact); err != nil {
+		return nil, err
+	}
+
+	fksMap, err := db.CreateContactsAndConversations(ctx, []models.ChatwootContact{contact}, inboxID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create contact: %w", err)
+	}
+	return fksMap[contact.PhoneNumber], nil
+}
+
+// individualContact monta a identidade do contato de um chat individual: com telefone, o
+// identifier é o JID de telefone; sem ele, o contato é identificado pelo LID. Retorna false
+// quando não há nenhum dos dois.
+func (s *Service) individualContact(jid, lid, phoneNumber string) (models.ChatwootContact, bool) {
+	contact := models.ChatwootContact{
+		PhoneNumber: s.contactPhone(jid, phoneNumber),
+		LID:         lidJID(lid, jid),
+	}
+	switch {
+	case contact.PhoneNumber != "":
+		contact.Identifier = s.buildIdentifier(contact.PhoneNumber)
+	case contact.LID != "":
+		contact.Identifier = contact.LID
+	default:
+		return contact, false
+	}
+	return contact, true
+}
 
-	// Garantir que começa com +
-	if !strings.HasPrefix(phone, "+") {
-		// Se não tem +, assumir que é número brasileiro
-		if strings.HasPrefix(phone, "55") {
-			phone = "+" + phone
-		} else if len(phone) >= 10 {
-			phone = "+55" + phone
-		} else {
-			return ""
+// lidJID retorna o primeiro dos JIDs informados que é um LID (@lid), sem o sufixo de dispositivo
+func lidJID(jids ...string) string {
+	for _, jid := range jids {
+		jid = strings.ToLower(strings.TrimSpace(jid))
+		if !strings.HasSuffix(jid, "@lid") {
+			continue
+		}
+		user := strings.TrimSuffix(jid, "@lid")
+		if colon := strings.Index(user, ":"); colon >= 0 {
+			user = user[:colon]
 		}
+		if user != "" {
+			return user + "@lid"
+		}
+	}
+	return ""
+}
+
+// contactPhone retorna o telefone do contato em E.164. O JID do chat é a fonte de verdade; o
+// telefone informado só é usado (na região padrão) quando o JID não é de um número.
+func (s *Service) contactPhone(jid, phoneNumber string) string {
+	if e164 := phone.FromJID(jid); e164 != "" {
+		return e164
+	}
+	return s.phones.Normalize(phoneNumber)
+}
+
+// matchContactPhone troca o telefone do contato pela grafia já usada no Chatwoot quando o
+// contato existe apenas com outra variante do número (ex.: celular brasileiro sem o nono dígito)
+func (s *Service) matchContactPhone(ctx context.Context, db *chatwoot.Database, contact models.ChatwootContact) (models.ChatwootContact, error) {
+	alternatives := phone.Alternatives(contact.PhoneNumber)
+	if len(alternatives) == 0 {
+		return contact, nil
 	}
 
-	return phone
+	found, err := db.FindContactPhone(ctx, append([]string{contact.PhoneNumber}, alternatives...))
+	if err != nil {
+		return contact, err
+	}
+	if found != "" && found != contact.PhoneNumber {
+		log.Printf("Contact %s matched existing contact with phone %s", contact.PhoneNumber, found)
+		contact.PhoneNumber = found
+		contact.Identifier = s.buildIdentifier(found)
+	}
+	return contact, nil
 }
 
 func (s *Service) buildIdentifier(phoneNumber string) string {
@@ -399,10 +1002,37 @@ func (s *Service) getContactName(chat models.UAZAPIChat) string {
 	return chat.Phone
 }
 
+// quotedSourceID retorna o source_id (WAID:) da mensagem citada, ou "" se a mensagem não cita outra
+func quotedSourceID(msg models.UAZAPIMessage) string {
+	quoted := strings.TrimSpace(msg.Quoted)
+	// O ID também pode vir completo ({dono}:{messageid}), como no campo id da mensagem
+	if colon := strings.LastIndex(quoted, ":"); colon >= 0 {
+		quoted = quoted[colon+1:]
+	}
+	if quoted == "" {
+		return ""
+	}
+	return "WAID:" + quoted
+}
+
+// linkReplies resolve, ao fim do chat, as citações de mensagens que só foram importadas depois da
+// resposta (ex.: mídias enviadas pela API após o commit)
+func (s *Service) linkReplies(ctx context.Context, conversationID int) {
+	if s.cfg.Sync.DryRun || conversationID == 0 {
+		return
+	}
+	if _, err := s.chatwoot.LinkReplies(ctx, conversationID); err != nil {
+		log.Printf("Warning: failed to link replies in conversation %d: %v", conversationID, err)
+	}
+}
+
 func (s *Service) extractMessageContent(msg models.UAZAPIMessage) string {
 	if msg.Text != "" {
 		return msg.Text
 	}
+	if kind, isMedia := getMediaKind(msg); isMedia {
+		return kind.Placeholder
+	}
 	return msg.MessageType
 }
 
@@ -417,10 +1047,20 @@ func (s *Service) printReport() {
 	log.Printf("Total de chats processados:        %d", s.stats.TotalChatsProcessed)
 	log.Printf("Chats com mensagens:               %d", s.stats.ChatsWithMessages)
 	log.Printf("Chats ignorados (sem mensagens):   %d", s.stats.ChatsSkipped)
+	log.Printf("Chats sem novidades (checkpoint):  %d", s.stats.ChatsUnchanged)
+	log.Printf("Chats fora dos filtros:            %d", s.stats.ChatsFiltered)
+	log.Printf("Chats com falha:                   %d", s.stats.ChatsFailed)
 	log.Printf("Total de mensagens verificadas:    %d", s.stats.TotalMessagesChecked)
 	log.Printf("Mensagens já existentes:           %d", s.stats.MessagesAlreadyExist)
 	log.Printf("Mensagens novas inseridas:         %d", s.stats.MessagesInserted)
+	log.Printf("Status de mensagens atualizados:   %d", s.stats.MessageStatusesUpdated)
+	log.Printf("Mídias inseridas como anexo:       %d", s.stats.MediaMessagesInserted)
+	log.Printf("Mídias com falha (texto):          %d", s.stats.MediaMessagesFailed)
 	log.Printf("Contatos criados/atualizados:      %d", s.stats.ContactsCreatedUpdated)
+	log.Printf("Avatares atualizados:              %d", s.stats.AvatarsUpdated)
+	if runID := s.chatwoot.RunID(); runID != "" {
+		log.Printf("Execução (para rollback):          %s", runID)
+	}
 	log.Println("========================================")
 	log.Println("")
 }
@@ -443,6 +1083,18 @@ func (s *Service) addStatsChatsSkipped(count int) {
 	s.stats.ChatsSkipped += count
 }
 
+func (s *Service) addStatsChatsUnchanged(count int) {
+	s.statsMutex.Lock()
+	defer s.statsMutex.Unlock()
+	s.stats.ChatsUnchanged += count
+}
+
+func (s *Service) addStatsChatsFiltered(count int) {
+	s.statsMutex.Lock()
+	defer s.statsMutex.Unlock()
+	s.stats.ChatsFiltered += count
+}
+
 func (s *Service) addStatsMessagesChecked(count int) {
 	s.statsMutex.Lock()
 	defer s.statsMutex.Unlock()
@@ -461,14 +1113,122 @@ func (s *Service) addStatsMessagesInserted(count int) {
 	s.stats.MessagesInserted += count
 }
 
+func (s *Service) addStatsMediaMessagesInserted(count int) {
+	s.statsMutex.Lock()
+	defer s.statsMutex.Unlock()
+	s.stats.MediaMessagesInserted += count
+}
+
+func (s *Service) addStatsMediaMessagesFailed(count int) {
+	s.statsMutex.Lock()
+	defer s.statsMutex.Unlock()
+	s.stats.MediaMessagesFailed += count
+}
+
+func (s *Service) addStatsMessageStatusesUpdated(count int) {
+	s.statsMutex.Lock()
+	defer s.statsMutex.Unlock()
+	s.stats.MessageStatusesUpdated += count
+}
+
+func (s *Service) addStatsAvatarsUpdated(count int) {
+	s.statsMutex.Lock()
+	defer s.statsMutex.Unlock()
+	s.stats.AvatarsUpdated += count
+}
+
 func (s *Service) addStatsContactsCreatedUpdated(count int) {
 	s.statsMutex.Lock()
 	defer s.statsMutex.Unlock()
 	s.stats.ContactsCreatedUpdated += count
 }
 
+func (s *Service) addStatsChatsFailed(count int) {
+	s.statsMutex.Lock()
+	defer s.statsMutex.Unlock()
+	s.stats.ChatsFailed += count
+}
+
+func (s *Service) resetStats() {
+	s.statsMutex.Lock()
+	defer s.statsMutex.Unlock()
+	s.stats = Stats{}
+	s.plans = make(map[string]*chatPlan)
+	s.cycleErr = nil
+}
+
+// handleChatError registra a falha de um chat. Falhas temporárias apenas adiam o chat para o
+// próximo ciclo (o checkpoint não avança); um token recusado pela UAZAPI interrompe o ciclo,
+// pois todos os chats restantes falhariam da mesma forma.
+func (s *Service) handleChatError(chatID string, err error) {
+	// Chats interrompidos por Stop não contam como falha
+	if errors.Is(err, context.Canceled) {
+		return
+	}
+	s.addStatsChatsFailed(1)
+
+	switch {
+	case errors.Is(err, uazapi.ErrUnauthorized):
+		log.Printf("Error syncing chat %s: UAZAPI rejected the token, aborting cycle: %v", chatID, err)
+		s.statsMutex.Lock()
+		if s.cycleErr == nil {
+			s.cycleErr = err
+		}
+		s.statsMutex.Unlock()
+	case uazapi.IsTransient(err):
+		log.Printf("Warning: transient failure syncing chat %s, it will be retried in the next cycle: %v", chatID, err)
+	default:
+		log.Printf("Error syncing chat %s: %v", chatID, err)
+	}
+}
+
+// cycleError retorna o erro permanente que interrompeu o ciclo atual, se houver
+func (s *Service) cycleError() error {
+	s.statsMutex.Lock()
+	defer s.statsMutex.Unlock()
+	return s.cycleErr
+}
+
+// lockChat bloqueia o chat até que a função retornada seja chamada
+func (s *Service) lockChat(chatID string) func() {
+	s.chatLocksMutex.Lock()
+	lock, exists := s.chatLocks[chatID]
+	if !exists {
+		lock = &chatLock{}
+		s.chatLocks[chatID] = lock
+	}
+	lock.refs++
+	s.chatLocksMutex.Unlock()
+
+	lock.mutex.Lock()
+
+	return func() {
+		lock.mutex.Unlock()
+
+		s.chatLocksMutex.Lock()
+		lock.refs--
+		if lock.refs == 0 {
+			delete(s.chatLocks, chatID)
+		}
+		s.chatLocksMutex.Unlock()
+	}
+}
+
+// Go executa run em background e envia seu resultado no canal retornado. A execução é
+// registrada antes de iniciar, então um Stop chamado logo em seguida sempre aguarda o seu fim.
+func (s *Service) Go(run func() error) <-chan error {
+	done := make(chan error, 1)
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+		done <- run()
+	}()
+	return done
+}
+
 func (s *Service) Stop() {
-	close(s.stopChan)
+	// Cancelar o contexto interrompe imediatamente as requisições HTTP e consultas em andamento
+	s.stopOnce.Do(s.cancel)
 	s.wg.Wait()
 }
 
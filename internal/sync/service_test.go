@@ -0,0 +1,156 @@
+package sync
+
+import (
+	"chatwoot-sync-go/internal/chatwoot"
+	"chatwoot-sync-go/internal/config"
+	"chatwoot-sync-go/internal/models"
+	"chatwoot-sync-go/internal/phone"
+	"chatwoot-sync-go/internal/uazapi"
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+)
+
+func TestQuotedSourceID(t *testing.T) {
+	tests := []struct {
+		name   string
+		quoted string
+		want   string
+	}{
+		{"sem citação", "", ""},
+		{"apenas espaços", "  ", ""},
+		{"ID da mensagem", "3EB0C767D26A1D2E5F4B", "WAID:3EB0C767D26A1D2E5F4B"},
+		{"ID completo com dono", "5511987654321:3EB0C767D26A1D2E5F4B", "WAID:3EB0C767D26A1D2E5F4B"},
+		{"espaços removidos", " 3EB0C767D26A1D2E5F4B\n", "WAID:3EB0C767D26A1D2E5F4B"},
+		{"dono sem ID", "5511987654321:", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := quotedSourceID(models.UAZAPIMessage{Quoted: tt.quoted}); got != tt.want {
+				t.Errorf("quotedSourceID(%q) = %q, esperado %q", tt.quoted, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestQuotedBridgedMessage(t *testing.T) {
+	// A ponte envia uma mensagem do Chatwoot com dois anexos: a UAZAPI cria uma mensagem por anexo
+	sent := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		sent++
+		fmt.Fprintf(w, `{"messageid":"3EB0%04d"}`, sent)
+	}))
+	defer server.Close()
+
+	cfg := &config.Config{UAZAPI: config.UAZAPIConfig{BaseURL: server.URL, MaxConcurrency: 1}}
+	s := &Service{cfg: cfg, uazapi: uazapi.NewClient(cfg)}
+	event := &models.ChatwootWebhookEvent{Attachments: []models.ChatwootWebhookAttachment{
+		{FileType: "image", DataURL: "https://chatwoot.example/foto.jpg"},
+		{FileType: "file", DataURL: "https://chatwoot.example/contrato.pdf"},
+	}}
+	sourceIDs, err := s.sendToWhatsApp(context.Background(), "5511987654321", event)
+	if err != nil || len(sourceIDs) != 2 {
+		t.Fatalf("sendToWhatsApp() = %v, %v, esperado dois source_ids", sourceIDs, err)
+	}
+
+	// O contato responde citando o segundo anexo, que só existe em bridged_source_ids (o source_id
+	// da mensagem é o primeiro); a citação precisa ter exatamente o mesmo formato
+	bridged := sourceIDs[1:]
+	reply := models.UAZAPIMessage{Quoted: "5511987654321:3EB00002"}
+	if got := quotedSourceID(reply); got != bridged[0] {
+		t.Errorf("quotedSourceID() = %q, esperado %q de bridged_source_ids %v", got, bridged[0], bridged)
+	}
+}
+
+func TestSplitContactJobs(t *testing.T) {
+	person := models.ChatwootContact{Identifier: "5511987654321@s.whatsapp.net"}
+	other := models.ChatwootContact{Identifier: "5511912345678@s.whatsapp.net"}
+	jobs := []chatJob{
+		{chatID: "5511987654321@s.whatsapp.net", contact: person},
+		{chatID: "123456789@lid", contact: person},
+		{chatID: "120363025246125486@g.us", isGroup: true},
+		{chatID: "5511912345678@s.whatsapp.net", contact: other},
+		{chatID: "987654321@lid", contact: person},
+		{chatID: "120363025246125487@g.us", isGroup: true},
+	}
+
+	var rounds [][]string
+	for pending := jobs; len(pending) > 0; {
+		var round []chatJob
+		round, pending = splitContactJobs(pending)
+		var chatIDs []string
+		for _, job := range round {
+			chatIDs = append(chatIDs, job.chatID)
+		}
+		rounds = append(rounds, chatIDs)
+	}
+
+	// Os chats do mesmo contato vão para rodadas seguintes, na ordem do lote, sem serem descartados
+	want := [][]string{
+		{"5511987654321@s.whatsapp.net", "120363025246125486@g.us", "5511912345678@s.whatsapp.net", "120363025246125487@g.us"},
+		{"123456789@lid"},
+		{"987654321@lid"},
+	}
+	if !reflect.DeepEqual(rounds, want) {
+		t.Errorf("rodadas = %v, esperado %v", rounds, want)
+	}
+}
+
+func TestStoreChatMessagesWithoutNewMessages(t *testing.T) {
+	s := &Service{cfg: &config.Config{Sync: config.SyncConfig{DryRun: true}}}
+
+	// O chat foi relido porque o contato mudou de nome, mas todas as mensagens já estavam
+	// importadas; é resolve que grava o nome novo (ou o planeja, em dry-run)
+	resolved := false
+	resolve := func(db *chatwoot.Database) (*models.ChatwootFKs, error) {
+		resolved = true
+		return &models.ChatwootFKs{ContactID: 1, ConversationID: 2}, nil
+	}
+	newest := &models.UAZAPIMessage{MessageID: "A", MessageTimestamp: 1700000000}
+	statuses := map[string]int{"WAID:A": chatwoot.MessageStatusRead}
+
+	err := s.storeChatMessages(context.Background(), "5511987654321@s.whatsapp.net", 1700000000, 2,
+		nil, statuses, newest, resolve, 1, nil)
+	if err != nil {
+		t.Fatalf("storeChatMessages() erro = %v", err)
+	}
+	if !resolved {
+		t.Errorf("contato não foi resolvido, esperado nome atualizado mesmo sem mensagens novas")
+	}
+	if s.stats.ChatsUnchanged != 1 || s.stats.ChatsWithMessages != 0 {
+		t.Errorf("ChatsUnchanged = %d, ChatsWithMessages = %d, esperado 1 e 0",
+			s.stats.ChatsUnchanged, s.stats.ChatsWithMessages)
+	}
+}
+
+func TestIndividualContactLIDOnly(t *testing.T) {
+	s := &Service{phones: phone.NewNormalizer("BR")}
+
+	tests := []struct {
+		name  string
+		jid   string
+		lid   string
+		phone string
+		want  models.ChatwootContact
+	}{
+		{"só o LID", "123456789012345:3@lid", "", "",
+			models.ChatwootContact{Identifier: "123456789012345@lid", LID: "123456789012345@lid"}},
+		{"LID com telefone informado", "123456789012345@lid", "", "(11) 98765-4321",
+			models.ChatwootContact{Identifier: "5511987654321@s.whatsapp.net", PhoneNumber: "+5511987654321", LID: "123456789012345@lid"}},
+		{"LID com dígitos de telefone", "5511987654321@lid", "", "",
+			models.ChatwootContact{Identifier: "5511987654321@lid", LID: "5511987654321@lid"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := s.individualContact(tt.jid, tt.lid, tt.phone)
+			if !ok || !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("individualContact(%q) = %+v, %v, esperado %+v", tt.jid, got, ok, tt.want)
+			}
+		})
+	}
+}
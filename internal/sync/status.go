@@ -0,0 +1,94 @@
+package sync
+
+import (
+	"chatwoot-sync-go/internal/chatwoot"
+	"chatwoot-sync-go/internal/models"
+	"context"
+	"fmt"
+	"log"
+	"strings"
+)
+
+// messageStatuses mapeia o status das mensagens da UAZAPI (e os tipos de confirmação recebidos
+// pelo webhook), em minúsculas, para o status do Chatwoot
+var messageStatuses = map[string]int{
+	"pending":     chatwoot.MessageStatusSent,
+	"sent":        chatwoot.MessageStatusSent,
+	"serverack":   chatwoot.MessageStatusSent,
+	"delivered":   chatwoot.MessageStatusDelivered,
+	"deliveryack": chatwoot.MessageStatusDelivered,
+	"read":        chatwoot.MessageStatusRead,
+	"readack":     chatwoot.MessageStatusRead,
+	"played":      chatwoot.MessageStatusRead,
+	"failed":      chatwoot.MessageStatusFailed,
+	"error":       chatwoot.MessageStatusFailed,
+	"servererror": chatwoot.MessageStatusFailed,
+}
+
+// lookupMessageStatus converte o status da UAZAPI, indicando se ele é conhecido
+func lookupMessageStatus(status string) (int, bool) {
+	value, ok := messageStatuses[strings.ToLower(strings.TrimSpace(status))]
+	return value, ok
+}
+
+// messageStatus retorna o status do Chatwoot para a mensagem. Só mensagens enviadas têm status
+// de entrega; as recebidas e as de status desconhecido ficam como enviadas.
+func messageStatus(msg models.UAZAPIMessage) int {
+	if !msg.FromMe {
+		return chatwoot.MessageStatusSent
+	}
+	status, _ := lookupMessageStatus(msg.Status)
+	return status
+}
+
+// messageStatusUpdates retorna, indexado pelo source_id, o status das mensagens enviadas que já
+// foram entregues, lidas ou falharam. Mensagens ainda não importadas são ignoradas na atualização.
+func messageStatusUpdates(messages []models.UAZAPIMessage) map[string]int {
+	statuses := make(map[string]int)
+	for _, msg := range messages {
+		if !msg.FromMe {
+			continue
+		}
+		if status, ok := lookupMessageStatus(msg.Status); ok && status != chatwoot.MessageStatusSent {
+			statuses[fmt.Sprintf("WAID:%s", msg.MessageID)] = status
+		}
+	}
+	return statuses
+}
+
+// applyMessageStatuses atualiza o status das mensagens enviadas já importadas na conversa cujo
+// status no WhatsApp avançou desde a importação (ex.: entregue → lida)
+func (s *Service) applyMessageStatuses(ctx context.Context, db *chatwoot.Database, conversationID int, statuses map[string]int) error {
+	if s.cfg.Sync.DryRun || conversationID == 0 {
+		return nil
+	}
+
+	updated, err := db.UpdateMessageStatuses(ctx, s.inboxID, conversationID, statuses)
+	if err != nil {
+		return err
+	}
+	s.addStatsMessageStatusesUpdated(updated)
+	return nil
+}
+
+// handleWebhookReceipt aplica as confirmações de entrega e leitura recebidas pelo webhook às
+// mensagens já importadas do inbox
+func (s *Service) handleWebhookReceipt(ctx context.Context, update models.UAZAPIMessageUpdate) error {
+	status, ok := lookupMessageStatus(update.Type)
+	if !ok || len(update.MessageIDs) == 0 {
+		log.Printf("Webhook: ignoring %s update for %d messages in chat %s",
+			update.Type, len(update.MessageIDs), update.Chat)
+		return nil
+	}
+
+	statuses := make(map[string]int, len(update.MessageIDs))
+	for _, messageID := range update.MessageIDs {
+		statuses[fmt.Sprintf("WAID:%s", messageID)] = status
+	}
+	updated, err := s.chatwoot.UpdateMessageStatuses(ctx, s.inboxID, 0, statuses)
+	if err != nil {
+		return err
+	}
+	s.addStatsMessageStatusesUpdated(updated)
+	return nil
+}
@@ -0,0 +1,84 @@
+package sync
+
+import (
+	"chatwoot-sync-go/internal/chatwoot"
+	"chatwoot-sync-go/internal/models"
+	"reflect"
+	"testing"
+)
+
+func TestLookupMessageStatus(t *testing.T) {
+	tests := []struct {
+		status string
+		want   int
+		known  bool
+	}{
+		{"Pending", chatwoot.MessageStatusSent, true},
+		{"sent", chatwoot.MessageStatusSent, true},
+		{"ServerAck", chatwoot.MessageStatusSent, true},
+		{"Delivered", chatwoot.MessageStatusDelivered, true},
+		{"DeliveryAck", chatwoot.MessageStatusDelivered, true},
+		{"Read", chatwoot.MessageStatusRead, true},
+		{" READ ", chatwoot.MessageStatusRead, true},
+		{"Played", chatwoot.MessageStatusRead, true},
+		{"Failed", chatwoot.MessageStatusFailed, true},
+		{"ServerError", chatwoot.MessageStatusFailed, true},
+		{"", chatwoot.MessageStatusSent, false},
+		{"unknown", chatwoot.MessageStatusSent, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.status, func(t *testing.T) {
+			got, known := lookupMessageStatus(tt.status)
+			if got != tt.want || known != tt.known {
+				t.Errorf("lookupMessageStatus(%q) = (%d, %v), esperado (%d, %v)", tt.status, got, known, tt.want, tt.known)
+			}
+		})
+	}
+}
+
+func TestMessageStatus(t *testing.T) {
+	tests := []struct {
+		name string
+		msg  models.UAZAPIMessage
+		want int
+	}{
+		{"enviada e lida", models.UAZAPIMessage{FromMe: true, Status: "Read"}, chatwoot.MessageStatusRead},
+		{"enviada e entregue", models.UAZAPIMessage{FromMe: true, Status: "Delivered"}, chatwoot.MessageStatusDelivered},
+		{"enviada com falha", models.UAZAPIMessage{FromMe: true, Status: "Failed"}, chatwoot.MessageStatusFailed},
+		{"enviada sem status", models.UAZAPIMessage{FromMe: true}, chatwoot.MessageStatusSent},
+		{"enviada com status desconhecido", models.UAZAPIMessage{FromMe: true, Status: "unknown"}, chatwoot.MessageStatusSent},
+		{"recebida lida", models.UAZAPIMessage{Status: "Read"}, chatwoot.MessageStatusSent},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := messageStatus(tt.msg); got != tt.want {
+				t.Errorf("messageStatus() = %d, esperado %d", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMessageStatusUpdates(t *testing.T) {
+	messages := []models.UAZAPIMessage{
+		{MessageID: "A", FromMe: true, Status: "Read"},
+		{MessageID: "B", FromMe: true, Status: "Delivered"},
+		{MessageID: "C", FromMe: true, Status: "Sent"},
+		{MessageID: "D", FromMe: true, Status: "unknown"},
+		{MessageID: "E", Status: "Read"},
+		{MessageID: "F", FromMe: true, Status: "Failed"},
+	}
+	want := map[string]int{
+		"WAID:A": chatwoot.MessageStatusRead,
+		"WAID:B": chatwoot.MessageStatusDelivered,
+		"WAID:F": chatwoot.MessageStatusFailed,
+	}
+
+	if got := messageStatusUpdates(messages); !reflect.DeepEqual(got, want) {
+		t.Errorf("messageStatusUpdates() = %v, esperado %v", got, want)
+	}
+	if got := messageStatusUpdates(nil); len(got) != 0 {
+		t.Errorf("messageStatusUpdates(nil) = %v, esperado vazio", got)
+	}
+}
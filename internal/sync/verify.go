@@ -0,0 +1,376 @@
+package sync
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"text/tabwriter"
+	"time"
+)
+
+// ErrVerifyMismatch indica que a verificação encontrou divergências entre a UAZAPI e o Chatwoot
+var ErrVerifyMismatch = errors.New("verification found discrepancies")
+
+// Formatos aceitos pelo relatório de verificação
+const (
+	VerifyFormatTable = "table"
+	VerifyFormatJSON  = "json"
+	VerifyFormatCSV   = "csv"
+)
+
+// Situação de cada chat no relatório de verificação
+const (
+	verifyStatusOK             = "ok"
+	verifyStatusDivergent      = "divergent"
+	verifyStatusNoConversation = "no_conversation"
+	verifyStatusError          = "error"
+)
+
+// chatVerification compara as mensagens de um chat na UAZAPI com as da conversa mapeada no Chatwoot
+type chatVerification struct {
+	ChatID           string   `json:"chat_id"`
+	Name             string   `json:"name"`
+	ConversationID   int      `json:"conversation_id"`
+	Status           string   `json:"status"`
+	UAZAPIMessages   int      `json:"uazapi_messages"`
+	ChatwootMessages int      `json:"chatwoot_messages"`
+	Missing          []string `json:"missing,omitempty"`    // Na UAZAPI e ausentes no Chatwoot
+	Extra            []string `json:"extra,omitempty"`      // No Chatwoot e ausentes na UAZAPI
+	Duplicated       []string `json:"duplicated,omitempty"` // Importadas mais de uma vez nas conversas do chat
+	Error            string   `json:"error,omitempty"`
+}
+
+// contactVerification é um contato do inbox sem nenhuma conversa
+type contactVerification struct {
+	ContactID   int    `json:"contact_id"`
+	Name        string `json:"name"`
+	PhoneNumber string `json:"phone_number"`
+	Identifier  string `json:"identifier"`
+}
+
+type verifyTotals struct {
+	Chats                       int `json:"chats"`
+	ChatsOK                     int `json:"chats_ok"`
+	ChatsDivergent              int `json:"chats_divergent"`
+	ChatsWithoutConversation    int `json:"chats_without_conversation"`
+	ChatsFailed                 int `json:"chats_failed"`
+	MissingMessages             int `json:"missing_messages"`
+	ExtraMessages               int `json:"extra_messages"`
+	DuplicatedMessages          int `json:"duplicated_messages"`
+	ContactsWithoutConversation int `json:"contacts_without_conversation"`
+}
+
+// verifyReport é o relatório completo da verificação
+type verifyReport struct {
+	GeneratedAt                 time.Time             `json:"generated_at"`
+	AccountID                   int                   `json:"account_id"`
+	InboxID                     int                   `json:"inbox_id"`
+	Totals                      verifyTotals          `json:"totals"`
+	Chats                       []chatVerification    `json:"chats"`
+	ContactsWithoutConversation []contactVerification `json:"contacts_without_conversation"`
+}
+
+// Verify compara, chat a chat, os IDs das mensagens na UAZAPI com os source_ids da conversa
+// mapeada no Chatwoot, sem gravar nada, e escreve o relatório em out no formato indicado.
+// Retorna ErrVerifyMismatch quando há divergências.
+func (s *Service) Verify(format string, out io.Writer) error {
+	s.wg.Add(1)
+	defer s.wg.Done()
+
+	switch format {
+	case VerifyFormatTable, VerifyFormatJSON, VerifyFormatCSV:
+	default:
+		return fmt.Errorf("unknown report format %q", format)
+	}
+
+	ctx := s.ctx
+	if err := s.connect(ctx); err != nil {
+		return err
+	}
+	defer s.chatwoot.Close()
+
+	chats, err := s.fetchChats(ctx)
+	if err != nil {
+		return err
+	}
+
+	var jobs []chatJob
+	for _, chat := range chats {
+		if chat.WAIsGroup && !s.cfg.Sync.IncludeGroups {
+			continue
+		}
+		if !s.filter.allowsChat(chat) {
+			continue
+		}
+		if job, ok := s.newChatJob(chat); ok {
+			jobs = append(jobs, job)
+		}
+	}
+	log.Printf("Verifying %d chats...", len(jobs))
+
+	report := &verifyReport{
+		GeneratedAt: time.Now(),
+		AccountID:   s.cfg.Chatwoot.AccountID,
+		InboxID:     s.inboxID,
+		Chats:       s.verifyChats(ctx, jobs),
+	}
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	contacts, err := s.chatwoot.FindContactsWithoutConversation(ctx, s.inboxID)
+	if err != nil {
+		return err
+	}
+	for _, contact := range contacts {
+		report.ContactsWithoutConversation = append(report.ContactsWithoutConversation, contactVerification{
+			ContactID:   contact.ContactID,
+			Name:        contact.Name,
+			PhoneNumber: contact.PhoneNumber,
+			Identifier:  contact.Identifier,
+		})
+	}
+	report.summarize()
+
+	switch format {
+	case VerifyFormatJSON:
+		err = writeVerifyJSON(out, report)
+	case VerifyFormatCSV:
+		err = writeVerifyCSV(out, report)
+	default:
+		err = writeVerifyTable(out, report)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to write report: %w", err)
+	}
+
+	totals := report.Totals
+	if totals.ChatsOK != totals.Chats || totals.ContactsWithoutConversation > 0 {
+		return ErrVerifyMismatch
+	}
+	return nil
+}
+
+// verifyChats verifica os chats com SYNC_WORKERS workers, mantendo a ordem de entrada
+func (s *Service) verifyChats(ctx context.Context, jobs []chatJob) []chatVerification {
+	results := make([]chatVerification, len(jobs))
+	indexes := make(chan int)
+
+	var workers sync.WaitGroup
+	for i := 0; i < s.cfg.Sync.Workers; i++ {
+		workers.Add(1)
+		go func() {
+			defer workers.Done()
+			for index := range indexes {
+				results[index] = s.verifyChat(ctx, jobs[index])
+			}
+		}()
+	}
+
+	for i := range jobs {
+		if ctx.Err() != nil {
+			break
+		}
+		indexes <- i
+	}
+	close(indexes)
+	workers.Wait()
+
+	return results
+}
+
+// verifyChat compara as mensagens de um chat
+func (s *Service) verifyChat(ctx context.Context, job chatJob) chatVerification {
+	result := chatVerification{ChatID: job.chatID, Name: job.contact.Name}
+	if job.isGroup {
+		result.Name = s.getGroupName(job.chat)
+	}
+
+	fail := func(err error) chatVerification {
+		log.Printf("Error verifying chat %s: %v", job.chatID, err)
+		result.Status = verifyStatusError
+		result.Error = err.Error()
+		return result
+	}
+
+	conversationIDs, err := s.findJobConversations(ctx, job, s.inboxID)
+	if err != nil {
+		return fail(err)
+	}
+	if len(conversationIDs) > 0 {
+		result.ConversationID = conversationIDs[0]
+	}
+
+	// Mensagens sem conteúdo nem mídia não são importadas pela sincronização
+	since := s.filter.messageCutoff(0)
+	expected := make(map[string]bool)
+	for page := range s.uazapi.StreamMessages(ctx, job.chatID, s.cfg.Sync.LimitMessages, since) {
+		if page.Err != nil {
+			return fail(page.Err)
+		}
+		for _, msg := range page.Messages {
+			if s.extractMessageContent(msg) == "" {
+				continue
+			}
+			expected[fmt.Sprintf("WAID:%s", msg.MessageID)] = true
+		}
+	}
+	if err := ctx.Err(); err != nil {
+		return fail(err)
+	}
+
+	// Após mesclas de contatos o chat pode ter mais de uma conversa; todas contam
+	imported, err := s.chatwoot.CountMessageSourceIDs(ctx, conversationIDs, since)
+	if err != nil {
+		return fail(err)
+	}
+	result.compare(expected, imported)
+	return result
+}
+
+// compare preenche as mensagens faltando, extras e duplicadas a partir dos IDs esperados
+// (UAZAPI) e das contagens por source_id (Chatwoot), e define a situação do chat
+func (r *chatVerification) compare(expected map[string]bool, imported map[string]int) {
+	r.UAZAPIMessages = len(expected)
+	for sourceID, count := range imported {
+		r.ChatwootMessages += count
+		if !expected[sourceID] {
+			r.Extra = append(r.Extra, sourceID)
+		}
+		if count > 1 {
+			r.Duplicated = append(r.Duplicated, sourceID)
+		}
+	}
+	for sourceID := range expected {
+		if _, ok := imported[sourceID]; !ok {
+			r.Missing = append(r.Missing, sourceID)
+		}
+	}
+	sort.Strings(r.Missing)
+	sort.Strings(r.Extra)
+	sort.Strings(r.Duplicated)
+
+	switch {
+	case r.ConversationID == 0 && len(expected) > 0:
+		r.Status = verifyStatusNoConversation
+	case len(r.Missing) > 0 || len(r.Extra) > 0 || len(r.Duplicated) > 0:
+		r.Status = verifyStatusDivergent
+	default:
+		r.Status = verifyStatusOK
+	}
+}
+
+// summarize calcula os totais do relatório
+func (r *verifyReport) summarize() {
+	totals := verifyTotals{
+		Chats:                       len(r.Chats),
+		ContactsWithoutConversation: len(r.ContactsWithoutConversation),
+	}
+	for _, chat := range r.Chats {
+		switch chat.Status {
+		case verifyStatusOK:
+			totals.ChatsOK++
+		case verifyStatusDivergent:
+			totals.ChatsDivergent++
+		case verifyStatusNoConversation:
+			totals.ChatsWithoutConversation++
+		case verifyStatusError:
+			totals.ChatsFailed++
+		}
+		totals.MissingMessages += len(chat.Missing)
+		totals.ExtraMessages += len(chat.Extra)
+		totals.DuplicatedMessages += len(chat.Duplicated)
+	}
+	r.Totals = totals
+}
+
+func writeVerifyJSON(out io.Writer, report *verifyReport) error {
+	encoder := json.NewEncoder(out)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(report)
+}
+
+// writeVerifyCSV escreve uma linha por chat e uma por contato sem conversa; os IDs das
+// mensagens divergentes ficam separados por espaço nas últimas colunas
+func writeVerifyCSV(out io.Writer, report *verifyReport) error {
+	w := csv.NewWriter(out)
+	w.Write([]string{
+		"type", "chat_id", "name", "conversation_id", "status",
+		"uazapi_messages", "chatwoot_messages", "missing", "extra", "duplicated",
+		"missing_ids", "extra_ids", "duplicated_ids", "error",
+	})
+	for _, chat := range report.Chats {
+		w.Write([]string{
+			"chat", chat.ChatID, chat.Name, strconv.Itoa(chat.ConversationID), chat.Status,
+			strconv.Itoa(chat.UAZAPIMessages), strconv.Itoa(chat.ChatwootMessages),
+			strconv.Itoa(len(chat.Missing)), strconv.Itoa(len(chat.Extra)), strconv.Itoa(len(chat.Duplicated)),
+			strings.Join(chat.Missing, " "), strings.Join(chat.Extra, " "), strings.Join(chat.Duplicated, " "),
+			chat.Error,
+		})
+	}
+	for _, contact := range report.ContactsWithoutConversation {
+		chatID := contact.Identifier
+		if chatID == "" {
+			chatID = contact.PhoneNumber
+		}
+		w.Write([]string{
+			"contact", chatID, contact.Name, "0", "contact_without_conversation",
+			"", "", "", "", "", "", "", "", "",
+		})
+	}
+	w.Flush()
+	return w.Error()
+}
+
+// writeVerifyTable lista os chats divergentes e os contatos sem conversa, seguidos do resumo
+func writeVerifyTable(out io.Writer, report *verifyReport) error {
+	w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "CHAT\tNOME\tCONVERSA\tSITUAÇÃO\tUAZAPI\tCHATWOOT\tFALTANDO\tEXTRAS\tDUPLICADAS")
+	for _, chat := range report.Chats {
+		if chat.Status == verifyStatusOK {
+			continue
+		}
+		fmt.Fprintf(w, "%s\t%s\t%d\t%s\t%d\t%d\t%d\t%d\t%d\n",
+			chat.ChatID, chat.Name, chat.ConversationID, chat.Status, chat.UAZAPIMessages,
+			chat.ChatwootMessages, len(chat.Missing), len(chat.Extra), len(chat.Duplicated))
+	}
+	if err := w.Flush(); err != nil {
+		return err
+	}
+
+	if len(report.ContactsWithoutConversation) > 0 {
+		fmt.Fprintln(out, "")
+		fmt.Fprintln(w, "CONTATO\tNOME\tTELEFONE\tIDENTIFICADOR")
+		for _, contact := range report.ContactsWithoutConversation {
+			fmt.Fprintf(w, "%d\t%s\t%s\t%s\n", contact.ContactID, contact.Name, contact.PhoneNumber, contact.Identifier)
+		}
+		if err := w.Flush(); err != nil {
+			return err
+		}
+	}
+
+	totals := report.Totals
+	fmt.Fprintln(out, "")
+	fmt.Fprintln(out, "========================================")
+	fmt.Fprintln(out, "       RELATÓRIO DE VERIFICAÇÃO")
+	fmt.Fprintln(out, "========================================")
+	fmt.Fprintf(out, "Chats verificados:                 %d\n", totals.Chats)
+	fmt.Fprintf(out, "Chats completos:                   %d\n", totals.ChatsOK)
+	fmt.Fprintf(out, "Chats com divergências:            %d\n", totals.ChatsDivergent)
+	fmt.Fprintf(out, "Chats sem conversa:                %d\n", totals.ChatsWithoutConversation)
+	fmt.Fprintf(out, "Chats com falha:                   %d\n", totals.ChatsFailed)
+	fmt.Fprintf(out, "Mensagens faltando:                %d\n", totals.MissingMessages)
+	fmt.Fprintf(out, "Mensagens extras:                  %d\n", totals.ExtraMessages)
+	fmt.Fprintf(out, "Mensagens duplicadas:              %d\n", totals.DuplicatedMessages)
+	fmt.Fprintf(out, "Contatos sem conversa:             %d\n", totals.ContactsWithoutConversation)
+	fmt.Fprintln(out, "========================================")
+	return nil
+}
@@ -0,0 +1,89 @@
+package sync
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestChatVerificationCompare(t *testing.T) {
+	tests := []struct {
+		name           string
+		conversationID int
+		expected       []string
+		imported       map[string]int
+		wantStatus     string
+		wantMissing    []string
+		wantExtra      []string
+		wantDuplicated []string
+		wantChatwoot   int
+	}{
+		{
+			name:           "completo",
+			conversationID: 10,
+			expected:       []string{"WAID:A", "WAID:B"},
+			imported:       map[string]int{"WAID:A": 1, "WAID:B": 1},
+			wantStatus:     verifyStatusOK,
+			wantChatwoot:   2,
+		},
+		{
+			name:           "faltando e extras",
+			conversationID: 10,
+			expected:       []string{"WAID:C", "WAID:A", "WAID:B"},
+			imported:       map[string]int{"WAID:B": 1, "WAID:X": 1},
+			wantStatus:     verifyStatusDivergent,
+			wantMissing:    []string{"WAID:A", "WAID:C"},
+			wantExtra:      []string{"WAID:X"},
+			wantChatwoot:   2,
+		},
+		{
+			name:           "duplicadas",
+			conversationID: 10,
+			expected:       []string{"WAID:A"},
+			imported:       map[string]int{"WAID:A": 3},
+			wantStatus:     verifyStatusDivergent,
+			wantDuplicated: []string{"WAID:A"},
+			wantChatwoot:   3,
+		},
+		{
+			name:        "sem conversa",
+			expected:    []string{"WAID:A"},
+			imported:    map[string]int{},
+			wantStatus:  verifyStatusNoConversation,
+			wantMissing: []string{"WAID:A"},
+		},
+		{
+			name:       "sem conversa e sem mensagens",
+			imported:   map[string]int{},
+			wantStatus: verifyStatusOK,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			expected := make(map[string]bool)
+			for _, sourceID := range tt.expected {
+				expected[sourceID] = true
+			}
+
+			result := chatVerification{ConversationID: tt.conversationID}
+			result.compare(expected, tt.imported)
+
+			if result.Status != tt.wantStatus {
+				t.Errorf("Status = %q, want %q", result.Status, tt.wantStatus)
+			}
+			if result.UAZAPIMessages != len(tt.expected) || result.ChatwootMessages != tt.wantChatwoot {
+				t.Errorf("counts = (%d, %d), want (%d, %d)",
+					result.UAZAPIMessages, result.ChatwootMessages, len(tt.expected), tt.wantChatwoot)
+			}
+			if !reflect.DeepEqual(result.Missing, tt.wantMissing) {
+				t.Errorf("Missing = %v, want %v", result.Missing, tt.wantMissing)
+			}
+			if !reflect.DeepEqual(result.Extra, tt.wantExtra) {
+				t.Errorf("Extra = %v, want %v", result.Extra, tt.wantExtra)
+			}
+			if !reflect.DeepEqual(result.Duplicated, tt.wantDuplicated) {
+				t.Errorf("Duplicated = %v, want %v", result.Duplicated, tt.wantDuplicated)
+			}
+		})
+	}
+}
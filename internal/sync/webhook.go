@@ -0,0 +1,205 @@
+package sync
+
+import (
+	"chatwoot-sync-go/internal/chatwoot"
+	"chatwoot-sync-go/internal/models"
+	"context"
+	"fmt"
+	"log"
+	"strings"
+)
+
+// HandleUAZAPIEvent processa um evento recebido pelo webhook da UAZAPI, usando o mesmo
+// caminho de resolução de contato/conversa e inserção da sincronização em lote
+func (s *Service) HandleUAZAPIEvent(ctx context.Context, event *models.UAZAPIWebhookEvent) error {
+	if ctx.Err() != nil {
+		return fmt.Errorf("service is stopping")
+	}
+
+	switch strings.ToLower(event.EventType) {
+	case "messages":
+		if event.Message == nil {
+			return nil
+		}
+		return s.handleWebhookMessage(ctx, event.Chat, *event.Message)
+	case "messages_update":
+		// Atualizações que trazem a mensagem completa (ex.: mensagens editadas) são importadas se ainda não existirem
+		if event.Message != nil {
+			return s.handleWebhookMessage(ctx, event.Chat, *event.Message)
+		}
+		// Confirmações de entrega e leitura atualizam o status das mensagens já importadas
+		if event.Event != nil {
+			return s.handleWebhookReceipt(ctx, *event.Event)
+		}
+		return nil
+	case "chats":
+		if event.Chat == nil {
+			return nil
+		}
+		return s.handleWebhookChat(ctx, *event.Chat)
+	default:
+		log.Printf("Webhook: ignoring event type '%s'", event.EventType)
+		return nil
+	}
+}
+
+// handleWebhookMessage importa uma mensagem recebida em tempo real
+func (s *Service) handleWebhookMessage(ctx context.Context, chat *models.UAZAPIChat, msg models.UAZAPIMessage) error {
+	chatID := msg.ChatID
+	if chatID == "" && chat != nil {
+		chatID = chat.WAChatID
+	}
+	if chatID == "" || msg.MessageID == "" {
+		return nil
+	}
+	if isGroupChatID(chatID) && !s.cfg.Sync.IncludeGroups {
+		return nil
+	}
+	phone := ""
+	if chat != nil {
+		phone = chat.Phone
+	}
+	if !s.filter.allowsChatID(chatID, phone) {
+		return nil
+	}
+
+	unlock := s.lockChat(chatID)
+	defer unlock()
+
+	var media *apiMedia
+	conversationID := 0
+	err := s.withChatTx(ctx, func(db *chatwoot.Database) error {
+		fks, err := s.resolveWebhookConversation(ctx, db, chatID, chat, msg)
+		if err != nil {
+			return err
+		}
+		if fks == nil {
+			log.Printf("Webhook: could not resolve contact for chat %s, skipping message %s", chatID, msg.MessageID)
+			return nil
+		}
+
+		conversationID = fks.ConversationID
+		log.Printf("Webhook: importing message %s for chat %s (conversation %d)", msg.MessageID, chatID, fks.ConversationID)
+		media, err = s.importMessages(ctx, db, chatID, []models.UAZAPIMessage{msg}, fks, s.inboxID, s.chatwootUser)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+
+	if media != nil {
+		if err := s.syncMediaMessages(ctx, media, s.inboxID, s.chatwootUser); err != nil {
+			return err
+		}
+	}
+	s.linkReplies(ctx, conversationID)
+	return nil
+}
+
+// resolveWebhookConversation busca ou cria o contato e a conversa do chat da mensagem
+func (s *Service) resolveWebhookConversation(
+	ctx context.Context,
+	db *chatwoot.Database,
+	chatID string,
+	chat *models.UAZAPIChat,
+	msg models.UAZAPIMessage,
+) (*models.ChatwootFKs, error) {
+	if isGroupChatID(chatID) {
+		group := models.ChatwootContact{
+			Name:           chatID,
+			Identifier:     chatID,
+			FirstTimestamp: msg.MessageTimestamp,
+			LastTimestamp:  msg.MessageTimestamp,
+		}
+		if chat != nil {
+			group.Name = s.getGroupName(*chat)
+		}
+		fks, err := db.CreateGroupConversation(ctx, group, s.inboxID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create group conversation: %w", err)
+		}
+		return fks, nil
+	}
+
+	// Sem o chat, o remetente de uma mensagem recebida informa o telefone e o LID do contato
+	lid, chatPhone := "", ""
+	if chat != nil {
+		lid, chatPhone = chat.WAChatLID, chat.Phone
+	} else if !msg.FromMe {
+		lid, chatPhone = msg.SenderLID, msg.SenderPN
+	}
+	contact, ok := s.individualContact(chatID, lid, chatPhone)
+	if !ok {
+		return nil, nil
+	}
+	contact.FirstTimestamp = msg.MessageTimestamp
+	contact.LastTimestamp = msg.MessageTimestamp
+	if chat != nil {
+		contact.Name = s.getContactName(*chat)
+	} else if !msg.FromMe {
+		contact.Name = msg.SenderName
+	}
+
+	fks, err := s.ensureContactConversation(ctx, db, contact, s.inboxID)
+	if err != nil {
+		return nil, err
+	}
+	if fks == nil || fks.ContactID == 0 || fks.ConversationID == 0 {
+		return nil, nil
+	}
+	return fks, nil
+}
+
+// handleWebhookChat atualiza o nome e a foto do contato quando o chat muda na UAZAPI
+func (s *Service) handleWebhookChat(ctx context.Context, chat models.UAZAPIChat) error {
+	if !s.filter.allowsChatID(chat.WAChatID, chat.Phone) {
+		return nil
+	}
+
+	// Vínculo do LID e nomes mudam o mesmo contato que a sincronização do chat
+	unlock := s.lockChat(chat.WAChatID)
+	defer unlock()
+
+	if chat.WAIsGroup {
+		if !s.cfg.Sync.IncludeGroups {
+			return nil
+		}
+		existing, err := s.chatwoot.FindGroupConversation(ctx, chat.WAChatID, s.inboxID)
+		if err != nil || existing == nil {
+			return err
+		}
+		if err := s.chatwoot.UpdateGroupName(ctx, existing.ContactID, s.getGroupName(chat)); err != nil {
+			return err
+		}
+		s.syncContactAvatar(ctx, existing.ContactID, chat)
+		return nil
+	}
+
+	contact, ok := s.individualContact(chat.WAChatID, chat.WAChatLID, chat.Phone)
+	if !ok {
+		return nil
+	}
+	contact.Name = s.getContactName(chat)
+
+	if contact.PhoneNumber != "" {
+		var err error
+		contact, err = s.matchContactPhone(ctx, s.chatwoot, contact)
+		if err != nil {
+			return err
+		}
+		// O chat pode trazer o LID de um contato que até então só era conhecido por ele
+		if err := s.chatwoot.LinkContactLID(ctx, contact); err != nil {
+			return err
+		}
+		if err := s.chatwoot.UpdateContactNames(ctx, []models.ChatwootContact{contact}); err != nil {
+			return err
+		}
+	}
+
+	contactIDs, err := s.chatwoot.FindContacts(ctx, []models.ChatwootContact{contact})
+	if err != nil {
+		return err
+	}
+	s.syncContactAvatar(ctx, contactIDs[contact.Identifier], chat)
+	return nil
+}
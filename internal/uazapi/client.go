@@ -1,21 +1,25 @@
 package uazapi
 
 import (
-	"bytes"
 	"chatwoot-sync-go/internal/config"
 	"chatwoot-sync-go/internal/models"
-	"encoding/json"
+	"context"
 	"fmt"
-	"io"
 	"log"
 	"net/http"
 	"time"
 )
 
 type Client struct {
-	baseURL string
-	token   string
-	client  *http.Client
+	baseURL    string
+	token      string
+	client     *http.Client
+	sem        chan struct{} // Limita as requisições simultâneas entre os workers
+	maxRetries int
+
+	// Orçamentos de requisições por segundo: listagem/envio e download de mídia
+	limiter      *rateLimiter
+	mediaLimiter *rateLimiter
 }
 
 func NewClient(cfg *config.Config) *Client {
@@ -25,93 +29,78 @@ func NewClient(cfg *config.Config) *Client {
 		client: &http.Client{
 			Timeout: 60 * time.Second,
 		},
+		sem:        make(chan struct{}, cfg.UAZAPI.MaxConcurrency),
+		maxRetries: cfg.UAZAPI.MaxRetries,
+
+		limiter:      newRateLimiter(cfg.UAZAPI.RateLimit, cfg.UAZAPI.RateBurst),
+		mediaLimiter: newRateLimiter(cfg.UAZAPI.MediaRateLimit, cfg.UAZAPI.MediaRateBurst),
 	}
 }
 
-// FindChats busca chats da API UAZAPI
-func (c *Client) FindChats(limit, offset int, isGroup bool) (*models.UAZAPIChatsResponse, error) {
-	url := fmt.Sprintf("%s/chat/find", c.baseURL)
+// ChatFilter restringe a listagem de chats. Since (unix, em segundos) não é aceito pela
+// UAZAPI; serve apenas para encerrar a paginação, que é ordenada do chat mais recente ao mais antigo.
+type ChatFilter struct {
+	IsGroup         bool
+	ExcludeArchived bool
+	Since           int64
+}
 
+// FindChats busca chats da API UAZAPI
+func (c *Client) FindChats(ctx context.Context, limit, offset int, filter ChatFilter) (*models.UAZAPIChatsResponse, error) {
 	payload := map[string]interface{}{
 		"operator":     "LIKE",
 		"sort":         "-wa_lastMsgTimestamp",
 		"limit":        limit,
 		"offset":       offset,
-		"wa_isGroup":   isGroup,
-	}
-
-	jsonData, err := json.Marshal(payload)
-	if err != nil {
-		return nil, fmt.Errorf("failed to marshal request: %w", err)
-	}
-
-	req, err := http.NewRequest("POST", url, bytes.NewBuffer(jsonData))
-	if err != nil {
-		return nil, fmt.Errorf("failed to create request: %w", err)
+		"wa_isGroup":   filter.IsGroup,
 	}
-
-	req.Header.Set("Accept", "application/json")
-	req.Header.Set("Content-Type", "application/json")
-	req.Header.Set("token", c.token)
-
-	resp, err := c.client.Do(req)
-	if err != nil {
-		return nil, fmt.Errorf("failed to execute request: %w", err)
-	}
-	defer resp.Body.Close()
-
-	if resp.StatusCode != http.StatusOK {
-		body, _ := io.ReadAll(resp.Body)
-		return nil, fmt.Errorf("API returned status %d: %s", resp.StatusCode, string(body))
+	if filter.ExcludeArchived {
+		payload["wa_archived"] = false
 	}
 
 	var result models.UAZAPIChatsResponse
-	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
-		return nil, fmt.Errorf("failed to decode response: %w", err)
+	if err := c.post(ctx, "/chat/find", payload, &result, true); err != nil {
+		return nil, err
 	}
 
 	log.Printf("Found %d chats (offset: %d)", len(result.Chats), offset)
 	return &result, nil
 }
 
-// FindMessages busca mensagens de um chat específico
-func (c *Client) FindMessages(chatID string, limit, offset int) (*models.UAZAPIMessagesResponse, error) {
-	url := fmt.Sprintf("%s/message/find", c.baseURL)
-
+// FindChat busca um único chat pelo JID (wa_chatid), retornando nil se ele não existir
+func (c *Client) FindChat(ctx context.Context, chatID string) (*models.UAZAPIChat, error) {
 	payload := map[string]interface{}{
-		"chatid": chatID,
-		"limit":  limit,
-		"offset": offset,
-	}
-
-	jsonData, err := json.Marshal(payload)
-	if err != nil {
-		return nil, fmt.Errorf("failed to marshal request: %w", err)
+		"operator":  "LIKE",
+		"wa_chatid": chatID,
+		"limit":     1,
 	}
 
-	req, err := http.NewRequest("POST", url, bytes.NewBuffer(jsonData))
-	if err != nil {
-		return nil, fmt.Errorf("failed to create request: %w", err)
+	var result models.UAZAPIChatsResponse
+	if err := c.post(ctx, "/chat/find", payload, &result, true); err != nil {
+		return nil, err
 	}
 
-	req.Header.Set("Accept", "application/json")
-	req.Header.Set("Content-Type", "application/json")
-	req.Header.Set("token", c.token)
-
-	resp, err := c.client.Do(req)
-	if err != nil {
-		return nil, fmt.Errorf("failed to execute request: %w", err)
+	for i := range result.Chats {
+		if result.Chats[i].WAChatID == chatID {
+			return &result.Chats[i], nil
+		}
 	}
-	defer resp.Body.Close()
+	return nil, nil
+}
 
-	if resp.StatusCode != http.StatusOK {
-		body, _ := io.ReadAll(resp.Body)
-		return nil, fmt.Errorf("API returned status %d: %s", resp.StatusCode, string(body))
+// FindMessages busca mensagens de um chat específico, da mais recente para a mais antiga. A ordem é
+// pedida explicitamente, pois StreamMessages encerra a paginação com base nela.
+func (c *Client) FindMessages(ctx context.Context, chatID string, limit, offset int) (*models.UAZAPIMessagesResponse, error) {
+	payload := map[string]interface{}{
+		"chatid": chatID,
+		"sort":   "-messageTimestamp",
+		"limit":  limit,
+		"offset": offset,
 	}
 
 	var result models.UAZAPIMessagesResponse
-	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
-		return nil, fmt.Errorf("failed to decode response: %w", err)
+	if err := c.post(ctx, "/message/find", payload, &result, true); err != nil {
+		return nil, err
 	}
 
 	log.Printf("Found %d messages for chat %s (offset: %d)", len(result.Messages), chatID, offset)
@@ -119,12 +108,12 @@ func (c *Client) FindMessages(chatID string, limit, offset int) (*models.UAZAPIM
 }
 
 // GetAllChats busca todos os chats (com paginação)
-func (c *Client) GetAllChats(limit int, isGroup bool) ([]models.UAZAPIChat, error) {
+func (c *Client) GetAllChats(ctx context.Context, limit int, filter ChatFilter) ([]models.UAZAPIChat, error) {
 	var allChats []models.UAZAPIChat
 	offset := 0
 
 	for {
-		response, err := c.FindChats(limit, offset, isGroup)
+		response, err := c.FindChats(ctx, limit, offset, filter)
 		if err != nil {
 			return nil, err
 		}
@@ -135,6 +124,14 @@ func (c *Client) GetAllChats(limit int, isGroup bool) ([]models.UAZAPIChat, erro
 			break
 		}
 
+		// Os chats seguintes são todos anteriores à janela pedida
+		if filter.Since > 0 && len(response.Chats) > 0 {
+			last := response.Chats[len(response.Chats)-1].WALastMsgTimestamp
+			if models.UnixSeconds(last) < filter.Since {
+				break
+			}
+		}
+
 		offset += len(response.Chats)
 		log.Printf("Fetched %d chats so far...", len(allChats))
 	}
@@ -142,70 +139,142 @@ func (c *Client) GetAllChats(limit int, isGroup bool) ([]models.UAZAPIChat, erro
 	return allChats, nil
 }
 
-// GetAllMessages busca todas as mensagens de um chat (com paginação)
-func (c *Client) GetAllMessages(chatID string, limit int) ([]models.UAZAPIMessage, error) {
-	var allMessages []models.UAZAPIMessage
-	offset := 0
+// MessagePage é uma página de mensagens entregue por StreamMessages. Err é preenchido
+// (e o canal fechado em seguida) quando a busca de uma página falha.
+type MessagePage struct {
+	Messages []models.UAZAPIMessage
+	Err      error
+}
 
-	for {
-		response, err := c.FindMessages(chatID, limit, offset)
-		if err != nil {
-			return nil, err
+// StreamMessages busca as mensagens de um chat página a página, das mais recentes para as
+// mais antigas, e envia cada página pelo canal retornado, que é fechado ao fim da paginação.
+// Com since > 0 (em segundos ou milissegundos), apenas mensagens a partir de since são enviadas e
+// a paginação para assim que uma página inteira for anterior a ele; dentro da página, todas as
+// mensagens são conferidas, em qualquer ordem. Cancelar ctx interrompe a busca.
+func (c *Client) StreamMessages(ctx context.Context, chatID string, limit int, since int64) <-chan MessagePage {
+	pages := make(chan MessagePage)
+
+	go func() {
+		defer close(pages)
+
+		offset := 0
+		fetched := 0
+		for {
+			response, err := c.FindMessages(ctx, chatID, limit, offset)
+			if err != nil {
+				select {
+				case pages <- MessagePage{Err: err}:
+				case <-ctx.Done():
+				}
+				return
+			}
+
+			messages := response.Messages
+			olderCount := 0
+			if since > 0 {
+				messages = make([]models.UAZAPIMessage, 0, len(response.Messages))
+				for _, msg := range response.Messages {
+					if models.UnixSeconds(msg.MessageTimestamp) >= models.UnixSeconds(since) {
+						messages = append(messages, msg)
+					} else {
+						olderCount++
+					}
+				}
+			}
+
+			if len(messages) > 0 {
+				select {
+				case pages <- MessagePage{Messages: messages}:
+				case <-ctx.Done():
+					return
+				}
+			}
+			fetched += len(messages)
+
+			if !response.HasMore || (len(response.Messages) > 0 && olderCount == len(response.Messages)) {
+				return
+			}
+
+			offset = response.NextOffset
+			log.Printf("Fetched %d messages for chat %s so far...", fetched, chatID)
 		}
+	}()
 
-		allMessages = append(allMessages, response.Messages...)
+	return pages
+}
 
-		if !response.HasMore {
-			break
-		}
+// GetAllMessages busca todas as mensagens de um chat (com paginação)
+func (c *Client) GetAllMessages(ctx context.Context, chatID string, limit int) ([]models.UAZAPIMessage, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
 
-		offset = response.NextOffset
-		log.Printf("Fetched %d messages for chat %s so far...", len(allMessages), chatID)
+	var allMessages []models.UAZAPIMessage
+	for page := range c.StreamMessages(ctx, chatID, limit, 0) {
+		if page.Err != nil {
+			return nil, page.Err
+		}
+		allMessages = append(allMessages, page.Messages...)
+	}
+	// O canal também é fechado quando ctx é cancelado, sem enviar o erro
+	if err := ctx.Err(); err != nil {
+		return nil, err
 	}
 
 	return allMessages, nil
 }
 
 // DownloadMedia baixa uma mídia usando o messageid
-func (c *Client) DownloadMedia(messageID string) (*models.UAZAPIMediaResponse, error) {
-	url := fmt.Sprintf("%s/message/download", c.baseURL)
-
+func (c *Client) DownloadMedia(ctx context.Context, messageID string) (*models.UAZAPIMediaResponse, error) {
 	payload := map[string]interface{}{
 		"id":             messageID,
 		"return_base64":  true,
 		"return_link":    true,
 	}
 
-	jsonData, err := json.Marshal(payload)
-	if err != nil {
-		return nil, fmt.Errorf("failed to marshal request: %w", err)
+	var result models.UAZAPIMediaResponse
+	if err := c.post(ctx, "/message/download", payload, &result, true); err != nil {
+		return nil, err
 	}
 
-	req, err := http.NewRequest("POST", url, bytes.NewBuffer(jsonData))
-	if err != nil {
-		return nil, fmt.Errorf("failed to create request: %w", err)
-	}
+	return &result, nil
+}
 
-	req.Header.Set("Accept", "application/json")
-	req.Header.Set("Content-Type", "application/json")
-	req.Header.Set("token", c.token)
 
-	resp, err := c.client.Do(req)
-	if err != nil {
-		return nil, fmt.Errorf("failed to execute request: %w", err)
+// SendText envia uma mensagem de texto para um número ou grupo
+func (c *Client) SendText(ctx context.Context, number, text string) (*models.UAZAPISendResponse, error) {
+	payload := map[string]interface{}{
+		"number": number,
+		"text":   text,
 	}
-	defer resp.Body.Close()
+	return c.send(ctx, "/send/text", payload)
+}
 
-	if resp.StatusCode != http.StatusOK {
-		body, _ := io.ReadAll(resp.Body)
-		return nil, fmt.Errorf("API returned status %d: %s", resp.StatusCode, string(body))
+// SendMedia envia uma mídia (image, video, audio, document) a partir de uma URL
+func (c *Client) SendMedia(ctx context.Context, number, mediaType, fileURL, caption, fileName string) (*models.UAZAPISendResponse, error) {
+	payload := map[string]interface{}{
+		"number": number,
+		"type":   mediaType,
+		"file":   fileURL,
+	}
+	if caption != "" {
+		payload["text"] = caption
 	}
+	if fileName != "" {
+		payload["docName"] = fileName
+	}
+	return c.send(ctx, "/send/media", payload)
+}
 
-	var result models.UAZAPIMediaResponse
-	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
-		return nil, fmt.Errorf("failed to decode response: %w", err)
+// send executa uma requisição para um endpoint de envio da UAZAPI
+func (c *Client) send(ctx context.Context, path string, payload map[string]interface{}) (*models.UAZAPISendResponse, error) {
+	// Envios não são idempotentes: só são repetidos quando a UAZAPI recusa a requisição por limite (429)
+	var result models.UAZAPISendResponse
+	if err := c.post(ctx, path, payload, &result, false); err != nil {
+		return nil, err
+	}
+	if result.MessageID == "" {
+		return nil, fmt.Errorf("API response has no messageid")
 	}
 
 	return &result, nil
 }
-
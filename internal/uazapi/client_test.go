@@ -0,0 +1,68 @@
+package uazapi
+
+import (
+	"chatwoot-sync-go/internal/config"
+	"chatwoot-sync-go/internal/models"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+)
+
+func TestStreamMessagesSince(t *testing.T) {
+	// Páginas por offset; a primeira traz uma mensagem antiga antes de uma nova
+	pages := map[int]models.UAZAPIMessagesResponse{
+		0: {Messages: []models.UAZAPIMessage{
+			{MessageID: "antiga", MessageTimestamp: 100},
+			{MessageID: "nova-2", MessageTimestamp: 400},
+			{MessageID: "nova", MessageTimestamp: 300},
+		}, HasMore: true, NextOffset: 3},
+		3: {Messages: []models.UAZAPIMessage{
+			{MessageID: "anterior-1", MessageTimestamp: 50},
+			{MessageID: "anterior-2", MessageTimestamp: 40},
+		}, HasMore: true, NextOffset: 5},
+		5: {Messages: []models.UAZAPIMessage{
+			{MessageID: "não deve ser buscada", MessageTimestamp: 900},
+		}},
+	}
+
+	var offsets []int
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var payload struct {
+			ChatID string `json:"chatid"`
+			Sort   string `json:"sort"`
+			Offset int    `json:"offset"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
+			t.Errorf("payload inválido: %v", err)
+		}
+		if payload.Sort != "-messageTimestamp" {
+			t.Errorf("sort = %q, esperado -messageTimestamp", payload.Sort)
+		}
+		offsets = append(offsets, payload.Offset)
+		json.NewEncoder(w).Encode(pages[payload.Offset])
+	}))
+	defer server.Close()
+
+	client := NewClient(&config.Config{UAZAPI: config.UAZAPIConfig{BaseURL: server.URL, MaxConcurrency: 1}})
+
+	var got []string
+	for page := range client.StreamMessages(context.Background(), "5511987654321@s.whatsapp.net", 3, 200) {
+		if page.Err != nil {
+			t.Fatalf("StreamMessages: %v", page.Err)
+		}
+		for _, msg := range page.Messages {
+			got = append(got, msg.MessageID)
+		}
+	}
+
+	// A página fora de ordem não encerra a paginação; a primeira página toda anterior a since, sim
+	if want := []string{"nova-2", "nova"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("mensagens = %v, esperado %v", got, want)
+	}
+	if want := []int{0, 3}; !reflect.DeepEqual(offsets, want) {
+		t.Errorf("offsets buscados = %v, esperado %v", offsets, want)
+	}
+}
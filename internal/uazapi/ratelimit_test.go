@@ -0,0 +1,73 @@
+package uazapi
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestRateLimiterIdleRefill(t *testing.T) {
+	if l := newRateLimiter(0, 5); l != nil || l.Wait(context.Background()) != nil {
+		t.Fatalf("newRateLimiter(0, 5) = %v, esperado limitador nil que não bloqueia", l)
+	}
+
+	// Uma hora parado não acumula mais que o burst
+	l := newRateLimiter(20, 2)
+	l.last = time.Now().Add(-time.Hour)
+	start := time.Now()
+	for i := 0; i < 3; i++ {
+		if err := l.Wait(context.Background()); err != nil {
+			t.Fatalf("Wait() = %v", err)
+		}
+	}
+	if elapsed := time.Since(start); elapsed < 40*time.Millisecond {
+		t.Errorf("3 chamadas com burst 2 levaram %s, esperado ao menos 50ms pela terceira", elapsed)
+	}
+}
+
+func TestRateLimiterConcurrentWaiters(t *testing.T) {
+	// Chamadas simultâneas com o balde vazio saem espaçadas pela taxa, não todas juntas
+	l := newRateLimiter(50, 1)
+	if err := l.Wait(context.Background()); err != nil {
+		t.Fatalf("Wait() = %v", err)
+	}
+
+	start := time.Now()
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := l.Wait(context.Background()); err != nil {
+				t.Errorf("Wait() = %v", err)
+			}
+		}()
+	}
+	wg.Wait()
+	if elapsed := time.Since(start); elapsed < 70*time.Millisecond {
+		t.Errorf("4 chamadas simultâneas a 50/s levaram %s, esperado ao menos 80ms", elapsed)
+	}
+}
+
+func TestRateLimiterWaitCanceled(t *testing.T) {
+	l := newRateLimiter(1, 1)
+	if err := l.Wait(context.Background()); err != nil {
+		t.Fatalf("Wait() = %v", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if err := l.Wait(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("Wait() = %v, esperado %v", err, context.DeadlineExceeded)
+	}
+
+	// O token reservado pela chamada cancelada volta ao balde
+	l.mutex.Lock()
+	tokens := l.tokens
+	l.mutex.Unlock()
+	if tokens < -0.1 || tokens > 0.1 {
+		t.Errorf("tokens após o cancelamento = %.2f, esperado cerca de 0", tokens)
+	}
+}
@@ -0,0 +1,250 @@
+package uazapi
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"math/rand"
+	"net"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+const (
+	retryBaseDelay = 500 * time.Millisecond
+	retryMaxDelay  = 30 * time.Second
+	maxErrorBody   = 1024 // Bytes do corpo da resposta mantidos em APIError
+)
+
+// ErrUnauthorized indica que a UAZAPI recusou o token (401/403). Não adianta repetir a requisição.
+var ErrUnauthorized = errors.New("uazapi: unauthorized")
+
+// APIError é retornado quando a UAZAPI responde com status diferente de 200
+type APIError struct {
+	Path       string
+	StatusCode int
+	Body       string
+	RetryAfter time.Duration // Valor do header Retry-After, se presente
+}
+
+func (e *APIError) Error() string {
+	return fmt.Sprintf("API %s returned status %d: %s", e.Path, e.StatusCode, e.Body)
+}
+
+// Is permite usar errors.Is(err, ErrUnauthorized)
+func (e *APIError) Is(target error) bool {
+	return target == ErrUnauthorized && (e.StatusCode == http.StatusUnauthorized || e.StatusCode == http.StatusForbidden)
+}
+
+// Transient indica se o status é de uma falha temporária (limite de requisições ou erro do servidor)
+func (e *APIError) Transient() bool {
+	return e.StatusCode == http.StatusTooManyRequests || e.StatusCode >= 500
+}
+
+// TransportError é retornado quando a requisição não obteve resposta (conexão, timeout)
+type TransportError struct {
+	Path string
+	Err  error
+}
+
+func (e *TransportError) Error() string {
+	return fmt.Sprintf("failed to execute request %s: %v", e.Path, e.Err)
+}
+
+func (e *TransportError) Unwrap() error {
+	return e.Err
+}
+
+// IsTransient indica se o erro é temporário, ou seja, se a mesma operação pode dar certo
+// em uma próxima tentativa (429, 5xx, timeouts e falhas de conexão)
+func IsTransient(err error) bool {
+	var apiErr *APIError
+	if errors.As(err, &apiErr) {
+		return apiErr.Transient()
+	}
+	var transportErr *TransportError
+	return errors.As(err, &transportErr)
+}
+
+// post envia payload como JSON para o endpoint e decodifica a resposta em result.
+// Falhas temporárias são repetidas até maxRetries vezes com backoff exponencial e jitter,
+// respeitando o Retry-After até retryMaxDelay. Com idempotent=false, apenas respostas 429 são repetidas.
+func (c *Client) post(ctx context.Context, path string, payload interface{}, result interface{}, idempotent bool) error {
+	jsonData, err := json.Marshal(payload)
+	if err != nil {
+		return fmt.Errorf("failed to marshal request: %w", err)
+	}
+
+	for attempt := 0; ; attempt++ {
+		err := c.postOnce(ctx, path, jsonData, result)
+		if err == nil {
+			return nil
+		}
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		if attempt >= c.maxRetries || !shouldRetry(err, idempotent) {
+			return err
+		}
+
+		delay, ok := retryDelay(err, attempt)
+		if !ok {
+			log.Printf("UAZAPI %s asked to retry after more than %s, giving up", path, retryMaxDelay)
+			return err
+		}
+		log.Printf("UAZAPI %s failed (attempt %d/%d), retrying in %s: %v",
+			path, attempt+1, c.maxRetries+1, delay.Round(time.Millisecond), err)
+
+		timer := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		case <-timer.C:
+		}
+	}
+}
+
+// rateLimiterFor retorna o orçamento de requisições do endpoint
+func (c *Client) rateLimiterFor(path string) *rateLimiter {
+	if path == "/message/download" {
+		return c.mediaLimiter
+	}
+	return c.limiter
+}
+
+// postOnce executa uma única tentativa da requisição
+func (c *Client) postOnce(ctx context.Context, path string, jsonData []byte, result interface{}) error {
+	// Aguardar o limite de taxa antes de ocupar uma vaga de concorrência
+	if err := c.rateLimiterFor(path).Wait(ctx); err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "POST", c.baseURL+path, bytes.NewReader(jsonData))
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+
+	req.Header.Set("Accept", "application/json")
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("token", c.token)
+
+	resp, err := c.do(req)
+	if err != nil {
+		return &TransportError{Path: path, Err: err}
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, maxErrorBody))
+		return &APIError{
+			Path:       path,
+			StatusCode: resp.StatusCode,
+			Body:       string(body),
+			RetryAfter: parseRetryAfter(resp.Header.Get("Retry-After")),
+		}
+	}
+
+	if err := json.NewDecoder(resp.Body).Decode(result); err != nil {
+		// Corpo cortado no meio ou timeout na leitura também são falhas de transporte
+		var netErr net.Error
+		if errors.Is(err, io.ErrUnexpectedEOF) || errors.As(err, &netErr) {
+			return &TransportError{Path: path, Err: err}
+		}
+		return fmt.Errorf("failed to decode response: %w", err)
+	}
+	return nil
+}
+
+// shouldRetry decide se uma falha deve ser repetida
+func shouldRetry(err error, idempotent bool) bool {
+	if idempotent {
+		return IsTransient(err)
+	}
+	var apiErr *APIError
+	return errors.As(err, &apiErr) && apiErr.StatusCode == http.StatusTooManyRequests
+}
+
+// retryDelay retorna a espera antes da próxima tentativa: o Retry-After da resposta, se presente,
+// ou o backoff. Um Retry-After acima de retryMaxDelay não é esperado: retorna false e a falha,
+// que continua temporária, é devolvida ao chamador.
+func retryDelay(err error, attempt int) (time.Duration, bool) {
+	var apiErr *APIError
+	if errors.As(err, &apiErr) && apiErr.RetryAfter > 0 {
+		if apiErr.RetryAfter > retryMaxDelay {
+			return 0, false
+		}
+		return apiErr.RetryAfter, true
+	}
+	return backoffDelay(attempt), true
+}
+
+// backoffDelay calcula a espera antes da próxima tentativa: exponencial com jitter
+// entre metade e o valor cheio, limitada a retryMaxDelay
+func backoffDelay(attempt int) time.Duration {
+	delay := retryMaxDelay
+	if attempt < 16 {
+		if d := retryBaseDelay << uint(attempt); d < retryMaxDelay {
+			delay = d
+		}
+	}
+	half := delay / 2
+	return half + time.Duration(rand.Int63n(int64(half)+1))
+}
+
+// parseRetryAfter interpreta o header Retry-After em segundos ou como data HTTP
+func parseRetryAfter(value string) time.Duration {
+	if value == "" {
+		return 0
+	}
+	if seconds, err := strconv.Atoi(value); err == nil {
+		if seconds < 0 {
+			return 0
+		}
+		return time.Duration(seconds) * time.Second
+	}
+	if at, err := http.ParseTime(value); err == nil {
+		if wait := time.Until(at); wait > 0 {
+			return wait
+		}
+	}
+	return 0
+}
+
+// do executa a requisição ocupando uma vaga do limite de concorrência.
+// A vaga só é liberada quando o corpo da resposta é fechado.
+func (c *Client) do(req *http.Request) (*http.Response, error) {
+	select {
+	case c.sem <- struct{}{}:
+	case <-req.Context().Done():
+		return nil, req.Context().Err()
+	}
+	release := func() { <-c.sem }
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		release()
+		return nil, err
+	}
+	resp.Body = &releaseOnClose{ReadCloser: resp.Body, release: release}
+	return resp, nil
+}
+
+// releaseOnClose libera a vaga do semáforo ao fechar o corpo da resposta
+type releaseOnClose struct {
+	io.ReadCloser
+	release func()
+	once    sync.Once
+}
+
+func (r *releaseOnClose) Close() error {
+	err := r.ReadCloser.Close()
+	r.once.Do(r.release)
+	return err
+}
@@ -0,0 +1,137 @@
+package uazapi
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestBackoffDelay(t *testing.T) {
+	tests := []struct {
+		attempt int
+		min     time.Duration
+		max     time.Duration
+	}{
+		{0, 250 * time.Millisecond, 500 * time.Millisecond},
+		{1, 500 * time.Millisecond, time.Second},
+		{3, 2 * time.Second, 4 * time.Second},
+		{6, 15 * time.Second, retryMaxDelay},
+		{16, 15 * time.Second, retryMaxDelay},
+		{100, 15 * time.Second, retryMaxDelay},
+	}
+
+	for _, tt := range tests {
+		t.Run(fmt.Sprintf("tentativa %d", tt.attempt), func(t *testing.T) {
+			for i := 0; i < 100; i++ {
+				if got := backoffDelay(tt.attempt); got < tt.min || got > tt.max {
+					t.Fatalf("backoffDelay(%d) = %s, want between %s and %s", tt.attempt, got, tt.min, tt.max)
+				}
+			}
+		})
+	}
+}
+
+func TestParseRetryAfter(t *testing.T) {
+	tests := []struct {
+		name  string
+		value string
+		min   time.Duration
+		max   time.Duration
+	}{
+		{"vazio", "", 0, 0},
+		{"segundos", "5", 5 * time.Second, 5 * time.Second},
+		{"zero", "0", 0, 0},
+		{"negativo", "-3", 0, 0},
+		{"inválido", "logo", 0, 0},
+		{"data futura", time.Now().Add(10 * time.Second).UTC().Format(http.TimeFormat), 8 * time.Second, 10 * time.Second},
+		{"data passada", time.Now().Add(-time.Minute).UTC().Format(http.TimeFormat), 0, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := parseRetryAfter(tt.value); got < tt.min || got > tt.max {
+				t.Errorf("parseRetryAfter(%q) = %s, want between %s and %s", tt.value, got, tt.min, tt.max)
+			}
+		})
+	}
+}
+
+func TestShouldRetry(t *testing.T) {
+	transport := &TransportError{Path: "/chat/find", Err: errors.New("connection reset")}
+	tests := []struct {
+		name       string
+		err        error
+		idempotent bool
+		want       bool
+	}{
+		{"429 idempotente", &APIError{StatusCode: http.StatusTooManyRequests}, true, true},
+		{"429 não idempotente", &APIError{StatusCode: http.StatusTooManyRequests}, false, true},
+		{"500 idempotente", &APIError{StatusCode: http.StatusInternalServerError}, true, true},
+		{"503 não idempotente", &APIError{StatusCode: http.StatusServiceUnavailable}, false, false},
+		{"400", &APIError{StatusCode: http.StatusBadRequest}, true, false},
+		{"401", &APIError{StatusCode: http.StatusUnauthorized}, true, false},
+		{"transporte idempotente", transport, true, true},
+		{"transporte não idempotente", transport, false, false},
+		{"transporte embrulhado", fmt.Errorf("send: %w", transport), true, true},
+		{"contexto cancelado", context.Canceled, true, false},
+		{"erro de decodificação", errors.New("failed to decode response"), true, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := shouldRetry(tt.err, tt.idempotent); got != tt.want {
+				t.Errorf("shouldRetry() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRetryDelay(t *testing.T) {
+	tests := []struct {
+		name   string
+		err    error
+		min    time.Duration
+		max    time.Duration
+		wantOK bool
+	}{
+		{"sem Retry-After", &APIError{StatusCode: http.StatusBadGateway}, 250 * time.Millisecond, 500 * time.Millisecond, true},
+		{"Retry-After", &APIError{StatusCode: http.StatusTooManyRequests, RetryAfter: 7 * time.Second}, 7 * time.Second, 7 * time.Second, true},
+		{"Retry-After no limite", &APIError{StatusCode: http.StatusTooManyRequests, RetryAfter: retryMaxDelay}, retryMaxDelay, retryMaxDelay, true},
+		{"Retry-After acima do limite", &APIError{StatusCode: http.StatusTooManyRequests, RetryAfter: time.Hour}, 0, 0, false},
+		{"transporte", &TransportError{Err: errors.New("timeout")}, 250 * time.Millisecond, 500 * time.Millisecond, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := retryDelay(tt.err, 0)
+			if ok != tt.wantOK || got < tt.min || got > tt.max {
+				t.Errorf("retryDelay() = (%s, %v), want between %s and %s, %v", got, ok, tt.min, tt.max, tt.wantOK)
+			}
+		})
+	}
+}
+
+func TestAPIErrorIs(t *testing.T) {
+	tests := []struct {
+		status int
+		want   bool
+	}{
+		{http.StatusUnauthorized, true},
+		{http.StatusForbidden, true},
+		{http.StatusTooManyRequests, false},
+		{http.StatusInternalServerError, false},
+		{http.StatusNotFound, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(http.StatusText(tt.status), func(t *testing.T) {
+			err := fmt.Errorf("list chats: %w", &APIError{Path: "/chat/find", StatusCode: tt.status})
+			if got := errors.Is(err, ErrUnauthorized); got != tt.want {
+				t.Errorf("errors.Is(%d, ErrUnauthorized) = %v, want %v", tt.status, got, tt.want)
+			}
+		})
+	}
+}
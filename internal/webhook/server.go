@@ -0,0 +1,142 @@
+package webhook
+
+import (
+	"chatwoot-sync-go/internal/config"
+	"chatwoot-sync-go/internal/models"
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"log"
+	"net"
+	"net/http"
+	"time"
+)
+
+// maxBodySize limita o tamanho dos payloads recebidos
+const maxBodySize = 10 << 20
+
+// Handler processa os eventos recebidos pelo servidor
+type Handler interface {
+	HandleUAZAPIEvent(ctx context.Context, event *models.UAZAPIWebhookEvent) error
+	HandleChatwootEvent(ctx context.Context, event *models.ChatwootWebhookEvent) error
+}
+
+// Server recebe os webhooks da UAZAPI e, com a ponte de saída habilitada, do Chatwoot
+type Server struct {
+	cfg        *config.Config
+	handler    Handler
+	httpServer *http.Server
+}
+
+func NewServer(cfg *config.Config, handler Handler) *Server {
+	s := &Server{
+		cfg:     cfg,
+		handler: handler,
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/health", s.handleHealth)
+	mux.HandleFunc("/webhook/uazapi", s.handleUAZAPI)
+	if cfg.Webhook.OutboundEnabled {
+		mux.HandleFunc("/webhook/chatwoot", s.handleChatwoot)
+	}
+
+	s.httpServer = &http.Server{
+		Addr:              cfg.Webhook.ListenAddr,
+		Handler:           mux,
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+
+	return s
+}
+
+// Start inicia o servidor em background. As requisições herdam ctx, então cancelá-lo
+// interrompe o processamento dos eventos em andamento.
+func (s *Server) Start(ctx context.Context) {
+	s.httpServer.BaseContext = func(net.Listener) context.Context { return ctx }
+	go func() {
+		log.Printf("Webhook server listening on %s", s.cfg.Webhook.ListenAddr)
+		if err := s.httpServer.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+			log.Printf("Webhook server failed: %v", err)
+		}
+	}()
+}
+
+// Shutdown encerra o servidor aguardando as requisições em andamento
+func (s *Server) Shutdown(timeout time.Duration) error {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	return s.httpServer.Shutdown(ctx)
+}
+
+func (s *Server) handleHealth(w http.ResponseWriter, r *http.Request) {
+	w.WriteHeader(http.StatusOK)
+	w.Write([]byte("ok"))
+}
+
+func (s *Server) handleUAZAPI(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if !s.authorized(r) {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	var event models.UAZAPIWebhookEvent
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxBodySize)).Decode(&event); err != nil {
+		log.Printf("Webhook: failed to decode UAZAPI payload: %v", err)
+		http.Error(w, "invalid payload", http.StatusBadRequest)
+		return
+	}
+
+	if err := s.handler.HandleUAZAPIEvent(r.Context(), &event); err != nil {
+		log.Printf("Webhook: failed to handle UAZAPI event %s: %v", event.EventType, err)
+		http.Error(w, "failed to process event", http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+}
+
+func (s *Server) handleChatwoot(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if !s.authorized(r) {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	var event models.ChatwootWebhookEvent
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxBodySize)).Decode(&event); err != nil {
+		log.Printf("Webhook: failed to decode Chatwoot payload: %v", err)
+		http.Error(w, "invalid payload", http.StatusBadRequest)
+		return
+	}
+
+	if err := s.handler.HandleChatwootEvent(r.Context(), &event); err != nil {
+		log.Printf("Webhook: failed to handle Chatwoot event %s: %v", event.Event, err)
+		http.Error(w, "failed to process event", http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+}
+
+// authorized valida o segredo enviado no header X-Webhook-Secret. O parâmetro ?secret= é aceito
+// apenas para origens que não enviam headers personalizados (ex.: webhooks do Chatwoot); por isso a
+// URL das requisições nunca é registrada no log. Sem segredo configurado, nada é autorizado.
+func (s *Server) authorized(r *http.Request) bool {
+	if s.cfg.Webhook.Secret == "" {
+		return false
+	}
+
+	secret := r.Header.Get("X-Webhook-Secret")
+	if secret == "" {
+		secret = r.URL.Query().Get("secret")
+	}
+	return subtle.ConstantTimeCompare([]byte(secret), []byte(s.cfg.Webhook.Secret)) == 1
+}
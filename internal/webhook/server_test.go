@@ -0,0 +1,41 @@
+package webhook
+
+import (
+	"chatwoot-sync-go/internal/config"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestAuthorized(t *testing.T) {
+	tests := []struct {
+		name       string
+		configured string
+		header     string
+		target     string
+		want       bool
+	}{
+		{"header correto", "s3gredo", "s3gredo", "/webhook/uazapi", true},
+		{"header incorreto", "s3gredo", "outro", "/webhook/uazapi", false},
+		{"query como alternativa", "s3gredo", "", "/webhook/chatwoot?secret=s3gredo", true},
+		{"query incorreta", "s3gredo", "", "/webhook/chatwoot?secret=outro", false},
+		{"header tem prioridade", "s3gredo", "outro", "/webhook/uazapi?secret=s3gredo", false},
+		{"sem segredo enviado", "s3gredo", "", "/webhook/uazapi", false},
+		{"sem segredo configurado", "", "", "/webhook/uazapi", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := &config.Config{}
+			cfg.Webhook.Secret = tt.configured
+			s := &Server{cfg: cfg}
+
+			r := httptest.NewRequest("POST", tt.target, nil)
+			if tt.header != "" {
+				r.Header.Set("X-Webhook-Secret", tt.header)
+			}
+			if got := s.authorized(r); got != tt.want {
+				t.Errorf("authorized() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}